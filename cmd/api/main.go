@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"log/slog"
 	"os"
 	"os/signal" // <--- NEW: To listen for Ctrl+C
@@ -13,6 +14,11 @@ import (
 	"github.com/ibrahimkeyboad/gopay/internal/adapter/middleware"
 	"github.com/ibrahimkeyboad/gopay/internal/adapter/storage"
 	"github.com/ibrahimkeyboad/gopay/internal/core/config"
+	"github.com/ibrahimkeyboad/gopay/internal/core/fx"
+	"github.com/ibrahimkeyboad/gopay/internal/core/pricing"
+	"github.com/ibrahimkeyboad/gopay/internal/core/schedule"
+	"github.com/ibrahimkeyboad/gopay/internal/core/security"
+	"github.com/ibrahimkeyboad/gopay/internal/core/tax"
 	"github.com/ibrahimkeyboad/gopay/internal/core/worker"
 )
 
@@ -32,14 +38,62 @@ func main() {
 	}
 	// We do NOT defer dbPool.Close() here anymore. We close it manually on shutdown.
 
+	// Internal ledger accounts (funding, revenue, floats, clearing, suspense)
+	if err := storage.BootstrapSystemAccounts(context.Background(), dbPool); err != nil {
+		slog.Error("❌ System account bootstrap failed", "error", err)
+		os.Exit(1)
+	}
+
 	// 4. Setup Repos & Handlers
 	accountRepo := storage.NewAccountRepository(dbPool)
 	ledgerRepo := storage.NewLedgerRepository(dbPool)
 
+	// Pricing plans come from a local file when configured, otherwise the built-in ones
+	if cfg.PricingPlansFile != "" {
+		plans, err := pricing.LoadPlans(cfg.PricingPlansFile)
+		if err != nil {
+			slog.Error("❌ Failed to load pricing plans", "error", err, "file", cfg.PricingPlansFile)
+			os.Exit(1)
+		}
+		ledgerRepo.Plans = plans
+	}
+	if cfg.TaxRatesFile != "" {
+		rates, err := tax.LoadTable(cfg.TaxRatesFile)
+		if err != nil {
+			slog.Error("❌ Failed to load tax rates", "error", err, "file", cfg.TaxRatesFile)
+			os.Exit(1)
+		}
+		ledgerRepo.Taxes = rates
+	}
+
 	accountHandler := &handler.AccountHandler{Repo: accountRepo}
+	apiKeyHandler := &handler.APIKeyHandler{Repo: accountRepo, RollOverlap: cfg.KeyRollOverlap}
+	operatorHandler := &handler.OperatorHandler{Repo: storage.NewOperatorRepository(dbPool)}
+	adminHandler := &handler.AdminHandler{Accounts: accountRepo, Ledger: ledgerRepo, Plans: ledgerRepo.Plans}
 	transactionHandler := &handler.TransactionHandler{Repo: ledgerRepo}
 	mobileHandler := &handler.MobileMoneyHandler{Repo: ledgerRepo}
 	paymentHandler := &handler.PaymentHandler{Repo: ledgerRepo}
+	paymentIntentHandler := &handler.PaymentIntentHandler{Repo: ledgerRepo}
+	holdHandler := &handler.HoldHandler{Repo: ledgerRepo}
+	balanceHandler := &handler.BalanceHandler{Accounts: accountRepo, Ledger: ledgerRepo}
+	statementHandler := &handler.StatementHandler{Repo: ledgerRepo}
+	scheduledTransferHandler := &handler.ScheduledTransferHandler{Repo: ledgerRepo}
+
+	// FX rates come from a local file when configured, otherwise from the fx_rates table
+	var rateSource fx.RateSource = storage.NewFXRateRepository(dbPool)
+	if cfg.FXRatesFile != "" {
+		fileRates, err := fx.LoadFileRates(cfg.FXRatesFile)
+		if err != nil {
+			slog.Error("❌ Failed to load FX rates", "error", err, "file", cfg.FXRatesFile)
+			os.Exit(1)
+		}
+		rateSource = fileRates
+	}
+	fxHandler := &handler.FXHandler{
+		Engine:   fx.NewEngine(rateSource, cfg.FXSpreadBps, cfg.FXQuoteTTL),
+		Repo:     ledgerRepo,
+		Accounts: accountRepo,
+	}
 
 	// 5. Setup Fiber
 	app := fiber.New(fiber.Config{
@@ -53,20 +107,91 @@ func main() {
 	// 6. Routes
 	api := app.Group("/v1")
 
-	// Public
-	api.Post("/accounts", accountHandler.CreateAccount)
-	api.Post("/accounts/:id/keys", accountHandler.GenerateKey)
-	api.Post("/charges", paymentHandler.MakeCharge)
+	// Public. A charge made with a secret key falls through to the protected route below.
+	api.Post("/charges", middleware.Anonymous(paymentHandler.MakeCharge))
+
+	// Checkout (publishable keys only). Registered before the secret key middleware.
+	publishable := middleware.Publishable(dbPool)
+	api.Post("/payment_intents", publishable, paymentIntentHandler.Create)
+	api.Get("/payment_intents/:id", publishable, paymentIntentHandler.Get)
+
+	// Back office (operator keys, or ADMIN_API_KEY). Registered before the merchant auth middleware.
+	admin := api.Group("/admin", middleware.Admin(dbPool, cfg.AdminAPIKey))
+	admin.Post("/operators", middleware.AdminKeyOnly(), operatorHandler.Create)
+	admin.Get("/operators", middleware.AdminKeyOnly(), operatorHandler.List)
+	admin.Post("/operators/:id/revoke", middleware.AdminKeyOnly(), operatorHandler.Revoke)
+
+	// Onboarding: an operator opens the account and issues its first key;
+	// from then on the account manages its own keys
+	admin.Post("/accounts", accountHandler.CreateAccount)
+	admin.Post("/accounts/:id/keys", accountHandler.GenerateKey)
+	admin.Get("/accounts/:id/keys", apiKeyHandler.List)
+	admin.Get("/accounts/:id/keys/events", apiKeyHandler.Events)
+	admin.Post("/accounts/:id/keys/:key_id/revoke", apiKeyHandler.Revoke)
+	admin.Post("/accounts/:id/deposit", adminHandler.Deposit)
+	admin.Post("/accounts/:id/freeze", adminHandler.FreezeAccount)
+	admin.Post("/accounts/:id/unfreeze", adminHandler.UnfreezeAccount)
+	admin.Post("/accounts/:id/close", adminHandler.CloseAccount)
+	admin.Post("/accounts/:id/kyc", adminHandler.SetKYCTier)
+	admin.Post("/accounts/:id/credit", adminHandler.SetCreditTerms)
+	admin.Post("/accounts/:id/pricing", adminHandler.SetPricingPlan)
+	admin.Post("/accounts/:id/tax", adminHandler.SetTaxSettings)
+	admin.Get("/tax-report", adminHandler.TaxReport)
+	admin.Get("/overdrawn-accounts", adminHandler.OverdrawnAccounts)
 
 	// Protected
 	private := api.Use(middleware.Protected(dbPool))
-	private.Post("/deposit", transactionHandler.Deposit)
-	private.Post("/transfer", middleware.Idempotency(dbPool), transactionHandler.Transfer)
-	private.Post("/mobile-money", middleware.Idempotency(dbPool), mobileHandler.InitializePayment)
-	private.Get("/accounts/:id/transactions", transactionHandler.GetHistory)
+
+	// Restricted keys only reach the routes their scopes allow (see security.Scopes)
+	readAccounts := middleware.RequireScope(security.ScopeAccountsRead)
+	writeAccounts := middleware.RequireScope(security.ScopeAccountsWrite)
+	readTransactions := middleware.RequireScope(security.ScopeTransactionsRead)
+	writeTransfers := middleware.RequireScope(security.ScopeTransfersWrite)
+	writeCharges := middleware.RequireScope(security.ScopeChargesWrite)
+	writeRefunds := middleware.RequireScope(security.ScopeRefundsWrite)
+	manageKeys := middleware.RequireScope(security.ScopeKeysManage)
+
+	private.Post("/transfer", writeTransfers, middleware.Idempotency(dbPool), transactionHandler.Transfer)
+	private.Post("/platform/transfers", writeTransfers, middleware.Idempotency(dbPool), transactionHandler.PlatformTransfer)
+	private.Post("/scheduled-transfers", writeTransfers, middleware.Idempotency(dbPool), scheduledTransferHandler.Create)
+	private.Get("/scheduled-transfers/:id", readTransactions, scheduledTransferHandler.Get)
+	private.Post("/scheduled-transfers/:id/cancel", writeTransfers, scheduledTransferHandler.Cancel)
+	private.Post("/charges", writeCharges, paymentHandler.MakeCharge)
+	private.Post("/mobile-money", writeCharges, middleware.Idempotency(dbPool), mobileHandler.InitializePayment)
+	private.Get("/balance", readAccounts, balanceHandler.GetBalance)
+	private.Get("/accounts/:id", readAccounts, middleware.AccountOwner("id"), balanceHandler.GetAccount)
+	private.Post("/accounts/:id/children", writeAccounts, middleware.AccountOwner("id"), accountHandler.CreateChildAccount)
+	private.Get("/accounts/:id/children", readAccounts, middleware.AccountOwner("id"), accountHandler.ListChildAccounts)
+	private.Get("/accounts/:id/transactions", readTransactions, middleware.AccountOwner("id"), transactionHandler.GetHistory)
+	private.Get("/accounts/:id/statements", readTransactions, middleware.AccountOwner("id"), statementHandler.GetStatement)
+	private.Get("/accounts/:id/scheduled-transfers", readTransactions, middleware.AccountOwner("id"), scheduledTransferHandler.List)
+	private.Post("/accounts/:id/keys", manageKeys, middleware.AccountOwner("id"), accountHandler.GenerateKey)
+	private.Get("/accounts/:id/keys", manageKeys, middleware.AccountOwner("id"), apiKeyHandler.List)
+	private.Get("/accounts/:id/keys/events", manageKeys, middleware.AccountOwner("id"), apiKeyHandler.Events)
+	private.Post("/accounts/:id/keys/:key_id/revoke", manageKeys, middleware.AccountOwner("id"), apiKeyHandler.Revoke)
+	private.Post("/accounts/:id/keys/:key_id/expiry", manageKeys, middleware.AccountOwner("id"), apiKeyHandler.SetExpiry)
+	private.Post("/accounts/:id/keys/:key_id/roll", manageKeys, middleware.AccountOwner("id"), apiKeyHandler.Roll)
+	private.Get("/transactions/:id", readTransactions, transactionHandler.GetTransaction)
+	private.Post("/transactions/:id/refunds", writeRefunds, middleware.Idempotency(dbPool), transactionHandler.Refund)
+
+	// FX (quote -> convert)
+	private.Post("/fx/quotes", writeTransfers, fxHandler.CreateQuote)
+	private.Post("/fx/conversions", writeTransfers, middleware.Idempotency(dbPool), fxHandler.Convert)
+
+	// Holds (authorize -> capture / void)
+	private.Post("/holds", writeCharges, middleware.Idempotency(dbPool), holdHandler.Authorize)
+	private.Get("/holds/:id", readTransactions, holdHandler.Get)
+	private.Post("/holds/:id/capture", writeCharges, middleware.Idempotency(dbPool), holdHandler.Capture)
+	private.Post("/holds/:id/void", writeCharges, holdHandler.Void)
+
+	// Card payment intents: authorized from the checkout, captured with a secret key
+	private.Post("/payment_intents/:id/capture", writeCharges, middleware.Idempotency(dbPool), paymentIntentHandler.Capture)
 
 	// 7. Start Worker
 	worker.StartWebhookWorker(dbPool)
+	worker.StartHoldExpiryWorker(ledgerRepo)
+	worker.StartSnapshotWorker(ledgerRepo)
+	worker.StartScheduledTransferWorker(ledgerRepo, schedule.DefaultRetryPolicy)
 
 	// ==========================================
 	// 🚀 GRACEFUL SHUTDOWN LOGIC STARTS HERE
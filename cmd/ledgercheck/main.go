@@ -0,0 +1,87 @@
+// Command ledgercheck audits the double-entry ledger and exits non-zero if the books don't balance.
+//
+// Usage:
+//
+//	go run ./cmd/ledgercheck          # human readable summary
+//	go run ./cmd/ledgercheck -json    # full report as JSON (for cron / alerting)
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/ibrahimkeyboad/gopay/internal/adapter/storage"
+	"github.com/ibrahimkeyboad/gopay/internal/core/config"
+)
+
+func main() {
+	asJSON := flag.Bool("json", false, "print the full report as JSON")
+	timeout := flag.Duration("timeout", 5*time.Minute, "give up after this long")
+	flag.Parse()
+
+	// Keep our own output clean: logs go to stderr
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, nil)))
+
+	cfg := config.LoadConfig()
+
+	dbPool, err := storage.ConnectDB(cfg.DatabaseURL)
+	if err != nil {
+		slog.Error("❌ Database connection failed", "error", err)
+		os.Exit(2)
+	}
+	defer dbPool.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	report, err := storage.CheckLedger(ctx, dbPool)
+	if err != nil {
+		slog.Error("❌ Ledger check failed to run", "error", err)
+		os.Exit(2)
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			slog.Error("Failed to encode report", "error", err)
+			os.Exit(2)
+		}
+	} else {
+		printSummary(report)
+	}
+
+	if !report.OK() {
+		os.Exit(1)
+	}
+}
+
+func printSummary(r *storage.AuditReport) {
+	fmt.Printf("Checked %d transactions and %d accounts\n\n", r.TransactionsChecked, r.AccountsChecked)
+
+	fmt.Printf("Unbalanced transactions: %d\n", len(r.UnbalancedTransactions))
+	for _, u := range r.UnbalancedTransactions {
+		fmt.Printf("  %s  %s  debits=%d credits=%d\n", u.TransactionID, u.Currency, u.Debits, u.Credits)
+	}
+
+	fmt.Printf("Balance mismatches:      %d\n", len(r.BalanceMismatches))
+	for _, m := range r.BalanceMismatches {
+		fmt.Printf("  %s  stored=%d entries=%d (off by %d)\n", m.AccountID, m.StoredBalance, m.EntryBalance, m.StoredBalance-m.EntryBalance)
+	}
+
+	fmt.Printf("Orphan entries:          %d\n", len(r.OrphanEntries))
+	for _, o := range r.OrphanEntries {
+		fmt.Printf("  tx=%s account=%s %s %d (missing %s)\n", o.TransactionID, o.AccountID, o.Direction, o.Amount, o.Missing)
+	}
+
+	if r.OK() {
+		fmt.Println("\n✅ Ledger is balanced")
+	} else {
+		fmt.Println("\n❌ Ledger has problems")
+	}
+}
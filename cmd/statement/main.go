@@ -0,0 +1,127 @@
+// Command statement exports an account statement for the finance team.
+//
+// Usage:
+//
+//	go run ./cmd/statement -account <id> -from 2024-01-01 -to 2024-02-01            # plain text
+//	go run ./cmd/statement -account <id> -from 2024-01-01 -format csv -out jan.csv
+//	go run ./cmd/statement -snapshot 2024-02-01                                     # backfill snapshots
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"io"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/ibrahimkeyboad/gopay/internal/adapter/storage"
+	"github.com/ibrahimkeyboad/gopay/internal/core/config"
+	"github.com/ibrahimkeyboad/gopay/internal/core/statement"
+)
+
+func main() {
+	accountFlag := flag.String("account", "", "account ID")
+	fromFlag := flag.String("from", "", "start of the period, inclusive (2024-01-01 or RFC 3339)")
+	toFlag := flag.String("to", "", "end of the period, exclusive (default now)")
+	formatFlag := flag.String("format", "txt", "json, csv or txt")
+	outFlag := flag.String("out", "", "write to this file instead of stdout")
+	snapshotFlag := flag.String("snapshot", "", "take balance snapshots at this moment and exit")
+	timeout := flag.Duration("timeout", 5*time.Minute, "give up after this long")
+	flag.Parse()
+
+	// Keep our own output clean: logs go to stderr
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, nil)))
+
+	cfg := config.LoadConfig()
+
+	dbPool, err := storage.ConnectDB(cfg.DatabaseURL)
+	if err != nil {
+		slog.Error("❌ Database connection failed", "error", err)
+		os.Exit(2)
+	}
+	defer dbPool.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	repo := storage.NewLedgerRepository(dbPool)
+
+	if *snapshotFlag != "" {
+		at, err := statement.ParseTime(*snapshotFlag)
+		if err != nil {
+			fail(err)
+		}
+		taken, err := repo.TakeBalanceSnapshots(ctx, at)
+		if err != nil {
+			slog.Error("❌ Snapshot failed", "error", err)
+			os.Exit(2)
+		}
+		slog.Info("✅ Balance snapshots taken", "count", taken, "snapshot_at", at)
+		return
+	}
+
+	accountID, err := uuid.Parse(*accountFlag)
+	if err != nil {
+		fail(err)
+	}
+	format, err := statement.ParseFormat(*formatFlag)
+	if err != nil {
+		fail(err)
+	}
+	from, err := statement.ParseTime(*fromFlag)
+	if err != nil {
+		fail(err)
+	}
+	to := time.Now()
+	if *toFlag != "" {
+		if to, err = statement.ParseTime(*toFlag); err != nil {
+			fail(err)
+		}
+	}
+	if err := statement.ValidateRange(from, to); err != nil {
+		fail(err)
+	}
+
+	s, err := repo.GetStatement(ctx, accountID, from, to)
+	if err != nil {
+		slog.Error("❌ Statement failed", "error", err, "account_id", accountID)
+		os.Exit(2)
+	}
+
+	var out io.Writer = os.Stdout
+	if *outFlag != "" {
+		f, err := os.Create(*outFlag)
+		if err != nil {
+			slog.Error("❌ Cannot create output file", "error", err)
+			os.Exit(2)
+		}
+		defer f.Close()
+		out = f
+	}
+
+	switch format {
+	case statement.FormatJSON:
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(s)
+	case statement.FormatCSV:
+		err = statement.WriteCSV(out, s)
+	default:
+		err = statement.WriteText(out, s)
+	}
+	if err != nil {
+		slog.Error("❌ Failed to write statement", "error", err)
+		os.Exit(2)
+	}
+}
+
+// fail reports a bad flag and exits with the usage
+func fail(err error) {
+	slog.Error("Invalid arguments", "error", err)
+	flag.Usage()
+	os.Exit(2)
+}
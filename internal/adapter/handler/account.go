@@ -1,13 +1,16 @@
 package handler
 
 import (
+	"errors"
 	"log/slog"
 	"net/http"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
 
+	"github.com/ibrahimkeyboad/gopay/internal/adapter/middleware"
 	"github.com/ibrahimkeyboad/gopay/internal/adapter/storage"
+	"github.com/ibrahimkeyboad/gopay/internal/core/domain"
 
 	// FIX: This import was missing!
 	"github.com/ibrahimkeyboad/gopay/internal/core/security"
@@ -23,6 +26,8 @@ type CreateAccountRequest struct {
 	Currency  string `json:"currency"`
 }
 
+// CreateAccount onboards a merchant. It is an admin route; the operator then issues
+// the account's first key with GenerateKey.
 func (h *AccountHandler) CreateAccount(c *fiber.Ctx) error {
 	var req CreateAccountRequest
 
@@ -37,24 +42,36 @@ func (h *AccountHandler) CreateAccount(c *fiber.Ctx) error {
 		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Owner Name is required"})
 	}
 
-	validCurrencies := map[string]bool{"USD": true, "TZS": true}
-	if !validCurrencies[req.Currency] {
-		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid currency. Use USD or TZS"})
+	currency, err := domain.LookupCurrency(req.Currency)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
 	}
 
 	// 3. Call Storage
-	account, err := h.Repo.CreateAccount(c.Context(), req.OwnerName, req.Currency)
+	account, err := h.Repo.CreateAccount(c.Context(), req.OwnerName, string(currency.Code))
 	if err != nil {
 		slog.Error("Failed to create account", "error", err, "owner", req.OwnerName)
 		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Could not create account"})
 	}
 
-	slog.Info("✅ Account Created", "id", account.ID, "owner", req.OwnerName)
+	createdBy := actor(c)
+	slog.Info("✅ Account Created", "id", account.ID, "owner", req.OwnerName, "created_by", createdBy.Type, "creator_id", createdBy.ID)
 
 	// 4. Return Success
 	return c.Status(http.StatusCreated).JSON(account)
 }
 
+// GenerateKeyRequest optionally restricts the new key. Without scopes the key is unrestricted.
+type GenerateKeyRequest struct {
+	Scopes     []string `json:"scopes"`      // e.g. ["accounts:read", "transactions:read"]
+	AllowedIPs []string `json:"allowed_ips"` // Addresses or CIDR ranges
+}
+
+// GenerateKey issues a live key for :id, or a sandbox key with ?mode=test.
+// Test keys are bound to the account's test twin, which is created on first use.
+// With ?type=publishable it issues a gp_pk_ key for checkouts instead of a secret key.
+// It is mounted for operators (onboarding) and for the account's own keys; either way the
+// issuer is recorded in the key's audit trail.
 func (h *AccountHandler) GenerateKey(c *fiber.Ctx) error {
 	accountIDParam := c.Params("id")
 
@@ -64,25 +81,185 @@ func (h *AccountHandler) GenerateKey(c *fiber.Ctx) error {
 		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid Account ID format"})
 	}
 
+	var req GenerateKeyRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+		}
+	}
+	var scopes, allowedIPs []string
+	if req.Scopes != nil {
+		if scopes, err = security.ParseScopes(req.Scopes); err != nil {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+	}
+	if req.AllowedIPs != nil {
+		if allowedIPs, err = security.ParseAllowlist(req.AllowedIPs); err != nil {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+	}
+
+	publishable := false
+	switch c.Query("type", "secret") {
+	case "secret":
+	case "publishable":
+		// A publishable key has exactly one power: starting payments
+		if scopes != nil || allowedIPs != nil {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Publishable keys cannot have scopes or an IP allowlist"})
+		}
+		publishable = true
+	default:
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "type must be secret or publishable"})
+	}
+
+	// A restricted key can't hand out more power than it has
+	if callerScopes := middleware.Scopes(c); callerScopes != nil && publishable {
+		if !security.HasScope(callerScopes, security.ScopeChargesWrite) {
+			return c.Status(http.StatusForbidden).JSON(fiber.Map{"error": "Issuing a publishable key needs the charges:write scope"})
+		}
+	} else if callerScopes != nil {
+		if scopes == nil {
+			return c.Status(http.StatusForbidden).JSON(fiber.Map{"error": "A restricted key can only issue restricted keys"})
+		}
+		for _, s := range scopes {
+			if !security.HasScope(callerScopes, security.Scope(s)) {
+				return c.Status(http.StatusForbidden).JSON(fiber.Map{"error": "This API key cannot grant the " + s + " scope"})
+			}
+		}
+	}
+	// Nor can a key pinned to an allowlist mint one that works from further afield.
+	// Publishable keys are exempt: they run in customers' browsers and can only start payments.
+	if callerIPs := middleware.AllowedIPs(c); callerIPs != nil && !publishable && !security.AllowlistCovers(callerIPs, allowedIPs) {
+		return c.Status(http.StatusForbidden).JSON(fiber.Map{"error": "This API key can only issue keys whose allowed_ips are within its own allowlist"})
+	}
+
+	livemode := true
+	switch c.Query("mode", "live") {
+	case "live":
+	case "test":
+		livemode = false
+		twin, err := h.Repo.TestAccount(c.Context(), accountUUID)
+		if err != nil {
+			return keyError(c, err, accountUUID)
+		}
+		accountUUID = twin.ID
+	default:
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "mode must be live or test"})
+	}
+
+	prefix := security.PrefixLive
+	switch {
+	case publishable && livemode:
+		prefix = security.PrefixPublishableLive
+	case publishable:
+		prefix = security.PrefixPublishableTest
+	case !livemode:
+		prefix = security.PrefixTest
+	}
+
 	// 2. Generate Secure Key
-	realKey, keyHash, err := security.GenerateAPIKey()
+	realKey, keyHash, err := security.GenerateAPIKey(prefix)
 	if err != nil {
 		slog.Error("Crypto error generating key", "error", err)
 		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Crypto error"})
 	}
 
 	// 3. Save Hash to DB
-	err = h.Repo.SaveAPIKey(c.Context(), accountUUID, keyHash, "sk_live_")
+	issuer := actor(c)
+	last4 := security.Last4(realKey)
+	saved, err := h.Repo.SaveAPIKey(c.Context(), storage.APIKey{
+		AccountID: accountUUID,
+		Prefix:    prefix,
+		Last4:     &last4,
+		Livemode:  livemode,
+		Hash:      keyHash,
+
+		Publishable: publishable,
+
+		Scopes:     scopes,
+		AllowedIPs: allowedIPs,
+	}, issuer)
 	if err != nil {
-		slog.Error("Failed to save API key", "error", err, "account_id", accountUUID)
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to save key"})
+		return keyError(c, err, accountUUID)
 	}
 
-	slog.Info("🔑 API Key Generated", "account_id", accountUUID)
+	slog.Info("🔑 API Key Generated", "account_id", accountUUID, "livemode", saved.Livemode, "publishable", saved.Publishable, "scopes", saved.Scopes,
+		"issued_by", issuer.Type, "issuer_id", issuer.ID)
 
 	// 4. Show Key to User (ONCE ONLY)
 	return c.JSON(fiber.Map{
-		"api_key": realKey,
-		"warning": "Save this now! We won't show it again.",
+		"api_key":    realKey,
+		"key":        saved,
+		"account_id": accountUUID,
+		"livemode":   saved.Livemode,
+		"warning":    "Save this now! We won't show it again.",
 	})
-}
\ No newline at end of file
+}
+
+func keyError(c *fiber.Ctx, err error, accountUUID uuid.UUID) error {
+	if errors.Is(err, storage.ErrSystemAccount) {
+		return c.Status(http.StatusForbidden).JSON(fiber.Map{"error": err.Error()})
+	}
+	if errors.Is(err, storage.ErrAccountNotFound) {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	}
+	if errors.Is(err, storage.ErrLivemodeMismatch) {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Live keys are issued for live accounts; use mode=test for a test key"})
+	}
+	if err != nil {
+		slog.Error("Failed to save API key", "error", err, "account_id", accountUUID)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to save key"})
+	}
+	return nil
+}
+
+// CreateChildAccount opens a sub-account under :id (e.g. a seller on a marketplace)
+func (h *AccountHandler) CreateChildAccount(c *fiber.Ctx) error {
+	// Ownership is already checked by middleware.AccountOwner on the route.
+	parentID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid Account ID"})
+	}
+
+	var req CreateAccountRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	if req.OwnerName == "" {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Owner Name is required"})
+	}
+	currency, err := domain.LookupCurrency(req.Currency)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	account, err := h.Repo.CreateChildAccount(c.Context(), parentID, req.OwnerName, string(currency.Code))
+	switch {
+	case errors.Is(err, storage.ErrAccountNotFound):
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, storage.ErrNestedSubAccount), errors.Is(err, storage.ErrAccountClosed), errors.Is(err, storage.ErrSystemAccount):
+		return c.Status(http.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	case err != nil:
+		slog.Error("Failed to create sub-account", "error", err, "parent_id", parentID)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Could not create account"})
+	}
+
+	slog.Info("✅ Sub-account Created", "id", account.ID, "parent_id", parentID, "owner", req.OwnerName)
+	return c.Status(http.StatusCreated).JSON(account)
+}
+
+// ListChildAccounts returns the sub-accounts of :id
+func (h *AccountHandler) ListChildAccounts(c *fiber.Ctx) error {
+	// Ownership is already checked by middleware.AccountOwner on the route.
+	parentID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid Account ID"})
+	}
+
+	accounts, err := h.Repo.ListChildren(c.Context(), parentID)
+	if err != nil {
+		slog.Error("Failed to list sub-accounts", "error", err, "parent_id", parentID)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Could not list accounts"})
+	}
+	return c.JSON(fiber.Map{"accounts": accounts})
+}
@@ -0,0 +1,307 @@
+package handler
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"github.com/ibrahimkeyboad/gopay/internal/adapter/middleware"
+	"github.com/ibrahimkeyboad/gopay/internal/adapter/storage"
+	"github.com/ibrahimkeyboad/gopay/internal/core/domain"
+	"github.com/ibrahimkeyboad/gopay/internal/core/pricing"
+	"github.com/ibrahimkeyboad/gopay/internal/core/tax"
+)
+
+// AdminHandler serves back-office operations (compliance, support)
+type AdminHandler struct {
+	Accounts *storage.AccountRepository
+	Ledger   *storage.LedgerRepository
+	Plans    pricing.Plans // Plans an account can be moved to
+}
+
+type AccountStatusRequest struct {
+	Reason string `json:"reason"` // e.g. COMPLIANCE_REVIEW, SUSPECTED_FRAUD, REVIEW_CLEARED
+	Note   string `json:"note"`   // Free text for the case file
+}
+
+// FreezeAccount stops money leaving an account. It can still receive.
+func (h *AdminHandler) FreezeAccount(c *fiber.Ctx) error {
+	return h.setStatus(c, storage.AccountFrozen)
+}
+
+// UnfreezeAccount returns a frozen account to ACTIVE
+func (h *AdminHandler) UnfreezeAccount(c *fiber.Ctx) error {
+	return h.setStatus(c, storage.AccountActive)
+}
+
+// CloseAccount closes an empty account for good
+func (h *AdminHandler) CloseAccount(c *fiber.Ctx) error {
+	return h.setStatus(c, storage.AccountClosed)
+}
+
+func (h *AdminHandler) setStatus(c *fiber.Ctx, status storage.AccountStatus) error {
+	accountID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid Account ID"})
+	}
+
+	var req AccountStatusRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid body"})
+	}
+	reason, err := storage.ParseStatusReason(req.Reason)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	account, err := h.Accounts.SetStatus(c.Context(), accountID, status, reason, req.Note)
+	switch {
+	case errors.Is(err, storage.ErrAccountNotFound):
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, storage.ErrSystemAccount):
+		return c.Status(http.StatusForbidden).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, storage.ErrInvalidStatusChange), errors.Is(err, storage.ErrAccountNotEmpty):
+		return c.Status(http.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	case err != nil:
+		slog.Error("Failed to change account status", "error", err, "account_id", accountID)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Could not update account"})
+	}
+
+	slog.Info("🧊 Account Status Changed", "account_id", accountID, "status", status, "reason", reason)
+	return c.JSON(account)
+}
+
+type DepositRequest struct {
+	Amount   int64  `json:"amount"`   // Cents!
+	Currency string `json:"currency"` // Defaults to TZS
+	Note     string `json:"note"`     // Why the money was added, e.g. a bank transfer reference
+}
+
+// Deposit credits an account from FUNDING, e.g. for money received outside the platform.
+// It creates money in the ledger, so only operators can do it, never a merchant's key.
+func (h *AdminHandler) Deposit(c *fiber.Ctx) error {
+	accountID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid Account ID"})
+	}
+
+	var req DepositRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid body"})
+	}
+	amount := domain.NewMoney(req.Amount, requestCurrency(req.Currency))
+	if err := domain.ValidateAmount(amount); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	description := "Manual Deposit"
+	if req.Note != "" {
+		description += ": " + req.Note
+	}
+	err = h.Ledger.Deposit(c.Context(), storage.SystemFunding, accountID, amount, description)
+	if limitErr, ok := asLimitError(err); ok {
+		return limitExceeded(c, limitErr)
+	}
+	switch {
+	case errors.Is(err, storage.ErrAccountNotFound):
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, storage.ErrSystemAccount):
+		return c.Status(http.StatusForbidden).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, storage.ErrAccountClosed):
+		return c.Status(http.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, storage.ErrCurrencyMismatch):
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	case err != nil:
+		slog.Error("Manual deposit failed", "error", err, "account_id", accountID)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Could not deposit"})
+	}
+
+	operatorID, _ := middleware.OperatorID(c)
+	slog.Info("🏦 Manual Deposit", "account_id", accountID, "amount", amount.Amount, "currency", amount.Currency,
+		"operator_id", operatorID, "admin_key", middleware.IsAdminKey(c))
+	return c.JSON(fiber.Map{"status": "success", "message": "Money Deposited!"})
+}
+
+type KYCTierRequest struct {
+	Tier string `json:"tier"` // TIER_0, TIER_1 or TIER_2
+}
+
+// SetKYCTier records the outcome of a KYC review
+func (h *AdminHandler) SetKYCTier(c *fiber.Ctx) error {
+	accountID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid Account ID"})
+	}
+
+	var req KYCTierRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid body"})
+	}
+	tier, err := domain.ParseKYCTier(req.Tier)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	account, err := h.Accounts.SetKYCTier(c.Context(), accountID, tier)
+	switch {
+	case errors.Is(err, storage.ErrAccountNotFound):
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, storage.ErrSystemAccount):
+		return c.Status(http.StatusForbidden).JSON(fiber.Map{"error": err.Error()})
+	case err != nil:
+		slog.Error("Failed to change KYC tier", "error", err, "account_id", accountID)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Could not update account"})
+	}
+
+	slog.Info("🪪 KYC Tier Changed", "account_id", accountID, "tier", tier)
+	return c.JSON(account)
+}
+
+// SetCreditTerms sets an account's overdraft or turns it into a credit line.
+// Body: {"account_type": "CREDIT_LINE", "credit_limit": 500000000, "grace_period_days": 14}
+func (h *AdminHandler) SetCreditTerms(c *fiber.Ctx) error {
+	accountID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid Account ID"})
+	}
+
+	terms := storage.CreditTerms{AccountType: storage.AccountStandard}
+	if err := c.BodyParser(&terms); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid body"})
+	}
+	if err := terms.Validate(); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	account, err := h.Accounts.SetCreditTerms(c.Context(), accountID, terms)
+	switch {
+	case errors.Is(err, storage.ErrAccountNotFound):
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, storage.ErrSystemAccount):
+		return c.Status(http.StatusForbidden).JSON(fiber.Map{"error": err.Error()})
+	case err != nil:
+		slog.Error("Failed to change credit terms", "error", err, "account_id", accountID)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Could not update account"})
+	}
+
+	slog.Info("💳 Credit Terms Changed", "account_id", accountID, "type", terms.AccountType,
+		"overdraft_limit", terms.OverdraftLimit, "credit_limit", terms.CreditLimit)
+	return c.JSON(account)
+}
+
+// OverdrawnAccounts lists accounts that are still negative after their grace period
+func (h *AdminHandler) OverdrawnAccounts(c *fiber.Ctx) error {
+	accounts, err := h.Accounts.OverdrawnAccounts(c.Context(), time.Now())
+	if err != nil {
+		slog.Error("Failed to list overdrawn accounts", "error", err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Could not list accounts"})
+	}
+	return c.JSON(fiber.Map{"accounts": accounts})
+}
+
+type PricingPlanRequest struct {
+	Plan string `json:"plan"` // e.g. STANDARD
+}
+
+// SetPricingPlan moves an account to another pricing plan. New payments are priced with it.
+func (h *AdminHandler) SetPricingPlan(c *fiber.Ctx) error {
+	accountID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid Account ID"})
+	}
+
+	var req PricingPlanRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid body"})
+	}
+	plan, err := h.Plans.Get(req.Plan)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	account, err := h.Accounts.SetPricingPlan(c.Context(), accountID, plan.Name)
+	switch {
+	case errors.Is(err, storage.ErrAccountNotFound):
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, storage.ErrSystemAccount):
+		return c.Status(http.StatusForbidden).JSON(fiber.Map{"error": err.Error()})
+	case err != nil:
+		slog.Error("Failed to change pricing plan", "error", err, "account_id", accountID)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Could not update account"})
+	}
+
+	slog.Info("🏷️ Pricing Plan Changed", "account_id", accountID, "plan", plan.Name)
+	return c.JSON(account)
+}
+
+// SetTaxSettings sets where an account's fees are taxed and whether we withhold tax for it.
+// Body: {"jurisdiction": "TZ", "withholding_tax": true}
+func (h *AdminHandler) SetTaxSettings(c *fiber.Ctx) error {
+	accountID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid Account ID"})
+	}
+
+	var settings storage.TaxSettings
+	if err := c.BodyParser(&settings); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid body"})
+	}
+	if settings.Jurisdiction == "" {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "jurisdiction is required"})
+	}
+
+	account, err := h.Accounts.SetTaxSettings(c.Context(), accountID, settings)
+	switch {
+	case errors.Is(err, storage.ErrAccountNotFound):
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, storage.ErrSystemAccount):
+		return c.Status(http.StatusForbidden).JSON(fiber.Map{"error": err.Error()})
+	case err != nil:
+		slog.Error("Failed to change tax settings", "error", err, "account_id", accountID)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Could not update account"})
+	}
+
+	slog.Info("🧾 Tax Settings Changed", "account_id", accountID, "jurisdiction", account.TaxJurisdiction,
+		"withholding_tax", account.WithholdingTax)
+	return c.JSON(account)
+}
+
+// TaxReport totals the tax we owe for ?month=2024-01 (default last month), as JSON or ?format=csv
+func (h *AdminHandler) TaxReport(c *fiber.Ctx) error {
+	month := c.Query("month")
+	if month == "" {
+		month = time.Now().AddDate(0, -1, 0).Format("2006-01")
+	}
+	from, to, err := tax.ReportPeriod(month)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	format := c.Query("format", "json")
+	if format != "json" && format != "csv" {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "format must be json or csv"})
+	}
+
+	report, err := h.Ledger.TaxReport(c.Context(), month, from, to)
+	if err != nil {
+		slog.Error("Failed to build tax report", "error", err, "month", month)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Could not build tax report"})
+	}
+	if format == "json" {
+		return c.JSON(report)
+	}
+
+	var buf bytes.Buffer
+	if err := tax.WriteCSV(&buf, report); err != nil {
+		slog.Error("Failed to render tax report", "error", err, "month", month)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Could not render tax report"})
+	}
+	c.Set(fiber.HeaderContentType, "text/csv; charset=utf-8")
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="tax-report-%s.csv"`, month))
+	return c.Send(buf.Bytes())
+}
@@ -0,0 +1,198 @@
+package handler
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"github.com/ibrahimkeyboad/gopay/internal/adapter/middleware"
+	"github.com/ibrahimkeyboad/gopay/internal/adapter/storage"
+	"github.com/ibrahimkeyboad/gopay/internal/core/security"
+)
+
+// maxRollOverlap caps how long a rolled key may keep working alongside its replacement
+const maxRollOverlap = 7 * 24 * time.Hour
+
+// APIKeyHandler manages the keys of an account. Merchant routes sit behind
+// middleware.AccountOwner, so a key can only manage keys of accounts it owns;
+// operators reach the same handlers under /v1/admin.
+type APIKeyHandler struct {
+	Repo *storage.AccountRepository
+
+	// RollOverlap is how long a rolled key keeps working when the request doesn't say
+	RollOverlap time.Duration
+}
+
+// List returns the keys of :id. Only the prefix and last 4 characters are shown.
+func (h *APIKeyHandler) List(c *fiber.Ctx) error {
+	accountID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid Account ID"})
+	}
+
+	keys, err := h.Repo.ListAPIKeys(c.Context(), accountID)
+	if err != nil {
+		return apiKeyError(c, err)
+	}
+	return c.JSON(fiber.Map{"keys": keys})
+}
+
+// Events returns the audit trail of the keys of :id: who issued, rolled, revoked or changed each one
+func (h *APIKeyHandler) Events(c *fiber.Ctx) error {
+	accountID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid Account ID"})
+	}
+
+	events, err := h.Repo.ListAPIKeyEvents(c.Context(), accountID)
+	if err != nil {
+		return apiKeyError(c, err)
+	}
+	return c.JSON(fiber.Map{"events": events})
+}
+
+// Revoke stops a key from working straight away
+func (h *APIKeyHandler) Revoke(c *fiber.Ctx) error {
+	key, err := h.load(c)
+	if err != nil || key == nil {
+		return err
+	}
+
+	key, err = h.Repo.RevokeAPIKey(c.Context(), key.AccountID, key.ID, actor(c))
+	if err != nil {
+		return apiKeyError(c, err)
+	}
+
+	slog.Info("🔑 API Key Revoked", "account_id", key.AccountID, "key_id", key.ID)
+	return c.JSON(key)
+}
+
+type KeyExpiryRequest struct {
+	ExpiresAt *time.Time `json:"expires_at"` // RFC 3339; null removes the expiry
+}
+
+// SetExpiry sets or clears the time a key stops working
+func (h *APIKeyHandler) SetExpiry(c *fiber.Ctx) error {
+	key, err := h.load(c)
+	if err != nil || key == nil {
+		return err
+	}
+
+	var req KeyExpiryRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid body"})
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "expires_at must be in the future (revoke the key to stop it now)"})
+	}
+
+	key, err = h.Repo.SetAPIKeyExpiry(c.Context(), key.AccountID, key.ID, req.ExpiresAt, actor(c))
+	if err != nil {
+		return apiKeyError(c, err)
+	}
+
+	slog.Info("🔑 API Key Expiry Set", "account_id", key.AccountID, "key_id", key.ID, "expires_at", req.ExpiresAt)
+	return c.JSON(key)
+}
+
+type RollKeyRequest struct {
+	OverlapSeconds *int64 `json:"overlap_seconds"` // How long the old key keeps working; 0 ends it now
+}
+
+// Roll issues a replacement key. The old key keeps working for the overlap window so
+// the new one can be deployed without downtime.
+func (h *APIKeyHandler) Roll(c *fiber.Ctx) error {
+	key, err := h.load(c)
+	if err != nil || key == nil {
+		return err
+	}
+
+	var req RollKeyRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid body"})
+		}
+	}
+	overlap := h.RollOverlap
+	if req.OverlapSeconds != nil {
+		overlap = time.Duration(*req.OverlapSeconds) * time.Second
+	}
+	if overlap < 0 || overlap > maxRollOverlap {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "overlap_seconds must be between 0 and 604800 (7 days)"})
+	}
+
+	// The replacement has the same prefix (and so the same mode) as the old key
+	realKey, keyHash, err := security.GenerateAPIKey(key.Prefix)
+	if err != nil {
+		slog.Error("Crypto error generating key", "error", err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Crypto error"})
+	}
+	last4 := security.Last4(realKey)
+
+	created, old, err := h.Repo.RollAPIKey(c.Context(), key.AccountID, key.ID, storage.APIKey{Hash: keyHash, Last4: &last4}, overlap, actor(c))
+	if err != nil {
+		return apiKeyError(c, err)
+	}
+
+	slog.Info("🔑 API Key Rolled", "account_id", key.AccountID, "old_key_id", old.ID, "new_key_id", created.ID, "old_expires_at", old.ExpiresAt)
+	return c.Status(http.StatusCreated).JSON(fiber.Map{
+		"api_key":      realKey,
+		"key":          created,
+		"previous_key": old,
+		"warning":      "Save this now! We won't show it again.",
+	})
+}
+
+// load parses :id and :key_id and fetches the key. A nil key means the response was already written.
+func (h *APIKeyHandler) load(c *fiber.Ctx) (*storage.APIKey, error) {
+	accountID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return nil, c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid Account ID"})
+	}
+	keyID, err := uuid.Parse(c.Params("key_id"))
+	if err != nil {
+		return nil, c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid Key ID"})
+	}
+
+	key, err := h.Repo.GetAPIKey(c.Context(), accountID, keyID)
+	if err != nil {
+		return nil, apiKeyError(c, err)
+	}
+
+	// A restricted key can only manage keys that have no more power than it has: rolling
+	// a wider key would hand back a secret with the wider key's scopes and allowlist.
+	// Operators carry no restrictions, so this only bites merchant keys.
+	if !security.ScopesCover(middleware.Scopes(c), key.Scopes) || !security.AllowlistCovers(middleware.AllowedIPs(c), key.AllowedIPs) {
+		return nil, c.Status(http.StatusForbidden).JSON(fiber.Map{"error": "This API key cannot manage a key with wider scopes or IP allowlist than its own"})
+	}
+	return key, nil
+}
+
+// actor identifies who is making the request, for the key audit trail
+func actor(c *fiber.Ctx) storage.Actor {
+	a := storage.Actor{IP: c.IP()}
+	if id, ok := middleware.OperatorID(c); ok {
+		a.Type, a.ID = storage.ActorOperator, &id
+	} else if id, ok := middleware.APIKeyID(c); ok {
+		a.Type, a.ID = storage.ActorAPIKey, &id
+	} else if middleware.IsAdminKey(c) {
+		a.Type = storage.ActorAdminKey
+	}
+	return a
+}
+
+func apiKeyError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, storage.ErrAPIKeyNotFound):
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, storage.ErrAPIKeyNotActive):
+		return c.Status(http.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	default:
+		slog.Error("API key operation failed", "error", err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "API key operation failed"})
+	}
+}
@@ -0,0 +1,113 @@
+package handler
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"github.com/ibrahimkeyboad/gopay/internal/adapter/middleware"
+	"github.com/ibrahimkeyboad/gopay/internal/adapter/storage"
+	"github.com/ibrahimkeyboad/gopay/internal/core/domain"
+)
+
+type BalanceHandler struct {
+	Accounts *storage.AccountRepository
+	Ledger   *storage.LedgerRepository
+}
+
+// GetAccount returns an account with its ledger, available and pending balances.
+// Pass ?as_of=<RFC 3339> to see the balance at a past moment.
+func (h *BalanceHandler) GetAccount(c *fiber.Ctx) error {
+	// Ownership is already checked by middleware.AccountOwner on the route.
+	accountID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid Account ID"})
+	}
+
+	asOf, err := parseAsOf(c)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	account, err := h.Accounts.GetAccountByID(c.Context(), accountID)
+	if errors.Is(err, storage.ErrAccountNotFound) {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		slog.Error("Failed to fetch account", "error", err, "account_id", accountID)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Could not fetch account"})
+	}
+
+	balance, err := h.Ledger.GetBalance(c.Context(), accountID, asOf)
+	if err != nil {
+		slog.Error("Failed to compute balance", "error", err, "account_id", accountID)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Could not fetch balance"})
+	}
+
+	// Limits for the account's KYC tier, so clients can show what's left
+	limits, err := domain.LimitsFor(domain.KYCTier(account.KYCTier), domain.Currency(account.Currency))
+	if err != nil {
+		slog.Warn("No KYC limits for account", "error", err, "account_id", accountID)
+	}
+
+	return c.JSON(fiber.Map{
+		"account": account,
+		"balance": balance,
+		"limits":  limits,
+	})
+}
+
+// GetBalance returns the caller's balances, one line per currency.
+// Platforms can pass ?include_children=true to add up their sub-accounts too.
+func (h *BalanceHandler) GetBalance(c *fiber.Ctx) error {
+	merchantID, ok := middleware.MerchantID(c)
+	if !ok {
+		return middleware.Forbidden(c)
+	}
+
+	accountIDs := []uuid.UUID{merchantID}
+	if c.QueryBool("include_children") {
+		children, err := h.Accounts.ChildIDs(c.Context(), merchantID)
+		if err != nil {
+			slog.Error("Failed to list sub-accounts", "error", err, "merchant_id", merchantID)
+			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Could not fetch balance"})
+		}
+		accountIDs = append(accountIDs, children...)
+	}
+
+	asOf, err := parseAsOf(c)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	balances, err := h.Ledger.GetBalances(c.Context(), accountIDs, asOf)
+	if errors.Is(err, storage.ErrAccountNotFound) {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		slog.Error("Failed to compute balances", "error", err, "merchant_id", merchantID)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Could not fetch balance"})
+	}
+
+	return c.JSON(fiber.Map{"balances": balances})
+}
+
+// parseAsOf reads the optional ?as_of= timestamp. It can't be in the future.
+func parseAsOf(c *fiber.Ctx) (*time.Time, error) {
+	v := c.Query("as_of")
+	if v == "" {
+		return nil, nil
+	}
+	asOf, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return nil, errors.New("invalid as_of, use RFC 3339 (e.g. 2024-01-31T00:00:00Z)")
+	}
+	if asOf.After(time.Now()) {
+		return nil, errors.New("as_of cannot be in the future")
+	}
+	return &asOf, nil
+}
@@ -0,0 +1,123 @@
+package handler
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"github.com/ibrahimkeyboad/gopay/internal/adapter/middleware"
+	"github.com/ibrahimkeyboad/gopay/internal/adapter/storage"
+	"github.com/ibrahimkeyboad/gopay/internal/core/domain"
+	"github.com/ibrahimkeyboad/gopay/internal/core/fx"
+)
+
+type FXHandler struct {
+	Engine   *fx.Engine
+	Repo     *storage.LedgerRepository
+	Accounts *storage.AccountRepository
+}
+
+type QuoteRequest struct {
+	AccountID  string `json:"account_id"`  // Wallet the money leaves from
+	ToCurrency string `json:"to_currency"` // Currency to buy
+	Amount     int64  `json:"amount"`      // Cents, in the source wallet's currency
+}
+
+type ConvertRequest struct {
+	QuoteID       string `json:"quote_id"`
+	FromAccountID string `json:"from_account_id"`
+	ToAccountID   string `json:"to_account_id"`
+}
+
+// CreateQuote locks an exchange rate for a short time
+func (h *FXHandler) CreateQuote(c *fiber.Ctx) error {
+	var req QuoteRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid body"})
+	}
+
+	accountID, err := uuid.Parse(req.AccountID)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid account_id"})
+	}
+	if !middleware.CanAccess(c, accountID) {
+		return middleware.Forbidden(c)
+	}
+
+	account, err := h.Accounts.GetAccountByID(c.Context(), accountID)
+	if err != nil {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	amount := domain.NewMoney(req.Amount, domain.Currency(account.Currency))
+	if err := domain.ValidateAmount(amount); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	quote, err := h.Engine.Quote(c.Context(), amount, requestCurrency(req.ToCurrency))
+	if errors.Is(err, fx.ErrRateNotFound) {
+		return c.Status(http.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	saved, err := h.Repo.SaveQuote(c.Context(), accountID, quote)
+	if err != nil {
+		slog.Error("Failed to save FX quote", "error", err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Could not create quote"})
+	}
+
+	slog.Info("💱 FX Quote Created", "quote_id", saved.ID, "from", saved.FromCurrency, "to", saved.ToCurrency, "rate", saved.Rate)
+	return c.Status(http.StatusCreated).JSON(saved)
+}
+
+// Convert books a previously issued quote
+func (h *FXHandler) Convert(c *fiber.Ctx) error {
+	var req ConvertRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid body"})
+	}
+
+	quoteID, err := uuid.Parse(req.QuoteID)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid quote_id"})
+	}
+	fromID, err := uuid.Parse(req.FromAccountID)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid from_account_id"})
+	}
+	toID, err := uuid.Parse(req.ToAccountID)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid to_account_id"})
+	}
+
+	if !middleware.CanAccess(c, fromID) {
+		return middleware.Forbidden(c)
+	}
+
+	return bookConversion(c, h.Repo, quoteID, fromID, toID)
+}
+
+// bookConversion is shared by /fx/conversions and /transfer with a quote_id
+func bookConversion(c *fiber.Ctx, repo *storage.LedgerRepository, quoteID, fromID, toID uuid.UUID) error {
+	txn, err := repo.Convert(c.Context(), quoteID, fromID, toID)
+	if limitErr, ok := asLimitError(err); ok {
+		return limitExceeded(c, limitErr)
+	}
+	switch {
+	case errors.Is(err, storage.ErrQuoteNotFound):
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, storage.ErrQuoteUsed), errors.Is(err, storage.ErrQuoteExpired):
+		return c.Status(http.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	case err != nil:
+		slog.Warn("FX conversion rejected", "error", err, "quote_id", quoteID)
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	slog.Info("💱 FX Conversion Booked", "transaction_id", txn.ID, "quote_id", quoteID)
+	return c.JSON(txn)
+}
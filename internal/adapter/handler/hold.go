@@ -0,0 +1,175 @@
+package handler
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"github.com/ibrahimkeyboad/gopay/internal/adapter/middleware"
+	"github.com/ibrahimkeyboad/gopay/internal/adapter/storage"
+	"github.com/ibrahimkeyboad/gopay/internal/core/domain"
+)
+
+// Holds expire after 7 days unless the caller asks for less (max 30 days)
+const (
+	DefaultHoldTTL = 7 * 24 * time.Hour
+	MaxHoldTTL     = 30 * 24 * time.Hour
+)
+
+type HoldHandler struct {
+	Repo *storage.LedgerRepository
+}
+
+type AuthorizeHoldRequest struct {
+	AccountID        string `json:"account_id"`
+	DestinationID    string `json:"destination_id"`
+	Amount           int64  `json:"amount"`   // Cents
+	Currency         string `json:"currency"` // Defaults to TZS
+	Description      string `json:"description"`
+	ExpiresInSeconds int64  `json:"expires_in_seconds"`
+}
+
+type CaptureHoldRequest struct {
+	Amount int64 `json:"amount"` // Optional: 0 captures the full hold
+}
+
+// Authorize reserves funds on the caller's account
+func (h *HoldHandler) Authorize(c *fiber.Ctx) error {
+	var req AuthorizeHoldRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid body"})
+	}
+
+	accountID, err := uuid.Parse(req.AccountID)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid account_id"})
+	}
+	destinationID, err := uuid.Parse(req.DestinationID)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid destination_id"})
+	}
+	amount := domain.NewMoney(req.Amount, requestCurrency(req.Currency))
+	if err := domain.ValidateAmount(amount); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	// Only the owner can reserve their own money
+	if !middleware.CanAccess(c, accountID) {
+		return middleware.Forbidden(c)
+	}
+
+	ttl := DefaultHoldTTL
+	if req.ExpiresInSeconds > 0 {
+		ttl = time.Duration(req.ExpiresInSeconds) * time.Second
+	}
+	if ttl > MaxHoldTTL {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Holds cannot last longer than 30 days"})
+	}
+
+	hold, err := h.Repo.AuthorizeHold(c.Context(), accountID, destinationID, amount, req.Description, ttl)
+	if limitErr, ok := asLimitError(err); ok {
+		return limitExceeded(c, limitErr)
+	}
+	if err != nil {
+		slog.Warn("Hold authorization failed", "error", err, "account_id", accountID)
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	slog.Info("🔒 Hold Authorized", "hold_id", hold.ID, "amount", hold.Amount)
+	return c.Status(http.StatusCreated).JSON(hold)
+}
+
+// Get returns a hold to either of its parties
+func (h *HoldHandler) Get(c *fiber.Ctx) error {
+	hold, err := h.loadHold(c)
+	if err != nil || hold == nil {
+		return err
+	}
+	if !middleware.CanAccess(c, hold.AccountID) && !middleware.CanAccess(c, hold.DestinationAccountID) {
+		return middleware.Forbidden(c)
+	}
+	return c.JSON(hold)
+}
+
+// Capture is performed by the merchant receiving the funds
+func (h *HoldHandler) Capture(c *fiber.Ctx) error {
+	var req CaptureHoldRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid body"})
+		}
+	}
+
+	hold, err := h.loadHold(c)
+	if err != nil || hold == nil {
+		return err
+	}
+	if !middleware.CanAccess(c, hold.DestinationAccountID) {
+		return middleware.Forbidden(c)
+	}
+
+	hold, err = h.Repo.CaptureHold(c.Context(), hold.ID, req.Amount)
+	if err != nil {
+		return holdError(c, err)
+	}
+
+	slog.Info("💰 Hold Captured", "hold_id", hold.ID, "captured", hold.CapturedAmount)
+	return c.JSON(hold)
+}
+
+// Void releases the hold. Only the merchant receiving the funds may cancel it: the
+// payer can't pull money back that the merchant relies on, and is protected by expiry.
+func (h *HoldHandler) Void(c *fiber.Ctx) error {
+	hold, err := h.loadHold(c)
+	if err != nil || hold == nil {
+		return err
+	}
+	if !middleware.CanAccess(c, hold.DestinationAccountID) {
+		return middleware.Forbidden(c)
+	}
+
+	hold, err = h.Repo.VoidHold(c.Context(), hold.ID)
+	if err != nil {
+		return holdError(c, err)
+	}
+
+	slog.Info("🔓 Hold Voided", "hold_id", hold.ID)
+	return c.JSON(hold)
+}
+
+// loadHold parses :id and fetches the hold. A nil hold means the response was already written.
+func (h *HoldHandler) loadHold(c *fiber.Ctx) (*storage.Hold, error) {
+	holdID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return nil, c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid Hold ID"})
+	}
+
+	hold, err := h.Repo.GetHold(c.Context(), holdID)
+	if err != nil {
+		return nil, holdError(c, err)
+	}
+	return hold, nil
+}
+
+func holdError(c *fiber.Ctx, err error) error {
+	if limitErr, ok := asLimitError(err); ok {
+		return limitExceeded(c, limitErr)
+	}
+	switch {
+	case errors.Is(err, storage.ErrHoldNotFound):
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, storage.ErrHoldNotCapturable), errors.Is(err, storage.ErrHoldExpired),
+		errors.Is(err, storage.ErrAccountFrozen), errors.Is(err, storage.ErrAccountClosed):
+		return c.Status(http.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, storage.ErrCaptureTooLarge), errors.Is(err, storage.ErrLivemodeMismatch),
+		errors.Is(err, storage.ErrCurrencyMismatch), errors.Is(err, storage.ErrFeeExceedsShare):
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	default:
+		slog.Error("Hold operation failed", "error", err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Hold operation failed"})
+	}
+}
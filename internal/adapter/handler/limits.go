@@ -0,0 +1,29 @@
+package handler
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/ibrahimkeyboad/gopay/internal/core/domain"
+)
+
+// asLimitError reports whether err is a KYC limit breach from the ledger
+func asLimitError(err error) (*domain.LimitError, bool) {
+	var limitErr *domain.LimitError
+	ok := errors.As(err, &limitErr)
+	return limitErr, ok
+}
+
+// limitExceeded answers 422 with the typed limit code, so clients can tell the
+// customer to verify their account instead of retrying
+func limitExceeded(c *fiber.Ctx, limitErr *domain.LimitError) error {
+	slog.Warn("🚫 Transaction limit exceeded", "code", limitErr.Code, "tier", limitErr.Tier, "direction", limitErr.Direction)
+	return c.Status(http.StatusUnprocessableEntity).JSON(fiber.Map{
+		"error": limitErr.Error(),
+		"code":  limitErr.Code,
+		"limit": limitErr,
+	})
+}
@@ -10,7 +10,10 @@ import (
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
 
+	"github.com/ibrahimkeyboad/gopay/internal/adapter/middleware"
 	"github.com/ibrahimkeyboad/gopay/internal/adapter/storage"
+	"github.com/ibrahimkeyboad/gopay/internal/core/domain"
+	"github.com/ibrahimkeyboad/gopay/internal/core/pricing"
 )
 
 type MobileMoneyHandler struct {
@@ -22,6 +25,10 @@ type MobilePayRequest struct {
 	Provider    string `json:"provider"`
 	Amount      int64  `json:"amount"`
 	MerchantID  string `json:"merchant_id"`
+
+	// Optional marketplace split, as for card charges
+	Splits      []SplitDestination `json:"splits"`
+	PlatformFee *SplitShare        `json:"platform_fee"`
 }
 
 func (h *MobileMoneyHandler) InitializePayment(c *fiber.Ctx) error {
@@ -32,36 +39,52 @@ func (h *MobileMoneyHandler) InitializePayment(c *fiber.Ctx) error {
 	}
 
 
-	// 1. SAFETY CHECK: Minimum Transaction Amount
-    // We set the minimum to 500 TZS (which is 50,000 cents)
-    const MinAmount = 500 * 100
-
-		slog.Info("Payment initialization request",
-			"sms_phone", req.PhoneNumber,
-			"provider", req.Provider,
-			"amount", req.Amount,
-			"merchant_id", req.MerchantID,
-		)
-
-		if req.Amount < MinAmount {
-        slog.Warn("❌ Payment rejected: Amount too low", "amount", req.Amount)
-        return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-            "error": "Amount too low. Minimum is 500 TZS. Did you forget to multiply by 100?",
-        })
-    }
+	slog.Info("Payment initialization request",
+		"sms_phone", req.PhoneNumber,
+		"provider", req.Provider,
+		"amount", req.Amount,
+		"merchant_id", req.MerchantID,
+	)
 
+	// 1. SAFETY CHECK: Transaction limits come from the currency registry
+	// Mobile money is always collected in TZS
+	amount := domain.NewMoney(req.Amount, domain.TZS)
+	if err := domain.ValidateAmount(amount); err != nil {
+		slog.Warn("❌ Payment rejected: Amount out of range", "amount", req.Amount, "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
 
 	// Validate Phone Number
 	if len(req.PhoneNumber) < 10 {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid Phone Number"})
 	}
 
+	// Validate Provider (each one has its own float account in the ledger)
+	provider, err := domain.ParseMobileProvider(req.Provider)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
 	// Validate Merchant ID
 	merchantUUID, err := uuid.Parse(req.MerchantID)
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid Merchant UUID"})
 	}
 
+	// A key can only collect payments into its own account
+	if !middleware.CanAccess(c, merchantUUID) {
+		return middleware.Forbidden(c)
+	}
+
+	// Work out the split now so a bad request fails before the USSD push
+	credits, fee, err := buildSplit(merchantUUID, amount, req.Splits, req.PlatformFee)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	livemode := middleware.Livemode(c)
+	pinDelay := ussdPinDelay(livemode)
+
 	// Start the Background Process
 	go func() {
 	// Log Context: We can attach data to the log
@@ -70,21 +93,27 @@ func (h *MobileMoneyHandler) InitializePayment(c *fiber.Ctx) error {
 			slog.String("merchant_id", req.MerchantID),
 			slog.Int64("amount", req.Amount),
 			slog.String("provider", req.Provider),
+			slog.Bool("livemode", livemode),
 		}
 
 		slog.Info("📲 [M-PESA] USSD Push initiated", logAttrs...)
 
 		// Simulate User Delay (waiting for PIN entry)
-		time.Sleep(5 * time.Second)
+		time.Sleep(pinDelay)
 
-		// 80% Success Rate simulation
-		if rand.Float32() < 0.8 {
+		if ussdSucceeded(livemode, req.PhoneNumber) {
 		slog.Info("✅ [M-PESA] User entered PIN. Processing deposit...", logAttrs...)
 
 			// 1. Update Ledger
-			err := h.Repo.Deposit(context.Background(), merchantUUID, req.Amount, "M-Pesa Payment: "+req.PhoneNumber)
+			source := storage.MobileMoneyFloat(provider)
+			receipt, err := h.Repo.DepositSplit(context.Background(), source, pricing.MethodMobileMoney, credits, fee, "M-Pesa Payment: "+req.PhoneNumber)
 			if err != nil {
 	slog.Error("❌ [M-PESA] Ledger Deposit Failed", "error", err, "phone", req.PhoneNumber)
+
+				// The customer has already paid: park the money in suspense so it isn't lost
+				if suspenseErr := h.Repo.BookToSuspense(context.Background(), source, amount, "M-Pesa Payment: "+req.PhoneNumber+" for "+req.MerchantID, livemode); suspenseErr != nil {
+					slog.Error("❌ [M-PESA] Suspense Booking Failed", "error", suspenseErr, "phone", req.PhoneNumber)
+				}
 				return
 			}
 			slog.Info("💰 [M-PESA] Money deposited in DB!", logAttrs...)
@@ -93,13 +122,22 @@ func (h *MobileMoneyHandler) InitializePayment(c *fiber.Ctx) error {
 			webhookPayload := map[string]interface{}{
 				"event": "payment.succeeded",
 				"data": map[string]interface{}{
-					"amount":       req.Amount,
-					"currency":     "TZS",
-					"merchant_id":  req.MerchantID,
-					"phone_number": req.PhoneNumber,
-					"provider":     req.Provider,
-					"status":       "COMPLETED",
-					"timestamp":    time.Now(),
+					"transaction_id": receipt.TransactionID,
+					"amount":         req.Amount,
+					"currency":       "TZS",
+					"merchant_id":    req.MerchantID,
+					"phone_number":   req.PhoneNumber,
+					"provider":       req.Provider,
+					"splits":         splitResults(receipt.Credits),
+					"fee":            fee.Amount,
+					"gross":          receipt.Gross,
+					"processing_fee": receipt.ProcessingFee,
+					"tax":            receipt.Tax,
+					"tax_lines":      receipt.TaxLines,
+					"net":            receipt.Net,
+					"status":         "COMPLETED",
+					"livemode":       livemode,
+					"timestamp":      time.Now(),
 				},
 			}
 
@@ -137,6 +175,7 @@ func (h *MobileMoneyHandler) InitializePayment(c *fiber.Ctx) error {
 					"phone_number": req.PhoneNumber,
 					"provider":     req.Provider,
 					"reason":       "User cancelled or timeout",
+					"livemode":     livemode,
 					"timestamp":    time.Now(),
 				},
 			}
@@ -156,5 +195,24 @@ func (h *MobileMoneyHandler) InitializePayment(c *fiber.Ctx) error {
 		"status":   "pending",
 		"message":  "USSD Push sent. Check your phone.",
 		"provider": req.Provider,
+		"splits":   splitResults(credits),
+		"livemode": livemode,
 	})
-}
\ No newline at end of file
+}
+
+// ussdPinDelay is how long the simulated customer takes to enter their PIN
+func ussdPinDelay(livemode bool) time.Duration {
+	if !livemode {
+		return time.Second
+	}
+	return 5 * time.Second
+}
+
+// ussdSucceeded simulates the customer's answer to the USSD push: 80% approve in live mode.
+// Test keys never reach a provider, so the phone number decides (see domain.TestPaymentSucceeds).
+func ussdSucceeded(livemode bool, phone string) bool {
+	if !livemode {
+		return domain.TestPaymentSucceeds(phone)
+	}
+	return rand.Float32() < 0.8
+}
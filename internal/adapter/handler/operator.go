@@ -0,0 +1,100 @@
+package handler
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/mail"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"github.com/ibrahimkeyboad/gopay/internal/adapter/storage"
+	"github.com/ibrahimkeyboad/gopay/internal/core/security"
+)
+
+// OperatorHandler manages back-office operators. Its routes only accept the ADMIN_API_KEY.
+type OperatorHandler struct {
+	Repo *storage.OperatorRepository
+}
+
+type CreateOperatorRequest struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+// Create adds an operator and shows their gp_admin_ key once
+func (h *OperatorHandler) Create(c *fiber.Ctx) error {
+	var req CreateOperatorRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid body"})
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Name is required"})
+	}
+	if _, err := mail.ParseAddress(req.Email); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "A valid email is required"})
+	}
+
+	realKey, keyHash, err := security.GenerateAPIKey(security.PrefixAdmin)
+	if err != nil {
+		slog.Error("Crypto error generating key", "error", err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Crypto error"})
+	}
+
+	operator, err := h.Repo.CreateOperator(c.Context(), storage.Operator{
+		Name:  req.Name,
+		Email: strings.ToLower(req.Email),
+		Last4: security.Last4(realKey),
+		Hash:  keyHash,
+	})
+	if err != nil {
+		return operatorError(c, err)
+	}
+
+	slog.Info("🛂 Operator Created", "operator_id", operator.ID, "email", operator.Email)
+	return c.Status(http.StatusCreated).JSON(fiber.Map{
+		"operator": operator,
+		"api_key":  realKey,
+		"warning":  "Save this now! We won't show it again.",
+	})
+}
+
+// List returns every operator
+func (h *OperatorHandler) List(c *fiber.Ctx) error {
+	operators, err := h.Repo.ListOperators(c.Context())
+	if err != nil {
+		return operatorError(c, err)
+	}
+	return c.JSON(fiber.Map{"operators": operators})
+}
+
+// Revoke stops an operator's key from working
+func (h *OperatorHandler) Revoke(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid Operator ID"})
+	}
+
+	operator, err := h.Repo.RevokeOperator(c.Context(), id)
+	if err != nil {
+		return operatorError(c, err)
+	}
+
+	slog.Info("🛂 Operator Revoked", "operator_id", operator.ID)
+	return c.JSON(operator)
+}
+
+func operatorError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, storage.ErrOperatorNotFound):
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, storage.ErrOperatorExists):
+		return c.Status(http.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	default:
+		slog.Error("Operator operation failed", "error", err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Operator operation failed"})
+	}
+}
@@ -3,6 +3,7 @@ package handler
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"log/slog"
 	"net/http"
 	"os" // Added to read environment variables
@@ -11,6 +12,7 @@ import (
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
 
+	"github.com/ibrahimkeyboad/gopay/internal/adapter/middleware"
 	"github.com/ibrahimkeyboad/gopay/internal/adapter/storage"
 	"github.com/ibrahimkeyboad/gopay/internal/core/domain"
 )
@@ -23,8 +25,18 @@ type ChargeRequest struct {
 	CardNumber string `json:"card_number"`
 	Expiry     string `json:"expiry"` // MM/YY
 	CVC        string `json:"cvc"`
-	Amount     int64  `json:"amount"` // Cents
+	Amount     int64  `json:"amount"`   // Cents
+	Currency   string `json:"currency"` // Defaults to TZS
 	MerchantID string `json:"merchant_id"`
+
+	// Optional marketplace split: parts of the payment go to other accounts and the
+	// platform, the rest to merchant_id. Only a secret key for merchant_id can set these.
+	Splits      []SplitDestination `json:"splits"`
+	PlatformFee *SplitShare        `json:"platform_fee"`
+
+	// Defaults to true. false only authorizes the card: the merchant captures
+	// (or voids) the returned hold later through /v1/holds.
+	Capture *bool `json:"capture"`
 }
 
 func (h *PaymentHandler) MakeCharge(c *fiber.Ctx) error {
@@ -35,14 +47,13 @@ func (h *PaymentHandler) MakeCharge(c *fiber.Ctx) error {
 	}
 
 	// --- SECURITY CHECK START ---
-	// Minimum 500 TZS (50,000 cents)
-	const MinAmount = 500 * 100
+	// Min/max per currency come from the currency registry
+	currency := requestCurrency(req.Currency)
+	amount := domain.NewMoney(req.Amount, currency)
 
-	if req.Amount < MinAmount {
-		slog.Warn("❌ Card Payment rejected: Amount too low", "amount", req.Amount)
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Amount too low. Minimum is 500 TZS. Did you forget to multiply by 100?",
-		})
+	if err := domain.ValidateAmount(amount); err != nil {
+		slog.Warn("❌ Card Payment rejected: Amount out of range", "amount", req.Amount, "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
 	}
 	// --- SECURITY CHECK END ---
 
@@ -65,8 +76,84 @@ func (h *PaymentHandler) MakeCharge(c *fiber.Ctx) error {
 		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid Merchant ID"})
 	}
 
-	// Call Deposit
-	err = h.Repo.Deposit(c.Context(), merchantUUID, req.Amount, "Card Payment: "+string(brand))
+	// Anyone may pay a merchant, but only the merchant decides who else gets a share.
+	// A keyed charge (see middleware.Anonymous) must be for an account the key can act for.
+	_, keyed := middleware.MerchantID(c)
+	if keyed && !middleware.CanAccess(c, merchantUUID) {
+		return middleware.Forbidden(c)
+	}
+	if !keyed && (len(req.Splits) > 0 || req.PlatformFee != nil) {
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "Split charges need the merchant's secret API key"})
+	}
+
+	// Test accounts never reach a card network: the test card decides the outcome
+	livemode, err := h.Repo.AccountLivemode(c.Context(), merchantUUID)
+	if errors.Is(err, storage.ErrAccountNotFound) {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		slog.Error("❌ Payment Processing Failed", "error", err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Payment Processing Failed"})
+	}
+	decline, isTestCard := domain.TestCard(req.CardNumber)
+	if livemode && isTestCard {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Test cards cannot be charged in live mode"})
+	}
+	if !livemode && decline != "" {
+		slog.Info("🧪 Test Card Declined", "merchant_id", merchantUUID, "decline_code", decline)
+		return c.Status(http.StatusPaymentRequired).JSON(fiber.Map{
+			"status":       "declined",
+			"error":        "Payment Declined",
+			"decline_code": decline,
+			"livemode":     false,
+		})
+	}
+
+	capture := req.Capture == nil || *req.Capture
+	if !capture && (len(req.Splits) > 0 || req.PlatformFee != nil) {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Split charges must be captured immediately"})
+	}
+
+	credits, fee, err := buildSplit(merchantUUID, amount, req.Splits, req.PlatformFee)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	// The approved card is authorized first: its money waits in HOLDS until it is captured
+	hold, err := h.Repo.AuthorizeCardHold(c.Context(), merchantUUID, amount, string(brand), DefaultHoldTTL)
+	if errors.Is(err, storage.ErrCurrencyMismatch) {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		slog.Error("❌ Payment Processing Failed", "error", err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Payment Processing Failed"})
+	}
+	if !capture {
+		slog.Info("🔒 Card Authorized", "hold_id", hold.ID, "merchant_id", merchantUUID, "amount", hold.Amount)
+		return c.Status(http.StatusCreated).JSON(fiber.Map{
+			"status":   "authorized",
+			"message":  "Payment Authorized",
+			"hold":     hold,
+			"brand":    brand,
+			"livemode": livemode,
+		})
+	}
+
+	// Capture books the payment (its split and our processing fee) in one ledger transaction
+	_, receipt, err := h.Repo.CaptureCardHold(c.Context(), hold.ID, credits, fee)
+	if err != nil {
+		// Nothing was captured, so give the authorization back rather than leave it to expire
+		if _, voidErr := h.Repo.VoidHold(c.Context(), hold.ID); voidErr != nil {
+			slog.Error("❌ Failed to void card hold", "error", voidErr, "hold_id", hold.ID)
+		}
+	}
+	if limitErr, ok := asLimitError(err); ok {
+		return limitExceeded(c, limitErr)
+	}
+	if errors.Is(err, storage.ErrCurrencyMismatch) || errors.Is(err, storage.ErrSystemAccount) || errors.Is(err, storage.ErrAccountClosed) ||
+		errors.Is(err, storage.ErrFeeExceedsShare) || errors.Is(err, storage.ErrLivemodeMismatch) {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
 	if err != nil {
 		slog.Error("❌ Payment Processing Failed", "error", err)
 		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Payment Processing Failed"})
@@ -77,12 +164,21 @@ func (h *PaymentHandler) MakeCharge(c *fiber.Ctx) error {
 		webhookPayload := map[string]interface{}{
 			"event": "payment.succeeded",
 			"data": map[string]interface{}{
-				"amount":      req.Amount,
-				"currency":    "TZS",
-				"merchant_id": req.MerchantID,
-				"card_brand":  brand,
-				"status":      "COMPLETED",
-				"timestamp":   time.Now(),
+				"transaction_id": receipt.TransactionID,
+				"amount":         req.Amount,
+				"currency":       currency,
+				"merchant_id":    req.MerchantID,
+				"card_brand":     brand,
+				"splits":         splitResults(receipt.Credits),
+				"fee":            fee.Amount,
+				"gross":          receipt.Gross,
+				"processing_fee": receipt.ProcessingFee,
+				"tax":            receipt.Tax,
+				"tax_lines":      receipt.TaxLines,
+				"net":            receipt.Net,
+				"status":         "COMPLETED",
+				"livemode":       livemode,
+				"timestamp":      time.Now(),
 			},
 		}
 
@@ -115,7 +211,18 @@ func (h *PaymentHandler) MakeCharge(c *fiber.Ctx) error {
 	return c.JSON(fiber.Map{
 		"status":         "success",
 		"message":        "Payment Approved",
+		"transaction_id": receipt.TransactionID,
+		"hold_id":        hold.ID,
 		"brand":          brand,
 		"amount_charged": req.Amount,
+		"currency":       currency,
+		"splits":         splitResults(receipt.Credits),
+		"platform_fee":   fee.Amount,
+		"gross":          receipt.Gross,
+		"processing_fee": receipt.ProcessingFee,
+		"tax":            receipt.Tax,
+		"tax_lines":      receipt.TaxLines,
+		"net":            receipt.Net,
+		"livemode":       livemode,
 	})
 }
\ No newline at end of file
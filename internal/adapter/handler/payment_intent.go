@@ -0,0 +1,282 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"github.com/ibrahimkeyboad/gopay/internal/adapter/middleware"
+	"github.com/ibrahimkeyboad/gopay/internal/adapter/storage"
+	"github.com/ibrahimkeyboad/gopay/internal/core/domain"
+	"github.com/ibrahimkeyboad/gopay/internal/core/pricing"
+	"github.com/ibrahimkeyboad/gopay/internal/core/security"
+)
+
+// Payment methods a checkout can collect with
+const (
+	MethodCard        = "CARD"
+	MethodMobileMoney = "MOBILE_MONEY"
+)
+
+// clientSecretPrefix marks the secret a checkout uses to poll its own intent
+const clientSecretPrefix = "pi_secret_"
+
+// PaymentIntentHandler serves checkouts authenticated with a publishable key.
+// Payments always go to the key's own account: the merchant is never taken from the body.
+// A publishable key only authorizes cards; capturing needs the merchant's secret key (Capture).
+type PaymentIntentHandler struct {
+	Repo *storage.LedgerRepository
+}
+
+type PaymentIntentRequest struct {
+	Amount   int64  `json:"amount"`   // Cents
+	Currency string `json:"currency"` // Defaults to TZS
+	Method   string `json:"method"`   // CARD or MOBILE_MONEY
+
+	Card *struct {
+		Number string `json:"number"`
+		Expiry string `json:"expiry"` // MM/YY
+		CVC    string `json:"cvc"`
+	} `json:"card"`
+
+	PhoneNumber string `json:"phone_number"`
+	Provider    string `json:"provider"`
+}
+
+// Create starts a payment. Cards are authorized before it returns and wait in REQUIRES_CAPTURE
+// for the merchant's Capture; mobile money stays PROCESSING until the customer answers the
+// USSD push, so the checkout polls Get.
+func (h *PaymentIntentHandler) Create(c *fiber.Ctx) error {
+	var req PaymentIntentRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid body"})
+	}
+
+	merchantID, ok := middleware.MerchantID(c)
+	if !ok {
+		return middleware.Forbidden(c)
+	}
+	livemode := middleware.Livemode(c)
+
+	amount := domain.NewMoney(req.Amount, requestCurrency(req.Currency))
+	if err := domain.ValidateAmount(amount); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	intent := storage.PaymentIntent{
+		AccountID: merchantID,
+		Amount:    amount.Amount,
+		Currency:  string(amount.Currency),
+		Livemode:  livemode,
+	}
+
+	var brand domain.CardType
+	var decline string
+	var provider domain.MobileProvider
+	switch req.Method {
+	case MethodCard:
+		if req.Card == nil {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "card is required"})
+		}
+		var valid bool
+		valid, brand = domain.ValidateCard(req.Card.Number)
+		if !valid {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid Card. We only accept Visa and Mastercard."})
+		}
+		if len(req.Card.CVC) < 3 || len(req.Card.Expiry) != 5 {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid CVC or Expiry"})
+		}
+		var isTestCard bool
+		decline, isTestCard = domain.TestCard(req.Card.Number)
+		if livemode && isTestCard {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Test cards cannot be charged in live mode"})
+		}
+		cardBrand := string(brand)
+		intent.CardBrand = &cardBrand
+
+	case MethodMobileMoney:
+		// Mobile money is always collected in TZS
+		if amount.Currency != domain.TZS {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Mobile money payments must be in TZS"})
+		}
+		if len(req.PhoneNumber) < 10 {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid Phone Number"})
+		}
+		var err error
+		provider, err = domain.ParseMobileProvider(req.Provider)
+		if err != nil {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		providerName := string(provider)
+		intent.PhoneNumber = &req.PhoneNumber
+		intent.Provider = &providerName
+
+	default:
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "method must be CARD or MOBILE_MONEY"})
+	}
+	intent.Method = req.Method
+
+	clientSecret, secretHash, err := security.GenerateAPIKey(clientSecretPrefix)
+	if err != nil {
+		slog.Error("Failed to generate client secret", "error", err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create payment intent"})
+	}
+	intent.ClientSecretHash = secretHash
+
+	created, err := h.Repo.CreatePaymentIntent(c.Context(), intent)
+	if err != nil {
+		return paymentIntentError(c, err)
+	}
+	slog.Info("🧾 Payment intent created", "payment_intent_id", created.ID, "merchant_id", merchantID,
+		"method", created.Method, "amount", created.Amount, "livemode", livemode)
+
+	if req.Method == MethodCard {
+		if !livemode && decline != "" {
+			slog.Info("🧪 Test Card Declined", "payment_intent_id", created.ID, "decline_code", decline)
+			created, err = h.Repo.FailPaymentIntent(c.Context(), created.ID, decline)
+		} else {
+			created, err = h.authorize(c.Context(), created.ID, string(brand))
+		}
+		if err != nil {
+			return paymentIntentError(c, err)
+		}
+	} else {
+		credits := []storage.Credit{{AccountID: merchantID, Amount: amount}}
+		go h.collectMobileMoney(created.ID, provider, req.PhoneNumber, amount, credits, domain.NewMoney(0, amount.Currency), livemode)
+	}
+
+	return c.Status(http.StatusCreated).JSON(fiber.Map{
+		"payment_intent": created,
+		"client_secret":  clientSecret,
+	})
+}
+
+// Get lets a checkout poll its intent. The client_secret returned by Create is required,
+// so one checkout can't look up another's payments with the same publishable key.
+func (h *PaymentIntentHandler) Get(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid Payment Intent ID"})
+	}
+
+	intent, err := h.Repo.GetPaymentIntent(c.Context(), id)
+	if err != nil {
+		return paymentIntentError(c, err)
+	}
+
+	// Someone else's intent looks the same as a missing one
+	merchantID, ok := middleware.MerchantID(c)
+	if !ok || intent.AccountID != merchantID || !security.ValidateKey(c.Query("client_secret"), intent.ClientSecretHash) {
+		return paymentIntentError(c, storage.ErrPaymentIntentNotFound)
+	}
+	return c.JSON(intent)
+}
+
+// Capture collects an authorized card intent (secret key only). The card's hold is captured
+// for the intent's merchant, which succeeds the intent in the same ledger transaction.
+// Voiding the hold through /v1/holds fails the intent instead.
+func (h *PaymentIntentHandler) Capture(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid Payment Intent ID"})
+	}
+
+	intent, err := h.Repo.GetPaymentIntent(c.Context(), id)
+	if err != nil {
+		return paymentIntentError(c, err)
+	}
+	// Someone else's intent looks the same as a missing one
+	if !middleware.CanAccess(c, intent.AccountID) {
+		return paymentIntentError(c, storage.ErrPaymentIntentNotFound)
+	}
+	if intent.Status != storage.IntentRequiresCapture || intent.HoldID == nil {
+		return c.Status(http.StatusConflict).JSON(fiber.Map{"error": "Payment intent is not awaiting capture"})
+	}
+
+	amount := domain.NewMoney(intent.Amount, domain.Currency(intent.Currency))
+	credits := []storage.Credit{{AccountID: intent.AccountID, Amount: amount}}
+	if _, _, err := h.Repo.CaptureCardHold(c.Context(), *intent.HoldID, credits, domain.NewMoney(0, amount.Currency)); err != nil {
+		return holdError(c, err)
+	}
+
+	intent, err = h.Repo.GetPaymentIntent(c.Context(), id)
+	if err != nil {
+		return paymentIntentError(c, err)
+	}
+	slog.Info("💰 Payment intent captured", "payment_intent_id", intent.ID, "transaction_id", intent.TransactionID)
+	return c.JSON(intent)
+}
+
+// authorize puts an approved card on hold for the intent. An authorization the ledger
+// refuses fails the intent instead, as a declined card would.
+func (h *PaymentIntentHandler) authorize(ctx context.Context, id uuid.UUID, brand string) (*storage.PaymentIntent, error) {
+	intent, err := h.Repo.AuthorizePaymentIntent(ctx, id, brand, DefaultHoldTTL)
+	if err != nil && ledgerRefused(err) {
+		return h.Repo.FailPaymentIntent(ctx, id, err.Error())
+	}
+	return intent, err
+}
+
+// ledgerRefused reports whether a booking was refused for a reason the payer can be told about
+func ledgerRefused(err error) bool {
+	_, isLimit := asLimitError(err)
+	return isLimit || errors.Is(err, storage.ErrAccountClosed) || errors.Is(err, storage.ErrCurrencyMismatch) ||
+		errors.Is(err, storage.ErrLivemodeMismatch) || errors.Is(err, storage.ErrFeeExceedsShare)
+}
+
+// collectMobileMoney waits for the customer to answer the USSD push, then finishes the intent
+func (h *PaymentIntentHandler) collectMobileMoney(id uuid.UUID, provider domain.MobileProvider, phone string, amount domain.Money,
+	credits []storage.Credit, fee domain.Money, livemode bool) {
+	ctx := context.Background()
+	logAttrs := []any{
+		slog.String("payment_intent_id", id.String()),
+		slog.String("phone", phone),
+		slog.String("provider", string(provider)),
+		slog.Bool("livemode", livemode),
+	}
+	slog.Info("📲 [M-PESA] USSD Push initiated", logAttrs...)
+
+	time.Sleep(ussdPinDelay(livemode))
+
+	if !ussdSucceeded(livemode, phone) {
+		slog.Warn("⚠️ [M-PESA] User cancelled or timed out", logAttrs...)
+		if _, err := h.Repo.FailPaymentIntent(ctx, id, "User cancelled or timeout"); err != nil {
+			slog.Error("❌ [M-PESA] Failed to update payment intent", "error", err, "payment_intent_id", id)
+		}
+		return
+	}
+
+	// Unlike a card, the customer has paid by now, so any booking failure goes to suspense
+	source := storage.MobileMoneyFloat(provider)
+	_, _, err := h.Repo.SettlePaymentIntent(ctx, id, source, pricing.MethodMobileMoney, credits, fee, "M-Pesa Payment: "+phone)
+	if err != nil {
+		slog.Error("❌ [M-PESA] Ledger Deposit Failed", "error", err, "payment_intent_id", id)
+
+		// The customer has already paid: park the money in suspense so it isn't lost
+		if suspenseErr := h.Repo.BookToSuspense(ctx, source, amount, "Payment intent "+id.String()+": "+phone, livemode); suspenseErr != nil {
+			slog.Error("❌ [M-PESA] Suspense Booking Failed", "error", suspenseErr, "payment_intent_id", id)
+		}
+		if _, err := h.Repo.FailPaymentIntent(ctx, id, "Payment could not be booked"); err != nil {
+			slog.Error("❌ [M-PESA] Failed to update payment intent", "error", err, "payment_intent_id", id)
+		}
+		return
+	}
+	slog.Info("💰 [M-PESA] Payment intent succeeded", logAttrs...)
+}
+
+func paymentIntentError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, storage.ErrPaymentIntentNotFound):
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, storage.ErrPaymentIntentFinished):
+		return c.Status(http.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	default:
+		slog.Error("Payment intent operation failed", "error", err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Payment intent operation failed"})
+	}
+}
@@ -0,0 +1,182 @@
+package handler
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"github.com/ibrahimkeyboad/gopay/internal/adapter/middleware"
+	"github.com/ibrahimkeyboad/gopay/internal/adapter/storage"
+	"github.com/ibrahimkeyboad/gopay/internal/core/domain"
+	"github.com/ibrahimkeyboad/gopay/internal/core/schedule"
+)
+
+type ScheduledTransferHandler struct {
+	Repo *storage.LedgerRepository
+}
+
+// ScheduledTransferRequest sets up a future-dated (frequency ONCE) or recurring transfer.
+// A recurring transfer stops at end_at, after count occurrences, or when cancelled.
+type ScheduledTransferRequest struct {
+	FromID      string     `json:"from_id"`
+	ToID        string     `json:"to_id"`
+	Amount      int64      `json:"amount"`
+	Currency    string     `json:"currency"` // Defaults to TZS
+	Description string     `json:"description"`
+	Frequency   string     `json:"frequency"` // ONCE (default), DAILY, WEEKLY or MONTHLY
+	StartAt     *time.Time `json:"start_at"`  // RFC 3339, defaults to now
+	EndAt       *time.Time `json:"end_at"`
+	Count       *int       `json:"count"`
+}
+
+// Create saves a scheduled transfer out of an account the caller owns
+func (h *ScheduledTransferHandler) Create(c *fiber.Ctx) error {
+	var req ScheduledTransferRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid body"})
+	}
+
+	fromUUID, err := uuid.Parse(req.FromID)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid from_id"})
+	}
+	toUUID, err := uuid.Parse(req.ToID)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid to_id"})
+	}
+	if fromUUID == toUUID {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "from_id and to_id must be different accounts"})
+	}
+
+	// Money can only leave an account the caller owns
+	if !middleware.CanAccess(c, fromUUID) {
+		return middleware.Forbidden(c)
+	}
+
+	amount := domain.NewMoney(req.Amount, requestCurrency(req.Currency))
+	if err := domain.ValidateAmount(amount); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	frequency, err := schedule.ParseFrequency(req.Frequency)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	now := time.Now()
+	startAt := now
+	if req.StartAt != nil {
+		startAt = *req.StartAt
+	}
+	switch {
+	case startAt.Before(now.Add(-time.Minute)):
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "start_at cannot be in the past"})
+	case frequency == schedule.Once && (req.EndAt != nil || req.Count != nil):
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "end_at and count only apply to recurring transfers"})
+	case req.EndAt != nil && req.EndAt.Before(startAt):
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "end_at must be after start_at"})
+	case req.Count != nil && *req.Count < 1:
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "count must be at least 1"})
+	}
+
+	description := req.Description
+	if description == "" {
+		description = "Scheduled Transfer"
+	}
+
+	s, err := h.Repo.CreateScheduledTransfer(c.Context(), storage.ScheduledTransfer{
+		FromAccountID: fromUUID,
+		ToAccountID:   toUUID,
+		Amount:        amount.Amount,
+		Currency:      string(amount.Currency),
+		Description:   description,
+		Frequency:     frequency,
+		StartAt:       startAt,
+		EndAt:         req.EndAt,
+		MaxRuns:       req.Count,
+	})
+	if err != nil {
+		return scheduledTransferError(c, err)
+	}
+
+	slog.Info("🗓️ Scheduled Transfer Created", "id", s.ID, "from", s.FromAccountID, "frequency", s.Frequency, "start_at", s.StartAt)
+	return c.Status(http.StatusCreated).JSON(s)
+}
+
+// Get returns a scheduled transfer and its runs
+func (h *ScheduledTransferHandler) Get(c *fiber.Ctx) error {
+	s, err := h.load(c)
+	if err != nil || s == nil {
+		return err
+	}
+	if !middleware.CanAccess(c, s.FromAccountID) {
+		return middleware.Forbidden(c)
+	}
+	return c.JSON(s)
+}
+
+// List returns the scheduled transfers paying out of :id
+func (h *ScheduledTransferHandler) List(c *fiber.Ctx) error {
+	// Ownership is already checked by middleware.AccountOwner on the route.
+	accountID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid Account ID"})
+	}
+
+	schedules, err := h.Repo.ListScheduledTransfers(c.Context(), accountID)
+	if err != nil {
+		return scheduledTransferError(c, err)
+	}
+	return c.JSON(fiber.Map{"scheduled_transfers": schedules})
+}
+
+// Cancel stops a scheduled transfer. Runs already made are not reversed.
+func (h *ScheduledTransferHandler) Cancel(c *fiber.Ctx) error {
+	s, err := h.load(c)
+	if err != nil || s == nil {
+		return err
+	}
+	if !middleware.CanAccess(c, s.FromAccountID) {
+		return middleware.Forbidden(c)
+	}
+
+	s, err = h.Repo.CancelScheduledTransfer(c.Context(), s.ID)
+	if err != nil {
+		return scheduledTransferError(c, err)
+	}
+
+	slog.Info("🗓️ Scheduled Transfer Cancelled", "id", s.ID)
+	return c.JSON(s)
+}
+
+// load parses :id and fetches the schedule. A nil schedule means the response was already written.
+func (h *ScheduledTransferHandler) load(c *fiber.Ctx) (*storage.ScheduledTransfer, error) {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return nil, c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid Scheduled Transfer ID"})
+	}
+
+	s, err := h.Repo.GetScheduledTransfer(c.Context(), id)
+	if err != nil {
+		return nil, scheduledTransferError(c, err)
+	}
+	return s, nil
+}
+
+func scheduledTransferError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, storage.ErrScheduledTransferNotFound), errors.Is(err, storage.ErrAccountNotFound):
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, storage.ErrScheduleNotActive), errors.Is(err, storage.ErrAccountClosed):
+		return c.Status(http.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, storage.ErrCurrencyMismatch), errors.Is(err, storage.ErrSystemAccount), errors.Is(err, storage.ErrLivemodeMismatch):
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	default:
+		slog.Error("Scheduled transfer operation failed", "error", err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Scheduled transfer operation failed"})
+	}
+}
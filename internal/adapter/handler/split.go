@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/google/uuid"
+
+	"github.com/ibrahimkeyboad/gopay/internal/adapter/storage"
+	"github.com/ibrahimkeyboad/gopay/internal/core/domain"
+)
+
+// SplitShare is either a fixed amount (minor units) or a percentage of the payment
+type SplitShare struct {
+	Amount     int64   `json:"amount"`
+	Percentage float64 `json:"percentage"` // e.g. 12.5 for 12.5%, up to two decimals
+}
+
+// SplitDestination sends part of a payment to another account
+type SplitDestination struct {
+	AccountID string `json:"account_id"`
+	SplitShare
+}
+
+// rule converts a share to a domain.SplitRule, rejecting percentages finer than 0.01%
+func (s SplitShare) rule() (domain.SplitRule, error) {
+	if s.Percentage == 0 {
+		return domain.SplitRule{Amount: s.Amount}, nil
+	}
+	bps := math.Round(s.Percentage * 100)
+	if s.Percentage < 0 || s.Percentage > 100 || math.Abs(bps-s.Percentage*100) > 1e-6 {
+		return domain.SplitRule{}, fmt.Errorf("percentage must be between 0 and 100 with at most two decimals")
+	}
+	return domain.SplitRule{Amount: s.Amount, Bps: int64(bps)}, nil
+}
+
+// buildSplit divides a payment between the split destinations and the platform fee.
+// Whatever is left goes to the merchant, so every minor unit is accounted for.
+func buildSplit(merchantID uuid.UUID, total domain.Money, destinations []SplitDestination, fee *SplitShare) ([]storage.Credit, domain.Money, error) {
+	rules := make([]domain.SplitRule, 0, len(destinations)+1)
+	ids := make([]uuid.UUID, 0, len(destinations))
+	for _, d := range destinations {
+		id, err := uuid.Parse(d.AccountID)
+		if err != nil {
+			return nil, domain.Money{}, fmt.Errorf("invalid split account_id %q", d.AccountID)
+		}
+		rule, err := d.rule()
+		if err != nil {
+			return nil, domain.Money{}, err
+		}
+		ids = append(ids, id)
+		rules = append(rules, rule)
+	}
+
+	var feeRule domain.SplitRule
+	if fee != nil {
+		var err error
+		if feeRule, err = fee.rule(); err != nil {
+			return nil, domain.Money{}, err
+		}
+	}
+	rules = append(rules, feeRule)
+
+	parts, remainder, err := domain.Split(total, rules)
+	if err != nil {
+		return nil, domain.Money{}, err
+	}
+
+	credits := []storage.Credit{{AccountID: merchantID, Amount: remainder}}
+	for i, id := range ids {
+		credits = append(credits, storage.Credit{AccountID: id, Amount: parts[i]})
+	}
+	return credits, parts[len(parts)-1], nil
+}
+
+// SplitResult is how a split is reported back to clients and webhooks
+type SplitResult struct {
+	AccountID uuid.UUID       `json:"account_id"`
+	Amount    int64           `json:"amount"`
+	Currency  domain.Currency `json:"currency"`
+}
+
+func splitResults(credits []storage.Credit) []SplitResult {
+	results := make([]SplitResult, 0, len(credits))
+	for _, c := range credits {
+		results = append(results, SplitResult{AccountID: c.AccountID, Amount: c.Amount.Amount, Currency: c.Amount.Currency})
+	}
+	return results
+}
@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"github.com/ibrahimkeyboad/gopay/internal/adapter/storage"
+	"github.com/ibrahimkeyboad/gopay/internal/core/statement"
+)
+
+type StatementHandler struct {
+	Repo *storage.LedgerRepository
+}
+
+// GetStatement returns ?from= to ?to= (default now) as JSON, CSV or plain text (?format=json|csv|txt)
+func (h *StatementHandler) GetStatement(c *fiber.Ctx) error {
+	// Ownership is already checked by middleware.AccountOwner on the route.
+	accountID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid Account ID"})
+	}
+
+	format, err := statement.ParseFormat(c.Query("format"))
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	if c.Query("from") == "" {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "from is required"})
+	}
+	from, err := statement.ParseTime(c.Query("from"))
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	to := time.Now()
+	if v := c.Query("to"); v != "" {
+		if to, err = statement.ParseTime(v); err != nil {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+	}
+	if err := statement.ValidateRange(from, to); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	s, err := h.Repo.GetStatement(c.Context(), accountID, from, to)
+	if errors.Is(err, storage.ErrAccountNotFound) {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		slog.Error("Failed to build statement", "error", err, "account_id", accountID)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Could not build statement"})
+	}
+
+	if format == statement.FormatJSON {
+		return c.JSON(s)
+	}
+
+	var buf bytes.Buffer
+	contentType := "text/plain; charset=utf-8"
+	if format == statement.FormatCSV {
+		contentType = "text/csv; charset=utf-8"
+		err = statement.WriteCSV(&buf, s)
+	} else {
+		err = statement.WriteText(&buf, s)
+	}
+	if err != nil {
+		slog.Error("Failed to render statement", "error", err, "account_id", accountID, "format", format)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Could not render statement"})
+	}
+
+	c.Set(fiber.HeaderContentType, contentType)
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="statement-%s-%s.%s"`,
+		accountID, from.UTC().Format("20060102"), format))
+	return c.Send(buf.Bytes())
+}
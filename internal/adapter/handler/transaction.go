@@ -1,9 +1,19 @@
 package handler
 
 import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
+
+	"github.com/ibrahimkeyboad/gopay/internal/adapter/middleware"
 	"github.com/ibrahimkeyboad/gopay/internal/adapter/storage"
+	"github.com/ibrahimkeyboad/gopay/internal/core/domain"
 )
 
 type TransactionHandler struct {
@@ -11,68 +21,296 @@ type TransactionHandler struct {
 }
 
 // Request Models
-type DepositRequest struct {
-	AccountID string `json:"account_id"`
-	Amount    int64  `json:"amount"` // Cents!
+type TransferRequest struct {
+	FromID   string `json:"from_id"`
+	ToID     string `json:"to_id"`
+	Amount   int64  `json:"amount"`
+	Currency string `json:"currency"` // Defaults to TZS
+	QuoteID  string `json:"quote_id"` // Set to convert between currencies with a locked FX quote
 }
 
-type TransferRequest struct {
-	FromID string `json:"from_id"`
-	ToID   string `json:"to_id"`
-	Amount int64  `json:"amount"`
+// requestCurrency falls back to TZS for clients written before multi-currency support.
+// Unknown codes are passed through so domain.ValidateAmount can reject them.
+func requestCurrency(code string) domain.Currency {
+	if code == "" {
+		return domain.TZS
+	}
+	if info, err := domain.LookupCurrency(code); err == nil {
+		return info.Code
+	}
+	return domain.Currency(code)
 }
 
-// Deposit API
-func (h *TransactionHandler) Deposit(c *fiber.Ctx) error {
-	var req DepositRequest
+// Transfer API
+func (h *TransactionHandler) Transfer(c *fiber.Ctx) error {
+	var req TransferRequest
 	if err := c.BodyParser(&req); err != nil {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid body"})
 	}
 
-	accUUID, _ := uuid.Parse(req.AccountID)
-	err := h.Repo.Deposit(c.Context(), accUUID, req.Amount, "Manual Deposit")
+	fromUUID, err := uuid.Parse(req.FromID)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid from_id"})
+	}
+	toUUID, err := uuid.Parse(req.ToID)
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid to_id"})
+	}
+
+	// Money can only leave an account the caller owns
+	if !middleware.CanAccess(c, fromUUID) {
+		return middleware.Forbidden(c)
 	}
 
-	return c.JSON(fiber.Map{"status": "success", "message": "Money Deposited!"})
+	// An explicit conversion: the quote fixes both amounts
+	if req.QuoteID != "" {
+		quoteUUID, err := uuid.Parse(req.QuoteID)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "Invalid quote_id"})
+		}
+		return bookConversion(c, h.Repo, quoteUUID, fromUUID, toUUID)
+	}
+
+	amount := domain.NewMoney(req.Amount, requestCurrency(req.Currency))
+	if err := domain.ValidateAmount(amount); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	err = h.Repo.Transfer(c.Context(), fromUUID, toUUID, amount)
+	if limitErr, ok := asLimitError(err); ok {
+		return limitExceeded(c, limitErr)
+	}
+	if errors.Is(err, storage.ErrAccountFrozen) || errors.Is(err, storage.ErrAccountClosed) {
+		return c.Status(409).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(fiber.Map{"status": "success", "message": "Transfer Complete!"})
 }
 
-// Transfer API
-func (h *TransactionHandler) Transfer(c *fiber.Ctx) error {
-	var req TransferRequest
+type PlatformTransferRequest struct {
+	FromID      string `json:"from_id"`
+	ToID        string `json:"to_id"`
+	Amount      int64  `json:"amount"`
+	Currency    string `json:"currency"` // Defaults to TZS
+	Description string `json:"description"`
+}
+
+// PlatformTransfer moves money between a platform and one of its sub-accounts
+func (h *TransactionHandler) PlatformTransfer(c *fiber.Ctx) error {
+	var req PlatformTransferRequest
 	if err := c.BodyParser(&req); err != nil {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid body"})
 	}
 
-	fromUUID, _ := uuid.Parse(req.FromID)
-	toUUID, _ := uuid.Parse(req.ToID)
+	fromUUID, err := uuid.Parse(req.FromID)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid from_id"})
+	}
+	toUUID, err := uuid.Parse(req.ToID)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid to_id"})
+	}
+
+	// A platform key can move money out of itself or any of its sub-accounts
+	if !middleware.CanAccess(c, fromUUID) {
+		return middleware.Forbidden(c)
+	}
+
+	amount := domain.NewMoney(req.Amount, requestCurrency(req.Currency))
+	if err := domain.ValidateAmount(amount); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
 
-	err := h.Repo.Transfer(c.Context(), fromUUID, toUUID, req.Amount)
+	txn, err := h.Repo.PlatformTransfer(c.Context(), fromUUID, toUUID, amount, req.Description)
+	if limitErr, ok := asLimitError(err); ok {
+		return limitExceeded(c, limitErr)
+	}
+	if errors.Is(err, storage.ErrNotLinked) {
+		return c.Status(403).JSON(fiber.Map{"error": err.Error()})
+	}
+	if errors.Is(err, storage.ErrAccountFrozen) || errors.Is(err, storage.ErrAccountClosed) {
+		return c.Status(409).JSON(fiber.Map{"error": err.Error()})
+	}
 	if err != nil {
 		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
 	}
 
-	return c.JSON(fiber.Map{"status": "success", "message": "Transfer Complete!"})
+	slog.Info("🏬 Platform Transfer", "transaction_id", txn.ID, "from", fromUUID, "to", toUUID)
+	return c.JSON(txn)
 }
 
 func (h *TransactionHandler) GetHistory(c *fiber.Ctx) error {
 	// We get the Account ID from the URL (e.g., /accounts/:id/transactions)
+	// Ownership is already checked by middleware.AccountOwner on the route.
 	accountIDParam := c.Params("id")
 	accountUUID, err := uuid.Parse(accountIDParam)
 	if err != nil {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid Account ID"})
 	}
 
-	history, err := h.Repo.GetHistory(c.Context(), accountUUID)
+	filter, err := parseHistoryFilter(c)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	page, err := h.Repo.GetHistory(c.Context(), accountUUID, filter)
+	if errors.Is(err, storage.ErrTransactionNotFound) {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
 	if err != nil {
+		slog.Error("Failed to fetch history", "error", err, "account_id", accountUUID)
 		return c.Status(500).JSON(fiber.Map{"error": "Could not fetch history"})
 	}
 
-	// Also get the current balance
-	// (You can reuse the GetAccountByID logic here, but let's keep it simple for now)
-	
+	// Also report the balance, one total per currency
+	balances, err := h.Repo.BalancesByCurrency(c.Context(), accountUUID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Could not fetch balances"})
+	}
+
 	return c.JSON(fiber.Map{
-		"transactions": history,
+		"transactions": page.Items,
+		"has_more":     page.HasMore,
+		"balances":     balances,
 	})
-}
\ No newline at end of file
+}
+
+// parseHistoryFilter reads ?starting_after=&limit=&created_from=&created_to=&direction=
+// &status=&min_amount=&max_amount=&description= (dates are RFC 3339)
+func parseHistoryFilter(c *fiber.Ctx) (storage.HistoryFilter, error) {
+	var f storage.HistoryFilter
+
+	if v := c.Query("starting_after"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			return f, fmt.Errorf("invalid starting_after")
+		}
+		f.StartingAfter = &id
+	}
+
+	if v := c.Query("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > storage.MaxHistoryLimit {
+			return f, fmt.Errorf("limit must be between 1 and %d", storage.MaxHistoryLimit)
+		}
+		f.Limit = limit
+	}
+
+	for param, target := range map[string]**time.Time{"created_from": &f.CreatedFrom, "created_to": &f.CreatedTo} {
+		if v := c.Query(param); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return f, fmt.Errorf("invalid %s, use RFC 3339 (e.g. 2024-01-31T00:00:00Z)", param)
+			}
+			*target = &t
+		}
+	}
+
+	if v := strings.ToUpper(c.Query("direction")); v != "" {
+		if v != "CREDIT" && v != "DEBIT" {
+			return f, fmt.Errorf("direction must be CREDIT or DEBIT")
+		}
+		f.Direction = v
+	}
+
+	f.Status = strings.ToUpper(c.Query("status"))
+	f.Description = c.Query("description")
+
+	for param, target := range map[string]**int64{"min_amount": &f.MinAmount, "max_amount": &f.MaxAmount} {
+		if v := c.Query(param); v != "" {
+			amount, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return f, fmt.Errorf("invalid %s", param)
+			}
+			*target = &amount
+		}
+	}
+
+	return f, nil
+}
+
+type RefundRequest struct {
+	Amount int64  `json:"amount"` // Optional: 0 refunds everything that's left
+	Reason string `json:"reason"`
+}
+
+// GetTransaction returns a transaction to any account that appears in its entries
+func (h *TransactionHandler) GetTransaction(c *fiber.Ctx) error {
+	txID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid Transaction ID"})
+	}
+
+	txn, err := h.Repo.GetTransaction(c.Context(), txID)
+	if errors.Is(err, storage.ErrTransactionNotFound) {
+		return c.Status(404).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Could not fetch transaction"})
+	}
+
+	for _, e := range txn.Entries {
+		if middleware.CanAccess(c, e.AccountID) {
+			return c.JSON(txn)
+		}
+	}
+	return middleware.Forbidden(c)
+}
+
+// Refund API. Only the account that received the money can give it back. On a payment that
+// is the merchant it was collected for (or its platform): a seller on a split payment only
+// got a share, and refunding the whole payment would take money back from the others.
+func (h *TransactionHandler) Refund(c *fiber.Ctx) error {
+	txID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid Transaction ID"})
+	}
+
+	var req RefundRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "Invalid body"})
+		}
+	}
+	if req.Amount < 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "Amount cannot be negative"})
+	}
+
+	txn, err := h.Repo.GetTransaction(c.Context(), txID)
+	if errors.Is(err, storage.ErrTransactionNotFound) {
+		return c.Status(404).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Could not fetch transaction"})
+	}
+
+	allowed := false
+	if txn.MerchantAccountID != nil {
+		allowed = middleware.CanAccess(c, *txn.MerchantAccountID)
+	} else {
+		for _, e := range txn.Entries {
+			if e.Direction == "CREDIT" && middleware.CanAccess(c, e.AccountID) {
+				allowed = true
+				break
+			}
+		}
+	}
+	if !allowed {
+		return middleware.Forbidden(c)
+	}
+
+	refund, err := h.Repo.Refund(c.Context(), txID, req.Amount, req.Reason)
+	if err != nil {
+		slog.Warn("Refund rejected", "error", err, "transaction_id", txID)
+		if errors.Is(err, storage.ErrNotRefundable) || errors.Is(err, storage.ErrRefundExceedsBalance) {
+			return c.Status(409).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	slog.Info("↩️ Refund Created", "refund_id", refund.ID, "transaction_id", txID, "amount", refund.Amount)
+	return c.Status(201).JSON(refund)
+}
@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Admin guards back-office routes. It accepts an operator's own gp_admin_ key, or the
+// bootstrap ADMIN_API_KEY (when configured), which is meant for managing operators.
+func Admin(db *pgxpool.Pool, adminKey string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		key, ok := strings.CutPrefix(c.Get("Authorization"), "Bearer ")
+		if !ok || key == "" {
+			return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid Admin Key"})
+		}
+
+		if adminKey != "" && subtle.ConstantTimeCompare([]byte(key), []byte(adminKey)) == 1 {
+			c.Locals("admin_key", true)
+			return c.Next()
+		}
+
+		hash := sha256.Sum256([]byte(key))
+		var operatorID string
+		err := db.QueryRow(c.Context(), `
+			UPDATE operators SET last_used_at = NOW()
+			WHERE key_hash = $1 AND revoked_at IS NULL
+			RETURNING id`, hex.EncodeToString(hash[:])).Scan(&operatorID)
+		if err != nil {
+			return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid Admin Key"})
+		}
+
+		c.Locals("operator_id", operatorID)
+		slog.Info("🛂 Admin request", "operator_id", operatorID, "method", c.Method(), "path", c.Path())
+		return c.Next()
+	}
+}
+
+// AdminKeyOnly limits a route to the bootstrap ADMIN_API_KEY (e.g. managing operators).
+// It must be mounted after Admin.
+func AdminKeyOnly() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if !IsAdminKey(c) {
+			return c.Status(http.StatusForbidden).JSON(fiber.Map{"error": "Only the ADMIN_API_KEY can manage operators"})
+		}
+		return c.Next()
+	}
+}
+
+// IsAdminKey reports whether the request was made with the bootstrap ADMIN_API_KEY
+func IsAdminKey(c *fiber.Ctx) bool {
+	isAdminKey, _ := c.Locals("admin_key").(bool)
+	return isAdminKey
+}
+
+// OperatorID returns the operator making a back-office request
+func OperatorID(c *fiber.Ctx) (uuid.UUID, bool) {
+	return localID(c, "operator_id")
+}
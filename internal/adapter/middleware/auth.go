@@ -3,17 +3,21 @@ package middleware
 import (
 	"crypto/sha256"
 	"encoding/hex"
+	"log/slog"
 	"net/http"
 	"strings"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/ibrahimkeyboad/gopay/internal/core/security"
 )
 
 func Protected(db *pgxpool.Pool) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		// 1. Get Token from Header
-		authHeader := c.Get("Authorization") // "Bearer sk_live_..."
+		authHeader := c.Get("Authorization") // "Bearer gp_live_..." or "Bearer gp_test_..."
 		if authHeader == "" {
 			return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "Missing API Key"})
 		}
@@ -29,16 +33,85 @@ func Protected(db *pgxpool.Pool) fiber.Handler {
 		hashedKey := hex.EncodeToString(hash[:])
 
 		// 3. Check DB
-		var accountID string
-		err := db.QueryRow(c.Context(), "SELECT account_id FROM api_keys WHERE key_hash = $1", hashedKey).Scan(&accountID)
+		var keyID, accountID string
+		var livemode bool
+		var scopes, allowedIPs []string
+		// System accounts never act through the API, even if a key somehow exists for one.
+		// A test key only reaches a test account and a live key only a live one.
+		// Revoked and expired keys (including rolled keys past their overlap) are refused, and so
+		// are publishable keys: they only work on the routes behind Publishable.
+		err := db.QueryRow(c.Context(), `
+			SELECT k.id, k.account_id, k.livemode, k.scopes, k.allowed_ips FROM api_keys k
+			JOIN accounts a ON a.id = k.account_id
+			WHERE k.key_hash = $1 AND a.system_kind IS NULL AND a.livemode = k.livemode
+			  AND k.revoked_at IS NULL AND (k.expires_at IS NULL OR k.expires_at > NOW()) AND NOT k.publishable`, hashedKey).Scan(&keyID, &accountID, &livemode, &scopes, &allowedIPs)
 		
 		if err != nil {
 			return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid API Key"})
 		}
+		wantPrefix := security.PrefixLive
+		if !livemode {
+			wantPrefix = security.PrefixTest
+		}
+		if security.KeyPrefix(apiKey) != wantPrefix {
+			return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid API Key"})
+		}
+		// The allowlist binds the key itself, so it is checked here for every route.
+		// Scopes depend on the route and are checked by RequireScope.
+		if !security.IPAllowed(allowedIPs, c.IP()) {
+			slog.Warn("🚫 API key used from an address outside its allowlist", "key_id", keyID, "ip", c.IP())
+			return c.Status(http.StatusForbidden).JSON(fiber.Map{"error": "This API key cannot be used from your IP address"})
+		}
+		c.Locals("livemode", livemode)
+		c.Locals("scopes", scopes)
+		c.Locals("allowed_ips", allowedIPs)
+		c.Locals("api_key_id", keyID)
+
+		touchKey(c, db, keyID)
 
-		// 4. Save Account ID to Context (So handler knows who is calling)
+		// 4. Acting on behalf of a sub-account? The key's account must be its parent.
+		if childID := c.Get(AccountHeader); childID != "" {
+			if _, err := uuid.Parse(childID); err != nil {
+				return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid " + AccountHeader + " header"})
+			}
+			var isChild bool
+			err := db.QueryRow(c.Context(), `
+				SELECT EXISTS (SELECT 1 FROM accounts WHERE id = $1 AND parent_id = $2)`, childID, accountID).Scan(&isChild)
+			if err != nil || !isChild {
+				return Forbidden(c)
+			}
+			c.Locals("platform_id", accountID)
+			c.Locals("merchant_id", childID)
+			return c.Next()
+		}
+
+		// 5. Save Account ID to Context (So handler knows who is calling)
 		c.Locals("merchant_id", accountID)
 
+		// The key's account may also operate its sub-accounts
+		childOf := map[uuid.UUID]bool{}
+		c.Locals("child_check", childCheck(func(id uuid.UUID) bool {
+			if known, ok := childOf[id]; ok {
+				return known
+			}
+			var isChild bool
+			err := db.QueryRow(c.Context(), `
+				SELECT EXISTS (SELECT 1 FROM accounts WHERE id = $1 AND parent_id = $2)`, id, accountID).Scan(&isChild)
+			childOf[id] = err == nil && isChild
+			return childOf[id]
+		}))
+
 		return c.Next()
 	}
-}
\ No newline at end of file
+}
+
+// Anonymous serves requests that carry no API key with h and passes keyed requests on,
+// so the same route can be mounted again behind Protected for authenticated callers.
+func Anonymous(h fiber.Handler) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if c.Get("Authorization") != "" {
+			return c.Next()
+		}
+		return h(c)
+	}
+}
@@ -0,0 +1,102 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// AccountHeader lets a platform's key act on behalf of one of its sub-accounts
+const AccountHeader = "GoPay-Account"
+
+// MerchantID returns the account the request acts as: the account bound to the API key,
+// or the sub-account named in the GoPay-Account header.
+// It must only be called on routes mounted behind Protected.
+func MerchantID(c *fiber.Ctx) (uuid.UUID, bool) {
+	return localID(c, "merchant_id")
+}
+
+// APIKeyID returns the key the request was made with
+func APIKeyID(c *fiber.Ctx) (uuid.UUID, bool) {
+	return localID(c, "api_key_id")
+}
+
+// Scopes returns the scopes of the key the request was made with (nil = unrestricted)
+func Scopes(c *fiber.Ctx) []string {
+	scopes, _ := c.Locals("scopes").([]string)
+	return scopes
+}
+
+// AllowedIPs returns the IP allowlist of the key the request was made with (nil = any address)
+func AllowedIPs(c *fiber.Ctx) []string {
+	allowedIPs, _ := c.Locals("allowed_ips").([]string)
+	return allowedIPs
+}
+
+// PlatformID returns the account bound to the API key when it is acting on behalf of
+// one of its sub-accounts
+func PlatformID(c *fiber.Ctx) (uuid.UUID, bool) {
+	return localID(c, "platform_id")
+}
+
+// Livemode reports whether the request was made with a live key (false for gp_test_ keys).
+// Sub-accounts reached through the GoPay-Account header always share the key's mode.
+func Livemode(c *fiber.Ctx) bool {
+	livemode, ok := c.Locals("livemode").(bool)
+	return !ok || livemode
+}
+
+func localID(c *fiber.Ctx, key string) (uuid.UUID, bool) {
+	raw, ok := c.Locals(key).(string)
+	if !ok {
+		return uuid.Nil, false
+	}
+	id, err := uuid.Parse(raw)
+	if err != nil {
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+// childCheck is set by Protected: it reports whether an account is a sub-account of the caller
+type childCheck func(accountID uuid.UUID) bool
+
+// CanAccess reports whether the caller is entitled to operate on accountID.
+// A key is entitled to the account it was issued for and that account's sub-accounts.
+// A request made on behalf of a sub-account (GoPay-Account header) is limited to that sub-account.
+func CanAccess(c *fiber.Ctx, accountID uuid.UUID) bool {
+	merchantID, ok := MerchantID(c)
+	if !ok {
+		return false
+	}
+	if merchantID == accountID {
+		return true
+	}
+	if isChild, ok := c.Locals("child_check").(childCheck); ok {
+		return isChild(accountID)
+	}
+	return false
+}
+
+// Forbidden writes the standard 403 response used when a caller touches an account it doesn't own.
+func Forbidden(c *fiber.Ctx) error {
+	return c.Status(http.StatusForbidden).JSON(fiber.Map{"error": "You do not have access to this account"})
+}
+
+// AccountOwner rejects requests whose account route parameter (e.g. ":id")
+// does not belong to the caller. Use it on routes like /accounts/:id/...
+func AccountOwner(param string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		accountID, err := uuid.Parse(c.Params(param))
+		if err != nil {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid Account ID"})
+		}
+
+		if !CanAccess(c, accountID) {
+			return Forbidden(c)
+		}
+
+		return c.Next()
+	}
+}
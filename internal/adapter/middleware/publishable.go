@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/ibrahimkeyboad/gopay/internal/core/security"
+)
+
+// Publishable authenticates a publishable key (gp_pk_live_ / gp_pk_test_) from a checkout.
+// It only guards the payment intent routes; secret keys are not accepted here, so a
+// secret key never has to be sent from a browser or app.
+func Publishable(db *pgxpool.Pool) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		key, ok := strings.CutPrefix(c.Get("Authorization"), "Bearer ")
+		if !ok || key == "" {
+			return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "Missing Publishable Key"})
+		}
+		prefix := security.KeyPrefix(key)
+		if prefix != security.PrefixPublishableLive && prefix != security.PrefixPublishableTest {
+			return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "Use a publishable key (gp_pk_...) here"})
+		}
+
+		hash := sha256.Sum256([]byte(key))
+		var keyID, accountID string
+		var livemode bool
+		err := db.QueryRow(c.Context(), `
+			SELECT k.id, k.account_id, k.livemode FROM api_keys k
+			JOIN accounts a ON a.id = k.account_id
+			WHERE k.key_hash = $1 AND k.publishable AND a.system_kind IS NULL AND a.livemode = k.livemode
+			  AND k.revoked_at IS NULL AND (k.expires_at IS NULL OR k.expires_at > NOW())`,
+			hex.EncodeToString(hash[:])).Scan(&keyID, &accountID, &livemode)
+		if err != nil || livemode != (prefix == security.PrefixPublishableLive) {
+			return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid Publishable Key"})
+		}
+
+		c.Locals("merchant_id", accountID)
+		c.Locals("livemode", livemode)
+		c.Locals("api_key_id", keyID)
+		touchKey(c, db, keyID)
+		return c.Next()
+	}
+}
+
+// touchKey records when a key was last used. It writes at most once a minute per key
+// so busy keys don't turn every request into a write.
+func touchKey(c *fiber.Ctx, db *pgxpool.Pool, keyID string) {
+	if _, err := db.Exec(c.Context(), `
+		UPDATE api_keys SET last_used_at = NOW()
+		WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < NOW() - INTERVAL '1 minute')`, keyID); err != nil {
+		slog.Warn("Failed to record API key use", "error", err, "key_id", keyID)
+	}
+}
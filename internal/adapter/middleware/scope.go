@@ -0,0 +1,24 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/ibrahimkeyboad/gopay/internal/core/security"
+)
+
+// RequireScope rejects requests made with a restricted key that lacks scope.
+// Unrestricted keys pass. It must be mounted after Protected.
+func RequireScope(scope security.Scope) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		scopes, ok := c.Locals("scopes").([]string)
+		if !ok {
+			return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "Missing API Key"})
+		}
+		if !security.HasScope(scopes, scope) {
+			return c.Status(http.StatusForbidden).JSON(fiber.Map{"error": "This API key does not have the " + string(scope) + " scope"})
+		}
+		return c.Next()
+	}
+}
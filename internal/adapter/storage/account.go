@@ -20,11 +20,51 @@ func NewAccountRepository(db *pgxpool.Pool) *AccountRepository {
 
 // Account Model
 type Account struct {
-	ID        uuid.UUID `json:"id"`
-	OwnerName string    `json:"owner_name"`
-	Balance   int64     `json:"balance"`
-	Currency  string    `json:"currency"`
-	CreatedAt time.Time `json:"created_at"`
+	ID           uuid.UUID     `json:"id"`
+	OwnerName    string        `json:"owner_name"`
+	Balance      int64         `json:"balance"`
+	Currency     string        `json:"currency"`
+	Status       AccountStatus `json:"status"`
+	StatusReason *string       `json:"status_reason,omitempty"`
+	KYCTier      string        `json:"kyc_tier"`
+	PricingPlan  string        `json:"pricing_plan"`
+
+	// How our fees are taxed (see tax.go)
+	TaxJurisdiction string `json:"tax_jurisdiction"`
+	WithholdingTax  bool   `json:"withholding_tax"`
+
+	ParentID      *uuid.UUID `json:"parent_id,omitempty"` // Set on sub-accounts
+	Livemode      bool       `json:"livemode"`
+	LiveAccountID *uuid.UUID `json:"live_account_id,omitempty"` // Set on test accounts
+	CreatedAt     time.Time  `json:"created_at"`
+
+	// Overdraft / credit line (see credit.go)
+	AccountType     AccountType `json:"account_type"`
+	OverdraftLimit  int64       `json:"overdraft_limit"`
+	CreditLimit     int64       `json:"credit_limit"`
+	GracePeriodDays int         `json:"grace_period_days"`
+	OverdrawnSince  *time.Time  `json:"overdrawn_since,omitempty"`
+	GraceEndsAt     *time.Time  `json:"grace_ends_at,omitempty"` // End of the interest-free period while overdrawn
+}
+
+const accountColumns = `id, owner_name, balance, currency, status, status_reason, kyc_tier, pricing_plan, tax_jurisdiction, withholding_tax, parent_id, livemode, live_account_id, created_at,
+	account_type, overdraft_limit, credit_limit, grace_period_days, overdrawn_since`
+
+func scanAccount(row pgx.Row) (*Account, error) {
+	var acc Account
+	err := row.Scan(&acc.ID, &acc.OwnerName, &acc.Balance, &acc.Currency, &acc.Status, &acc.StatusReason, &acc.KYCTier, &acc.PricingPlan, &acc.TaxJurisdiction, &acc.WithholdingTax, &acc.ParentID, &acc.Livemode, &acc.LiveAccountID, &acc.CreatedAt,
+		&acc.AccountType, &acc.OverdraftLimit, &acc.CreditLimit, &acc.GracePeriodDays, &acc.OverdrawnSince)
+	if err == pgx.ErrNoRows {
+		return nil, ErrAccountNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if acc.OverdrawnSince != nil {
+		ends := acc.OverdrawnSince.AddDate(0, 0, acc.GracePeriodDays)
+		acc.GraceEndsAt = &ends
+	}
+	return &acc, nil
 }
 
 // CreateAccount
@@ -32,42 +72,59 @@ func (r *AccountRepository) CreateAccount(ctx context.Context, ownerName string,
 	query := `
 		INSERT INTO accounts (owner_name, currency, balance)
 		VALUES ($1, $2, 0)
-		RETURNING id, owner_name, balance, currency, created_at
-	`
-	var acc Account
-	err := r.db.QueryRow(ctx, query, ownerName, currency).Scan(
-		&acc.ID, &acc.OwnerName, &acc.Balance, &acc.Currency, &acc.CreatedAt,
-	)
+		RETURNING ` + accountColumns
+	acc, err := scanAccount(r.db.QueryRow(ctx, query, ownerName, currency))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create account: %w", err)
 	}
-	return &acc, nil
+	return acc, nil
 }
 
 // GetAccountByID
 func (r *AccountRepository) GetAccountByID(ctx context.Context, id uuid.UUID) (*Account, error) {
-	query := `SELECT id, owner_name, balance, currency, created_at FROM accounts WHERE id = $1`
-	var acc Account
-	err := r.db.QueryRow(ctx, query, id).Scan(
-		&acc.ID, &acc.OwnerName, &acc.Balance, &acc.Currency, &acc.CreatedAt,
-	)
-	if err == pgx.ErrNoRows {
-		return nil, fmt.Errorf("account not found")
+	query := `SELECT ` + accountColumns + ` FROM accounts WHERE id = $1`
+	return scanAccount(r.db.QueryRow(ctx, query, id))
+}
+
+// --- THIS IS THE MISSING PART ---
+// SaveAPIKey stores the hashed key for the user
+// Keys are never issued for system accounts. A key's mode must match its account's:
+// test keys are saved against the account's test twin (see TestAccount).
+// actor is recorded in the key's audit trail as its issuer.
+func (r *AccountRepository) SaveAPIKey(ctx context.Context, key APIKey, actor Actor) (*APIKey, error) {
+	system, err := isSystemAccount(ctx, r.db, key.AccountID)
+	if err != nil {
+		return nil, err
+	}
+	if system {
+		return nil, ErrSystemAccount
 	}
+	accountLive, err := accountLivemode(ctx, r.db, key.AccountID)
 	if err != nil {
 		return nil, err
 	}
-	return &acc, nil
-}
+	if accountLive != key.Livemode {
+		return nil, ErrLivemodeMismatch
+	}
 
-// --- THIS IS THE MISSING PART ---
-// SaveAPIKey stores the hashed key for the user
-func (r *AccountRepository) SaveAPIKey(ctx context.Context, accountID uuid.UUID, keyHash string, keyPrefix string) error {
-	query := `INSERT INTO api_keys (account_id, key_hash, key_prefix) VALUES ($1, $2, $3)`
-	
-	_, err := r.db.Exec(ctx, query, accountID, keyHash, keyPrefix)
+	tx, err := r.db.Begin(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to save api key: %w", err)
+		return nil, err
 	}
-	return nil
-}
\ No newline at end of file
+	defer tx.Rollback(ctx)
+
+	query := `
+		INSERT INTO api_keys (account_id, key_hash, key_prefix, last4, livemode, expires_at, scopes, allowed_ips, publishable)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING ` + apiKeyColumns
+
+	saved, err := scanAPIKey(tx.QueryRow(ctx, query, key.AccountID, key.Hash, key.Prefix, key.Last4, key.Livemode, key.ExpiresAt,
+		key.Scopes, key.AllowedIPs, key.Publishable))
+	if err != nil {
+		return nil, fmt.Errorf("failed to save api key: %w", err)
+	}
+	if err := recordKeyEvent(ctx, tx, saved, KeyEventIssued, actor); err != nil {
+		return nil, err
+	}
+	return saved, tx.Commit(ctx)
+}
@@ -0,0 +1,210 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/ibrahimkeyboad/gopay/internal/core/domain"
+)
+
+// AccountStatus is where an account is in its lifecycle
+type AccountStatus string
+
+const (
+	AccountActive AccountStatus = "ACTIVE"
+	AccountFrozen AccountStatus = "FROZEN" // can receive, cannot send
+	AccountClosed AccountStatus = "CLOSED" // can do neither, and never reopens
+)
+
+// StatusReason explains a status change
+type StatusReason string
+
+const (
+	ReasonComplianceReview StatusReason = "COMPLIANCE_REVIEW"
+	ReasonSuspectedFraud   StatusReason = "SUSPECTED_FRAUD"
+	ReasonSanctionsMatch   StatusReason = "SANCTIONS_MATCH"
+	ReasonChargebackRisk   StatusReason = "CHARGEBACK_RISK"
+	ReasonCustomerRequest  StatusReason = "CUSTOMER_REQUEST"
+	ReasonReviewCleared    StatusReason = "REVIEW_CLEARED"
+	ReasonDormant          StatusReason = "DORMANT"
+	ReasonOther            StatusReason = "OTHER"
+)
+
+var statusReasons = []StatusReason{
+	ReasonComplianceReview, ReasonSuspectedFraud, ReasonSanctionsMatch, ReasonChargebackRisk,
+	ReasonCustomerRequest, ReasonReviewCleared, ReasonDormant, ReasonOther,
+}
+
+var (
+	ErrAccountFrozen       = errors.New("account is frozen")
+	ErrAccountClosed       = errors.New("account is closed")
+	ErrInvalidStatusChange = errors.New("invalid account status change")
+	ErrAccountNotEmpty     = errors.New("account must have a zero balance and no open holds to be closed")
+	ErrInvalidStatusReason = errors.New("invalid status reason")
+)
+
+// ParseStatusReason validates a reason code (case-insensitive)
+func ParseStatusReason(code string) (StatusReason, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	for _, r := range statusReasons {
+		if string(r) == code {
+			return r, nil
+		}
+	}
+	return "", fmt.Errorf("%w %q", ErrInvalidStatusReason, code)
+}
+
+// canSend reports whether money may leave an account in this status
+func (s AccountStatus) canSend() error {
+	switch s {
+	case AccountFrozen:
+		return ErrAccountFrozen
+	case AccountClosed:
+		return ErrAccountClosed
+	}
+	return nil
+}
+
+// canReceive reports whether money may arrive in an account in this status
+func (s AccountStatus) canReceive() error {
+	if s == AccountClosed {
+		return ErrAccountClosed
+	}
+	return nil
+}
+
+// allowedTransitions lists where each status can go. CLOSED is final.
+var allowedTransitions = map[AccountStatus][]AccountStatus{
+	AccountActive: {AccountFrozen, AccountClosed},
+	AccountFrozen: {AccountActive, AccountClosed},
+}
+
+// SetStatus moves an account to a new status, records the change and queues an
+// account.updated webhook. Closing requires a zero balance and no open holds.
+func (r *AccountRepository) SetStatus(ctx context.Context, id uuid.UUID, status AccountStatus, reason StatusReason, note string) (*Account, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	var current AccountStatus
+	var balance int64
+	var system bool
+	err = tx.QueryRow(ctx, `SELECT status, balance, system_kind IS NOT NULL FROM accounts WHERE id = $1 FOR UPDATE`, id).
+		Scan(&current, &balance, &system)
+	if err == pgx.ErrNoRows {
+		return nil, ErrAccountNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if system {
+		return nil, ErrSystemAccount
+	}
+
+	allowed := false
+	for _, next := range allowedTransitions[current] {
+		if next == status {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return nil, fmt.Errorf("%w: %s -> %s", ErrInvalidStatusChange, current, status)
+	}
+
+	if status == AccountClosed {
+		// Expired holds count until the expiry worker has released them: their money is still
+		// in HOLDS, and releasing it to a closed account would fail
+		var openHolds int64
+		err = tx.QueryRow(ctx, `
+			SELECT COUNT(*) FROM holds
+			WHERE (account_id = $1 OR destination_account_id = $1) AND status = 'AUTHORIZED'`, id).Scan(&openHolds)
+		if err != nil {
+			return nil, err
+		}
+		if balance != 0 || openHolds > 0 {
+			return nil, ErrAccountNotEmpty
+		}
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE accounts SET status = $1, status_reason = $2, status_changed_at = NOW() WHERE id = $3`,
+		status, reason, id)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO account_status_changes (account_id, previous_status, status, reason, note)
+		VALUES ($1, $2, $3, $4, $5)`, id, current, status, reason, note)
+	if err != nil {
+		return nil, err
+	}
+
+	err = enqueueWebhook(ctx, tx, "account.updated", map[string]interface{}{
+		"account_id":      id,
+		"status":          status,
+		"previous_status": current,
+		"reason":          reason,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	account, err := scanAccount(tx.QueryRow(ctx, `SELECT `+accountColumns+` FROM accounts WHERE id = $1`, id))
+	if err != nil {
+		return nil, err
+	}
+
+	return account, tx.Commit(ctx)
+}
+
+// SetKYCTier moves an account to another KYC tier and queues an account.updated webhook.
+// The new limits apply to the next booking.
+func (r *AccountRepository) SetKYCTier(ctx context.Context, id uuid.UUID, tier domain.KYCTier) (*Account, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	var previous domain.KYCTier
+	var system bool
+	err = tx.QueryRow(ctx, `SELECT kyc_tier, system_kind IS NOT NULL FROM accounts WHERE id = $1 FOR UPDATE`, id).Scan(&previous, &system)
+	if err == pgx.ErrNoRows {
+		return nil, ErrAccountNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if system {
+		return nil, ErrSystemAccount
+	}
+
+	if _, err := tx.Exec(ctx, `UPDATE accounts SET kyc_tier = $1 WHERE id = $2`, tier, id); err != nil {
+		return nil, err
+	}
+
+	err = enqueueWebhook(ctx, tx, "account.updated", map[string]interface{}{
+		"account_id":        id,
+		"kyc_tier":          tier,
+		"previous_kyc_tier": previous,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	account, err := scanAccount(tx.QueryRow(ctx, `SELECT `+accountColumns+` FROM accounts WHERE id = $1`, id))
+	if err != nil {
+		return nil, err
+	}
+
+	return account, tx.Commit(ctx)
+}
@@ -0,0 +1,264 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// Key statuses, worked out from revoked_at and expires_at
+const (
+	KeyActive  = "ACTIVE"
+	KeyExpired = "EXPIRED"
+	KeyRevoked = "REVOKED"
+)
+
+// Who can act on keys
+const (
+	ActorAdminKey = "ADMIN_KEY" // The bootstrap ADMIN_API_KEY
+	ActorOperator = "OPERATOR"
+	ActorAPIKey   = "API_KEY" // The account's own (or its platform's) key
+)
+
+// Key events
+const (
+	KeyEventIssued        = "ISSUED"
+	KeyEventRolled        = "ROLLED"
+	KeyEventRevoked       = "REVOKED"
+	KeyEventExpiryChanged = "EXPIRY_CHANGED"
+)
+
+var (
+	ErrAPIKeyNotFound  = errors.New("api key not found")
+	ErrAPIKeyNotActive = errors.New("api key is revoked, expired or already rolled")
+)
+
+// APIKey describes an issued key. The secret itself is never stored: only its hash,
+// its prefix and its last 4 characters (so people can tell their keys apart).
+type APIKey struct {
+	ID        uuid.UUID `json:"id"`
+	AccountID uuid.UUID `json:"account_id"`
+	Prefix    string    `json:"prefix"`
+	Last4     *string   `json:"last4"` // NULL for keys issued before keys could be listed
+	Livemode  bool      `json:"livemode"`
+	// Publishable keys (gp_pk_) only create payment intents
+	Publishable bool       `json:"publishable"`
+	Status      string     `json:"status"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	RevokedAt   *time.Time `json:"revoked_at,omitempty"`
+	LastUsedAt  *time.Time `json:"last_used_at,omitempty"`
+	ReplacedBy  *uuid.UUID `json:"replaced_by,omitempty"` // Set once the key has been rolled
+	CreatedAt   time.Time  `json:"created_at"`
+
+	// Restrictions (see security.Scopes). Nil means unrestricted.
+	Scopes     []string `json:"scopes,omitempty"`
+	AllowedIPs []string `json:"allowed_ips,omitempty"`
+
+	Hash string `json:"-"`
+}
+
+const apiKeyColumns = `id, account_id, key_prefix, last4, livemode, expires_at, revoked_at, last_used_at, replaced_by, created_at, scopes, allowed_ips, publishable`
+
+func scanAPIKey(row pgx.Row) (*APIKey, error) {
+	var k APIKey
+	err := row.Scan(&k.ID, &k.AccountID, &k.Prefix, &k.Last4, &k.Livemode, &k.ExpiresAt, &k.RevokedAt,
+		&k.LastUsedAt, &k.ReplacedBy, &k.CreatedAt, &k.Scopes, &k.AllowedIPs, &k.Publishable)
+	if err == pgx.ErrNoRows {
+		return nil, ErrAPIKeyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	k.Status = k.status(time.Now())
+	return &k, nil
+}
+
+// Actor is whoever made a change, as recorded in api_key_events
+type Actor struct {
+	Type string     `json:"type"`
+	ID   *uuid.UUID `json:"id,omitempty"` // Operator or API key id; nil for ActorAdminKey
+	IP   string     `json:"ip"`
+}
+
+// APIKeyEvent is one entry in a key's audit trail
+type APIKeyEvent struct {
+	ID        uuid.UUID `json:"id"`
+	APIKeyID  uuid.UUID `json:"api_key_id"`
+	AccountID uuid.UUID `json:"account_id"`
+	Action    string    `json:"action"`
+	Actor     Actor     `json:"actor"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func recordKeyEvent(ctx context.Context, tx pgx.Tx, key *APIKey, action string, actor Actor) error {
+	_, err := tx.Exec(ctx, `
+		INSERT INTO api_key_events (api_key_id, account_id, action, actor_type, actor_id, ip)
+		VALUES ($1, $2, $3, $4, $5, $6)`, key.ID, key.AccountID, action, actor.Type, actor.ID, actor.IP)
+	return err
+}
+
+// ListAPIKeyEvents returns the audit trail of every key issued for an account, newest first
+func (r *AccountRepository) ListAPIKeyEvents(ctx context.Context, accountID uuid.UUID) ([]APIKeyEvent, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT id, api_key_id, account_id, action, actor_type, actor_id, ip, created_at
+		FROM api_key_events WHERE account_id = $1 ORDER BY created_at DESC, id`, accountID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []APIKeyEvent{}
+	for rows.Next() {
+		var e APIKeyEvent
+		if err := rows.Scan(&e.ID, &e.APIKeyID, &e.AccountID, &e.Action, &e.Actor.Type, &e.Actor.ID, &e.Actor.IP, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+func (k *APIKey) status(now time.Time) string {
+	switch {
+	case k.RevokedAt != nil:
+		return KeyRevoked
+	case k.ExpiresAt != nil && !k.ExpiresAt.After(now):
+		return KeyExpired
+	}
+	return KeyActive
+}
+
+// ListAPIKeys returns every key issued for an account, newest first
+func (r *AccountRepository) ListAPIKeys(ctx context.Context, accountID uuid.UUID) ([]APIKey, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT `+apiKeyColumns+` FROM api_keys WHERE account_id = $1 ORDER BY created_at DESC, id`, accountID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []APIKey{}
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, *k)
+	}
+	return keys, rows.Err()
+}
+
+// GetAPIKey fetches one of an account's keys
+func (r *AccountRepository) GetAPIKey(ctx context.Context, accountID, keyID uuid.UUID) (*APIKey, error) {
+	return scanAPIKey(r.db.QueryRow(ctx, `
+		SELECT `+apiKeyColumns+` FROM api_keys WHERE id = $1 AND account_id = $2`, keyID, accountID))
+}
+
+// RevokeAPIKey stops a key from working straight away. Revoking is final.
+func (r *AccountRepository) RevokeAPIKey(ctx context.Context, accountID, keyID uuid.UUID, actor Actor) (*APIKey, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	key, err := lockAPIKey(ctx, tx, accountID, keyID)
+	if err != nil {
+		return nil, err
+	}
+	if key.RevokedAt != nil {
+		return key, nil
+	}
+
+	key, err = scanAPIKey(tx.QueryRow(ctx, `
+		UPDATE api_keys SET revoked_at = NOW() WHERE id = $1 RETURNING `+apiKeyColumns, keyID))
+	if err != nil {
+		return nil, err
+	}
+	if err := recordKeyEvent(ctx, tx, key, KeyEventRevoked, actor); err != nil {
+		return nil, err
+	}
+	return key, tx.Commit(ctx)
+}
+
+// SetAPIKeyExpiry sets when a key stops working (nil = never). Revoked and expired keys can't be revived.
+func (r *AccountRepository) SetAPIKeyExpiry(ctx context.Context, accountID, keyID uuid.UUID, expiresAt *time.Time, actor Actor) (*APIKey, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	key, err := lockAPIKey(ctx, tx, accountID, keyID)
+	if err != nil {
+		return nil, err
+	}
+	if key.Status != KeyActive {
+		return nil, ErrAPIKeyNotActive
+	}
+
+	key, err = scanAPIKey(tx.QueryRow(ctx, `
+		UPDATE api_keys SET expires_at = $2 WHERE id = $1 RETURNING `+apiKeyColumns, keyID, expiresAt))
+	if err != nil {
+		return nil, err
+	}
+	if err := recordKeyEvent(ctx, tx, key, KeyEventExpiryChanged, actor); err != nil {
+		return nil, err
+	}
+	return key, tx.Commit(ctx)
+}
+
+// RollAPIKey replaces a key with next (same account, prefix, mode, kind and restrictions). The old key keeps
+// working for overlap so deployments can switch over, then expires. It returns the new key
+// and the old one as it now stands.
+func (r *AccountRepository) RollAPIKey(ctx context.Context, accountID, keyID uuid.UUID, next APIKey, overlap time.Duration, actor Actor) (*APIKey, *APIKey, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	old, err := lockAPIKey(ctx, tx, accountID, keyID)
+	if err != nil {
+		return nil, nil, err
+	}
+	// A key is rolled once; roll its replacement after that
+	if old.Status != KeyActive || old.ReplacedBy != nil {
+		return nil, nil, ErrAPIKeyNotActive
+	}
+
+	created, err := scanAPIKey(tx.QueryRow(ctx, `
+		INSERT INTO api_keys (account_id, key_hash, key_prefix, last4, livemode, scopes, allowed_ips, publishable)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING `+apiKeyColumns,
+		old.AccountID, next.Hash, old.Prefix, next.Last4, old.Livemode, old.Scopes, old.AllowedIPs, old.Publishable))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// An expiry already sooner than the overlap window is kept
+	old, err = scanAPIKey(tx.QueryRow(ctx, `
+		UPDATE api_keys SET replaced_by = $2, expires_at = LEAST(COALESCE(expires_at, $3), $3)
+		WHERE id = $1 RETURNING `+apiKeyColumns, keyID, created.ID, time.Now().Add(overlap)))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// The new key was issued by the roll; the old one is recorded as rolled
+	if err := recordKeyEvent(ctx, tx, created, KeyEventIssued, actor); err != nil {
+		return nil, nil, err
+	}
+	if err := recordKeyEvent(ctx, tx, old, KeyEventRolled, actor); err != nil {
+		return nil, nil, err
+	}
+
+	return created, old, tx.Commit(ctx)
+}
+
+func lockAPIKey(ctx context.Context, tx pgx.Tx, accountID, keyID uuid.UUID) (*APIKey, error) {
+	return scanAPIKey(tx.QueryRow(ctx, `
+		SELECT `+apiKeyColumns+` FROM api_keys WHERE id = $1 AND account_id = $2 FOR UPDATE`, keyID, accountID))
+}
@@ -0,0 +1,157 @@
+package storage
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// UnbalancedTransaction is a transaction whose DEBIT and CREDIT entries don't cancel out
+// in some currency. Transactions without any entries are reported with zero totals.
+type UnbalancedTransaction struct {
+	TransactionID uuid.UUID `json:"transaction_id"`
+	Currency      string    `json:"currency"`
+	Debits        int64     `json:"debits"`
+	Credits       int64     `json:"credits"`
+}
+
+// BalanceMismatch is an account whose stored balance disagrees with its entries
+type BalanceMismatch struct {
+	AccountID     uuid.UUID `json:"account_id"`
+	StoredBalance int64     `json:"stored_balance"`
+	EntryBalance  int64     `json:"entry_balance"`
+}
+
+// OrphanEntry is an entry pointing at a transaction or account that doesn't exist
+type OrphanEntry struct {
+	TransactionID uuid.UUID `json:"transaction_id"`
+	AccountID     uuid.UUID `json:"account_id"`
+	Direction     string    `json:"direction"`
+	Amount        int64     `json:"amount"`
+	Missing       string    `json:"missing"` // "transaction" or "account"
+}
+
+// AuditReport is the result of CheckLedger
+type AuditReport struct {
+	TransactionsChecked    int64                   `json:"transactions_checked"`
+	AccountsChecked        int64                   `json:"accounts_checked"`
+	UnbalancedTransactions []UnbalancedTransaction `json:"unbalanced_transactions"`
+	BalanceMismatches      []BalanceMismatch       `json:"balance_mismatches"`
+	OrphanEntries          []OrphanEntry           `json:"orphan_entries"`
+}
+
+// OK reports whether the ledger passed every check
+func (r *AuditReport) OK() bool {
+	return len(r.UnbalancedTransactions) == 0 && len(r.BalanceMismatches) == 0 && len(r.OrphanEntries) == 0
+}
+
+// CheckLedger verifies the double-entry invariants:
+//  1. every transaction has entries, and per currency its debits equal its credits
+//  2. every account's balance equals the sum of its entries (credits - debits)
+//  3. no entry references a missing transaction or account
+//
+// It runs in a single REPEATABLE READ transaction so all checks see the same snapshot.
+func CheckLedger(ctx context.Context, db *pgxpool.Pool) (*AuditReport, error) {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `SET TRANSACTION ISOLATION LEVEL REPEATABLE READ READ ONLY`); err != nil {
+		return nil, err
+	}
+
+	report := &AuditReport{
+		UnbalancedTransactions: []UnbalancedTransaction{},
+		BalanceMismatches:      []BalanceMismatch{},
+		OrphanEntries:          []OrphanEntry{},
+	}
+
+	if err := tx.QueryRow(ctx, `SELECT COUNT(*) FROM transactions`).Scan(&report.TransactionsChecked); err != nil {
+		return nil, err
+	}
+	if err := tx.QueryRow(ctx, `SELECT COUNT(*) FROM accounts`).Scan(&report.AccountsChecked); err != nil {
+		return nil, err
+	}
+
+	// 1. Balanced transactions
+	rows, err := tx.Query(ctx, `
+		SELECT t.id, COALESCE(e.currency, t.currency),
+			COALESCE(SUM(e.amount) FILTER (WHERE e.direction = 'DEBIT'), 0),
+			COALESCE(SUM(e.amount) FILTER (WHERE e.direction = 'CREDIT'), 0)
+		FROM transactions t
+		LEFT JOIN entries e ON e.transaction_id = t.id
+		GROUP BY t.id, COALESCE(e.currency, t.currency)
+		HAVING COUNT(e.transaction_id) = 0
+			OR COALESCE(SUM(e.amount) FILTER (WHERE e.direction = 'DEBIT'), 0)
+			<> COALESCE(SUM(e.amount) FILTER (WHERE e.direction = 'CREDIT'), 0)
+		ORDER BY t.id`)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var u UnbalancedTransaction
+		if err := rows.Scan(&u.TransactionID, &u.Currency, &u.Debits, &u.Credits); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		report.UnbalancedTransactions = append(report.UnbalancedTransactions, u)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// 2. Stored balances match entries
+	rows, err = tx.Query(ctx, `
+		SELECT a.id, a.balance,
+			COALESCE(SUM(CASE WHEN e.direction = 'CREDIT' THEN e.amount ELSE -e.amount END), 0) AS entry_balance
+		FROM accounts a
+		LEFT JOIN entries e ON e.account_id = a.id
+		GROUP BY a.id, a.balance
+		HAVING a.balance <> COALESCE(SUM(CASE WHEN e.direction = 'CREDIT' THEN e.amount ELSE -e.amount END), 0)
+		ORDER BY a.id`)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var m BalanceMismatch
+		if err := rows.Scan(&m.AccountID, &m.StoredBalance, &m.EntryBalance); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		report.BalanceMismatches = append(report.BalanceMismatches, m)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// 3. Orphans
+	rows, err = tx.Query(ctx, `
+		SELECT e.transaction_id, e.account_id, e.direction, e.amount,
+			CASE WHEN t.id IS NULL THEN 'transaction' ELSE 'account' END
+		FROM entries e
+		LEFT JOIN transactions t ON t.id = e.transaction_id
+		LEFT JOIN accounts a ON a.id = e.account_id
+		WHERE t.id IS NULL OR a.id IS NULL`)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var o OrphanEntry
+		if err := rows.Scan(&o.TransactionID, &o.AccountID, &o.Direction, &o.Amount, &o.Missing); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		report.OrphanEntries = append(report.OrphanEntries, o)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
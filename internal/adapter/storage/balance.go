@@ -0,0 +1,106 @@
+package storage
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// Balance splits an account's money three ways:
+//   - Ledger: everything booked (sum of entries), plus money moved to HOLDS by its own holds
+//   - Pending: reserved by authorized holds
+//   - Available: own funds that are free (Ledger - Pending), negative while overdrawn
+//
+// CreditLimit is the overdraft or credit line on top, and Spendable what can be sent
+// right now (Available + CreditLimit).
+type Balance struct {
+	AccountID   uuid.UUID `json:"account_id,omitempty"`
+	Currency    string    `json:"currency"`
+	Ledger      int64     `json:"ledger"`
+	Available   int64     `json:"available"`
+	Pending     int64     `json:"pending"`
+	CreditLimit int64     `json:"credit_limit"`
+	Spendable   int64     `json:"spendable"`
+	AsOf        time.Time `json:"as_of"`
+}
+
+// GetBalance returns an account's balance now, or at asOf when it is set.
+// Past balances are rebuilt from balance snapshots, entries and hold history
+// rather than the stored balance.
+func (r *LedgerRepository) GetBalance(ctx context.Context, accountID uuid.UUID, asOf *time.Time) (*Balance, error) {
+	b := Balance{AccountID: accountID, AsOf: time.Now()}
+	if asOf != nil {
+		b.AsOf = *asOf
+	}
+
+	err := r.Db.QueryRow(ctx, `SELECT balance, currency, `+creditAllowanceSQL+` FROM accounts WHERE id = $1`, accountID).
+		Scan(&b.Ledger, &b.Currency, &b.CreditLimit)
+	if err == pgx.ErrNoRows {
+		return nil, ErrAccountNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if asOf != nil {
+		// as_of is inclusive; timestamps are stored with microsecond precision
+		if b.Ledger, err = balanceBefore(ctx, r.Db, accountID, asOf.Add(time.Microsecond)); err != nil {
+			return nil, err
+		}
+	}
+
+	// A hold counts as pending from creation until it is resolved (captured/voided/expired
+	// rows record the resolution time in updated_at). Older holds also stop at their expiry;
+	// newer ones have moved their money to HOLDS until it is captured or released.
+	var booked int64
+	err = r.Db.QueryRow(ctx, `
+		SELECT
+			COALESCE(SUM(amount) FILTER (WHERE authorization_transaction_id IS NULL AND expires_at > $2), 0),
+			COALESCE(SUM(amount) FILTER (WHERE authorization_transaction_id IS NOT NULL), 0)
+		FROM holds
+		WHERE account_id = $1
+			AND created_at <= $2
+			AND (status = 'AUTHORIZED' OR updated_at > $2)`, accountID, b.AsOf).Scan(&b.Pending, &booked)
+	if err != nil {
+		return nil, err
+	}
+	// Held money is still the account's until it is captured
+	b.Ledger += booked
+	b.Pending += booked
+
+	b.Available = b.Ledger - b.Pending
+	b.Spendable = b.Available + b.CreditLimit
+	return &b, nil
+}
+
+// GetBalances totals several accounts' balances, one line per currency, sorted by currency
+func (r *LedgerRepository) GetBalances(ctx context.Context, accountIDs []uuid.UUID, asOf *time.Time) ([]Balance, error) {
+	byCurrency := make(map[string]*Balance)
+	for _, id := range accountIDs {
+		b, err := r.GetBalance(ctx, id, asOf)
+		if err != nil {
+			return nil, err
+		}
+
+		total, ok := byCurrency[b.Currency]
+		if !ok {
+			total = &Balance{Currency: b.Currency, AsOf: b.AsOf}
+			byCurrency[b.Currency] = total
+		}
+		total.Ledger += b.Ledger
+		total.Pending += b.Pending
+		total.Available += b.Available
+		total.CreditLimit += b.CreditLimit
+		total.Spendable += b.Spendable
+	}
+
+	balances := make([]Balance, 0, len(byCurrency))
+	for _, b := range byCurrency {
+		balances = append(balances, *b)
+	}
+	sort.Slice(balances, func(i, j int) bool { return balances[i].Currency < balances[j].Currency })
+	return balances, nil
+}
@@ -0,0 +1,119 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// AccountType decides which limit lets an account go negative
+type AccountType string
+
+const (
+	AccountStandard   AccountType = "STANDARD"    // Own funds, plus an optional overdraft
+	AccountCreditLine AccountType = "CREDIT_LINE" // Pays out ahead of settlement, up to its credit limit
+)
+
+var ErrInvalidCreditTerms = errors.New("invalid credit terms")
+
+// creditAllowanceSQL is how far below zero an account may go
+const creditAllowanceSQL = `CASE WHEN account_type = 'CREDIT_LINE' THEN credit_limit ELSE overdraft_limit END`
+
+// CreditTerms are the overdraft / credit line settings of an account, in minor units
+type CreditTerms struct {
+	AccountType     AccountType `json:"account_type"`
+	OverdraftLimit  int64       `json:"overdraft_limit"`
+	CreditLimit     int64       `json:"credit_limit"`
+	GracePeriodDays int         `json:"grace_period_days"`
+}
+
+// Validate checks the terms make sense together
+func (t CreditTerms) Validate() error {
+	switch {
+	case t.AccountType != AccountStandard && t.AccountType != AccountCreditLine:
+		return fmt.Errorf("%w: account_type must be STANDARD or CREDIT_LINE", ErrInvalidCreditTerms)
+	case t.OverdraftLimit < 0 || t.CreditLimit < 0 || t.GracePeriodDays < 0:
+		return fmt.Errorf("%w: limits and grace period cannot be negative", ErrInvalidCreditTerms)
+	case t.AccountType == AccountCreditLine && t.CreditLimit == 0:
+		return fmt.Errorf("%w: a credit line needs a credit_limit", ErrInvalidCreditTerms)
+	case t.AccountType == AccountStandard && t.CreditLimit != 0:
+		return fmt.Errorf("%w: credit_limit only applies to CREDIT_LINE accounts (use overdraft_limit)", ErrInvalidCreditTerms)
+	}
+	return nil
+}
+
+// SetCreditTerms changes an account's overdraft / credit line and queues an account.updated
+// webhook. Lowering a limit never moves money: an account already below the new floor simply
+// can't spend until it is repaid.
+func (r *AccountRepository) SetCreditTerms(ctx context.Context, id uuid.UUID, terms CreditTerms) (*Account, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	var system bool
+	err = tx.QueryRow(ctx, `SELECT system_kind IS NOT NULL FROM accounts WHERE id = $1 FOR UPDATE`, id).Scan(&system)
+	if err == pgx.ErrNoRows {
+		return nil, ErrAccountNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if system {
+		return nil, ErrSystemAccount
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE accounts SET account_type = $1, overdraft_limit = $2, credit_limit = $3, grace_period_days = $4
+		WHERE id = $5`,
+		terms.AccountType, terms.OverdraftLimit, terms.CreditLimit, terms.GracePeriodDays, id)
+	if err != nil {
+		return nil, err
+	}
+
+	err = enqueueWebhook(ctx, tx, "account.updated", map[string]interface{}{
+		"account_id":        id,
+		"account_type":      terms.AccountType,
+		"overdraft_limit":   terms.OverdraftLimit,
+		"credit_limit":      terms.CreditLimit,
+		"grace_period_days": terms.GracePeriodDays,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	account, err := scanAccount(tx.QueryRow(ctx, `SELECT `+accountColumns+` FROM accounts WHERE id = $1`, id))
+	if err != nil {
+		return nil, err
+	}
+
+	return account, tx.Commit(ctx)
+}
+
+// OverdrawnAccounts lists accounts whose grace period ran out before now, oldest first
+func (r *AccountRepository) OverdrawnAccounts(ctx context.Context, now time.Time) ([]Account, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT `+accountColumns+` FROM accounts
+		WHERE overdrawn_since IS NOT NULL
+			AND overdrawn_since + make_interval(days => grace_period_days) < $1
+		ORDER BY overdrawn_since`, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	accounts := []Account{}
+	for rows.Next() {
+		acc, err := scanAccount(rows)
+		if err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, *acc)
+	}
+	return accounts, rows.Err()
+}
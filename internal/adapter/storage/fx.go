@@ -0,0 +1,199 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/ibrahimkeyboad/gopay/internal/core/domain"
+	"github.com/ibrahimkeyboad/gopay/internal/core/fx"
+)
+
+var (
+	ErrQuoteNotFound = errors.New("fx quote not found")
+	ErrQuoteUsed     = errors.New("fx quote has already been used")
+	ErrQuoteExpired  = errors.New("fx quote has expired")
+)
+
+// FXRateRepository serves exchange rates from the fx_rates table
+type FXRateRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewFXRateRepository(db *pgxpool.Pool) *FXRateRepository {
+	return &FXRateRepository{db: db}
+}
+
+// Rate implements fx.RateSource
+func (r *FXRateRepository) Rate(ctx context.Context, base, quote domain.Currency) (*big.Rat, error) {
+	var value string
+	err := r.db.QueryRow(ctx, `
+		SELECT rate::text FROM fx_rates WHERE base_currency = $1 AND quote_currency = $2`,
+		base, quote).Scan(&value)
+	if err == pgx.ErrNoRows {
+		return nil, fmt.Errorf("%w: %s/%s", fx.ErrRateNotFound, base, quote)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rate, ok := new(big.Rat).SetString(value)
+	if !ok {
+		return nil, fmt.Errorf("invalid rate %q stored for %s/%s", value, base, quote)
+	}
+	return rate, nil
+}
+
+// FXQuote is a persisted fx.Quote that belongs to the account converting money
+type FXQuote struct {
+	ID            uuid.UUID  `json:"id"`
+	AccountID     uuid.UUID  `json:"account_id"`
+	FromCurrency  string     `json:"from_currency"`
+	FromAmount    int64      `json:"from_amount"`
+	ToCurrency    string     `json:"to_currency"`
+	ToAmount      int64      `json:"to_amount"`
+	MidRate       string     `json:"mid_rate"`
+	Rate          string     `json:"rate"`
+	SpreadBps     int64      `json:"spread_bps"`
+	ExpiresAt     time.Time  `json:"expires_at"`
+	TransactionID *uuid.UUID `json:"transaction_id,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+const fxQuoteColumns = `id, account_id, from_currency, from_amount, to_currency, to_amount, mid_rate, rate, spread_bps, expires_at, transaction_id, created_at`
+
+func scanFXQuote(row pgx.Row) (*FXQuote, error) {
+	var q FXQuote
+	err := row.Scan(&q.ID, &q.AccountID, &q.FromCurrency, &q.FromAmount, &q.ToCurrency, &q.ToAmount,
+		&q.MidRate, &q.Rate, &q.SpreadBps, &q.ExpiresAt, &q.TransactionID, &q.CreatedAt)
+	if err == pgx.ErrNoRows {
+		return nil, ErrQuoteNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &q, nil
+}
+
+// SaveQuote locks a quote for accountID so it can be booked later
+func (r *LedgerRepository) SaveQuote(ctx context.Context, accountID uuid.UUID, quote *fx.Quote) (*FXQuote, error) {
+	return scanFXQuote(r.Db.QueryRow(ctx, `
+		INSERT INTO fx_quotes (account_id, from_currency, from_amount, to_currency, to_amount, mid_rate, rate, spread_bps, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING `+fxQuoteColumns,
+		accountID, quote.From.Currency, quote.From.Amount, quote.To.Currency, quote.To.Amount,
+		quote.MidRate.FloatString(10), quote.Rate.FloatString(10), quote.SpreadBps, quote.ExpiresAt))
+}
+
+// GetQuote fetches a single quote
+func (r *LedgerRepository) GetQuote(ctx context.Context, id uuid.UUID) (*FXQuote, error) {
+	return scanFXQuote(r.Db.QueryRow(ctx, `SELECT `+fxQuoteColumns+` FROM fx_quotes WHERE id = $1`, id))
+}
+
+// Convert books a quote as a four-leg transaction through the FX clearing accounts:
+//
+//	DEBIT  fromID           (from currency)
+//	CREDIT FX clearing      (from currency)
+//	DEBIT  FX clearing      (to currency)
+//	CREDIT toID             (to currency)
+//
+// The quote's locked amounts are used, never a fresh rate.
+func (r *LedgerRepository) Convert(ctx context.Context, quoteID, fromID, toID uuid.UUID) (*Transaction, error) {
+	tx, err := r.Db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	quote, err := scanFXQuote(tx.QueryRow(ctx, `SELECT `+fxQuoteColumns+` FROM fx_quotes WHERE id = $1 FOR UPDATE`, quoteID))
+	if err != nil {
+		return nil, err
+	}
+	if quote.TransactionID != nil {
+		return nil, ErrQuoteUsed
+	}
+	if !quote.ExpiresAt.After(time.Now()) {
+		return nil, ErrQuoteExpired
+	}
+	if quote.AccountID != fromID {
+		return nil, fmt.Errorf("quote was issued for a different source account")
+	}
+
+	from := domain.NewMoney(quote.FromAmount, domain.Currency(quote.FromCurrency))
+	to := domain.NewMoney(quote.ToAmount, domain.Currency(quote.ToCurrency))
+
+	source, err := lockAccount(ctx, tx, fromID)
+	if err != nil {
+		return nil, err
+	}
+	if source.Currency != from.Currency {
+		return nil, fmt.Errorf("%w: source account holds %s but quote sells %s", ErrCurrencyMismatch, source.Currency, from.Currency)
+	}
+
+	toCurrency, err := destinationCurrency(ctx, tx, toID)
+	if err != nil {
+		return nil, err
+	}
+	if toCurrency != to.Currency {
+		return nil, fmt.Errorf("%w: destination account holds %s but quote buys %s", ErrCurrencyMismatch, toCurrency, to.Currency)
+	}
+
+	pending, err := pendingBalance(ctx, tx, fromID)
+	if err != nil {
+		return nil, err
+	}
+	if available := source.spendable(pending); available < from.Amount {
+		return nil, fmt.Errorf("%w: you have %d available but tried to convert %d", ErrInsufficientFunds, available, from.Amount)
+	}
+
+	clearingFrom, err := systemAccount(ctx, tx, SystemFXClearing, from.Currency, source.Livemode)
+	if err != nil {
+		return nil, err
+	}
+	clearingTo, err := systemAccount(ctx, tx, SystemFXClearing, to.Currency, source.Livemode)
+	if err != nil {
+		return nil, err
+	}
+
+	var transactionID uuid.UUID
+	err = tx.QueryRow(ctx, `
+		INSERT INTO transactions (amount, currency, description, status, livemode)
+		VALUES ($1, $2, $3, 'COMPLETED', $4) RETURNING id`,
+		from.Amount, from.Currency, fmt.Sprintf("FX Conversion %s->%s @ %s", from.Currency, to.Currency, quote.Rate), source.Livemode).Scan(&transactionID)
+	if err != nil {
+		return nil, err
+	}
+
+	legs := []struct {
+		account   uuid.UUID
+		direction string
+		amount    domain.Money
+	}{
+		{fromID, "DEBIT", from},
+		{clearingFrom, "CREDIT", from},
+		{clearingTo, "DEBIT", to},
+		{toID, "CREDIT", to},
+	}
+	for _, leg := range legs {
+		if err := postLimitedEntry(ctx, tx, transactionID, leg.account, leg.direction, leg.amount); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := tx.Exec(ctx, `UPDATE fx_quotes SET transaction_id = $1 WHERE id = $2`, transactionID, quoteID); err != nil {
+		return nil, err
+	}
+
+	txn, err := getTransaction(ctx, tx, transactionID, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return txn, tx.Commit(ctx)
+}
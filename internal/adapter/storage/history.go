@@ -0,0 +1,155 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// History page sizes
+const (
+	DefaultHistoryLimit = 10
+	MaxHistoryLimit     = 100
+)
+
+// HistoryFilter narrows an account's history. Zero values mean "no filter".
+type HistoryFilter struct {
+	StartingAfter *uuid.UUID // Cursor: the entry_id of the previous page's last item
+	Limit         int
+	CreatedFrom   *time.Time // Inclusive
+	CreatedTo     *time.Time // Exclusive
+	Direction     string     // "CREDIT" or "DEBIT"
+	Status        string
+	MinAmount     *int64
+	MaxAmount     *int64
+	Description   string // Case-insensitive substring
+}
+
+// HistoryItem is one movement on an account, seen from that account's side
+type HistoryItem struct {
+	ID          uuid.UUID `json:"id"` // Transaction ID
+	EntryID     uuid.UUID `json:"entry_id"`
+	Amount      int64     `json:"amount"`
+	Currency    string    `json:"currency"`
+	Description string    `json:"description"`
+	Status      string    `json:"status"`
+	Direction   string    `json:"direction"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// HistoryPage is one page of history, newest first
+type HistoryPage struct {
+	Items   []HistoryItem `json:"transactions"`
+	HasMore bool          `json:"has_more"`
+}
+
+// GetHistory returns an account's entries newest first, ordered by (created_at, transaction
+// id, entry id) so pages are stable even when many transactions share a timestamp, and a
+// page boundary inside a transaction with several entries on the account drops none of them.
+func (r *LedgerRepository) GetHistory(ctx context.Context, accountID uuid.UUID, f HistoryFilter) (*HistoryPage, error) {
+	limit := f.Limit
+	if limit <= 0 {
+		limit = DefaultHistoryLimit
+	}
+	if limit > MaxHistoryLimit {
+		limit = MaxHistoryLimit
+	}
+
+	conditions := []string{"e.account_id = $1"}
+	args := []any{accountID}
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if f.StartingAfter != nil {
+		// Resolve the cursor to its sort key; an unknown cursor is a client error
+		var cursorTime time.Time
+		var cursorTransaction uuid.UUID
+		err := r.Db.QueryRow(ctx, `
+			SELECT t.created_at, t.id FROM entries e JOIN transactions t ON e.transaction_id = t.id
+			WHERE e.id = $1 AND e.account_id = $2`, *f.StartingAfter, accountID).Scan(&cursorTime, &cursorTransaction)
+		switch {
+		case err == nil:
+			conditions = append(conditions, fmt.Sprintf("(t.created_at, t.id, e.id) < (%s, %s, %s)",
+				arg(cursorTime), arg(cursorTransaction), arg(*f.StartingAfter)))
+		case err == pgx.ErrNoRows:
+			// A transaction ID, as cursors were before entries had their own: skip the whole
+			// transaction. Like an entry cursor, it must be one of this account's.
+			err = r.Db.QueryRow(ctx, `
+				SELECT t.created_at FROM transactions t
+				WHERE t.id = $1 AND EXISTS (SELECT 1 FROM entries e WHERE e.transaction_id = t.id AND e.account_id = $2)`,
+				*f.StartingAfter, accountID).Scan(&cursorTime)
+			if err == pgx.ErrNoRows {
+				return nil, fmt.Errorf("invalid starting_after: %w", ErrTransactionNotFound)
+			}
+			if err != nil {
+				return nil, err
+			}
+			conditions = append(conditions, fmt.Sprintf("(t.created_at, t.id) < (%s, %s)", arg(cursorTime), arg(*f.StartingAfter)))
+		default:
+			return nil, err
+		}
+	}
+	if f.CreatedFrom != nil {
+		conditions = append(conditions, "t.created_at >= "+arg(*f.CreatedFrom))
+	}
+	if f.CreatedTo != nil {
+		conditions = append(conditions, "t.created_at < "+arg(*f.CreatedTo))
+	}
+	if f.Direction != "" {
+		conditions = append(conditions, "e.direction = "+arg(f.Direction))
+	}
+	if f.Status != "" {
+		conditions = append(conditions, "t.status = "+arg(f.Status))
+	}
+	if f.MinAmount != nil {
+		conditions = append(conditions, "e.amount >= "+arg(*f.MinAmount))
+	}
+	if f.MaxAmount != nil {
+		conditions = append(conditions, "e.amount <= "+arg(*f.MaxAmount))
+	}
+	if f.Description != "" {
+		// Escape LIKE wildcards so the filter is a plain substring match
+		escaped := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(f.Description)
+		conditions = append(conditions, "t.description ILIKE "+arg("%"+escaped+"%"))
+	}
+
+	// Fetch one extra row to know whether another page exists
+	query := `
+		SELECT t.id, e.id, e.amount, e.currency, t.description, t.status, e.direction, t.created_at
+		FROM entries e
+		JOIN transactions t ON e.transaction_id = t.id
+		WHERE ` + strings.Join(conditions, " AND ") + `
+		ORDER BY t.created_at DESC, t.id DESC, e.id DESC
+		LIMIT ` + arg(limit+1)
+
+	rows, err := r.Db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	page := &HistoryPage{Items: []HistoryItem{}}
+	for rows.Next() {
+		var item HistoryItem
+		if err := rows.Scan(&item.ID, &item.EntryID, &item.Amount, &item.Currency, &item.Description, &item.Status, &item.Direction, &item.CreatedAt); err != nil {
+			return nil, err
+		}
+		page.Items = append(page.Items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(page.Items) > limit {
+		page.Items = page.Items[:limit]
+		page.HasMore = true
+	}
+
+	return page, nil
+}
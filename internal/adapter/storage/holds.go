@@ -0,0 +1,526 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/ibrahimkeyboad/gopay/internal/core/domain"
+	"github.com/ibrahimkeyboad/gopay/internal/core/pricing"
+)
+
+// Hold statuses
+const (
+	HoldAuthorized = "AUTHORIZED"
+	HoldCaptured   = "CAPTURED"
+	HoldVoided     = "VOIDED"
+	HoldExpired    = "EXPIRED"
+)
+
+var (
+	ErrHoldNotFound      = errors.New("hold not found")
+	ErrHoldNotCapturable = errors.New("hold is no longer authorized")
+	ErrHoldExpired       = errors.New("hold has expired")
+	ErrCaptureTooLarge   = errors.New("capture amount exceeds authorized amount")
+)
+
+// Hold reserves money on AccountID for DestinationAccountID. Authorizing moves the money to
+// the HOLDS system account, so the payer can't spend it and the reservation shows in the
+// ledger; capturing moves it on to the destination and voiding or expiry gives it back.
+// For a card hold (see AuthorizeCardHold) the payer is the CARD_SETTLEMENT account.
+type Hold struct {
+	ID                   uuid.UUID `json:"id"`
+	AccountID            uuid.UUID `json:"account_id"`
+	DestinationAccountID uuid.UUID `json:"destination_account_id"`
+	Amount               int64     `json:"amount"`
+	CapturedAmount       int64     `json:"captured_amount"`
+	Currency             string    `json:"currency"`
+	Description          string    `json:"description"`
+	Status               string    `json:"status"`
+	ExpiresAt            time.Time `json:"expires_at"`
+	CreatedAt            time.Time `json:"created_at"`
+
+	// Nil on holds authorized before holds were booked in the ledger
+	AuthorizationTransactionID *uuid.UUID `json:"authorization_transaction_id,omitempty"`
+	ReleaseTransactionID       *uuid.UUID `json:"release_transaction_id,omitempty"` // Whatever was given back to the payer
+}
+
+const holdColumns = `id, account_id, destination_account_id, amount, captured_amount, currency, description, status, expires_at, created_at,
+	authorization_transaction_id, release_transaction_id`
+
+func scanHold(row pgx.Row) (*Hold, error) {
+	var h Hold
+	err := row.Scan(&h.ID, &h.AccountID, &h.DestinationAccountID, &h.Amount, &h.CapturedAmount,
+		&h.Currency, &h.Description, &h.Status, &h.ExpiresAt, &h.CreatedAt,
+		&h.AuthorizationTransactionID, &h.ReleaseTransactionID)
+	if err == pgx.ErrNoRows {
+		return nil, ErrHoldNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &h, nil
+}
+
+// booked reports whether the hold's money sits in the HOLDS account (false for older holds)
+func (h *Hold) booked() bool {
+	return h.AuthorizationTransactionID != nil
+}
+
+// pendingBalance sums the active holds on an account whose money is still in it: holds from
+// before holds were booked in the ledger. Newer holds have already left the balance.
+// Callers should already hold the account row lock (SELECT ... FOR UPDATE).
+func pendingBalance(ctx context.Context, tx pgx.Tx, accountID uuid.UUID) (int64, error) {
+	var pending int64
+	err := tx.QueryRow(ctx, `
+		SELECT COALESCE(SUM(amount), 0) FROM holds
+		WHERE account_id = $1 AND status = 'AUTHORIZED' AND expires_at > NOW() AND authorization_transaction_id IS NULL`, accountID).Scan(&pending)
+	return pending, err
+}
+
+// AuthorizeHold reserves amount on accountID for later capture by destinationID.
+func (r *LedgerRepository) AuthorizeHold(ctx context.Context, accountID, destinationID uuid.UUID, amount domain.Money, description string, ttl time.Duration) (*Hold, error) {
+	if amount.Amount <= 0 {
+		return nil, fmt.Errorf("hold amount must be positive")
+	}
+
+	tx, err := r.Db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	// Lock the account so concurrent holds and transfers see the same available balance
+	account, err := lockAccount(ctx, tx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	currency := account.Currency
+
+	// Captures never convert, so both sides must share a currency
+	destinationCurrency, err := destinationCurrency(ctx, tx, destinationID)
+	if err != nil {
+		return nil, err
+	}
+	if currency != amount.Currency {
+		return nil, fmt.Errorf("%w: account holds %s but hold is in %s", ErrCurrencyMismatch, currency, amount.Currency)
+	}
+	if destinationCurrency != currency {
+		return nil, fmt.Errorf("%w: cannot hold %s for a %s account", ErrCurrencyMismatch, currency, destinationCurrency)
+	}
+	if err := sameMode(ctx, tx, accountID, destinationID); err != nil {
+		return nil, err
+	}
+
+	pending, err := pendingBalance(ctx, tx, accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	if available := account.spendable(pending); available < amount.Amount {
+		return nil, fmt.Errorf("%w: you have %d available but tried to hold %d", ErrInsufficientFunds, available, amount.Amount)
+	}
+
+	// Limits are checked on the authorization rather than at capture; capture checks the destination
+	hold, err := insertHold(ctx, tx, accountID, destinationID, amount, description, ttl, account.Livemode)
+	if err != nil {
+		return nil, err
+	}
+
+	return hold, tx.Commit(ctx)
+}
+
+// AuthorizeCardHold reserves a card payment for merchantID: the card network has approved it,
+// but nothing reaches the merchant until the hold is captured (see CaptureCardHold).
+func (r *LedgerRepository) AuthorizeCardHold(ctx context.Context, merchantID uuid.UUID, amount domain.Money, description string, ttl time.Duration) (*Hold, error) {
+	if amount.Amount <= 0 {
+		return nil, fmt.Errorf("hold amount must be positive")
+	}
+
+	tx, err := r.Db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	hold, err := authorizeCardHold(ctx, tx, merchantID, amount, description, ttl)
+	if err != nil {
+		return nil, err
+	}
+	return hold, tx.Commit(ctx)
+}
+
+// authorizeCardHold books an AuthorizeCardHold inside tx
+func authorizeCardHold(ctx context.Context, tx pgx.Tx, merchantID uuid.UUID, amount domain.Money, description string, ttl time.Duration) (*Hold, error) {
+	currency, err := destinationCurrency(ctx, tx, merchantID)
+	if err != nil {
+		return nil, err
+	}
+	if currency != amount.Currency {
+		return nil, fmt.Errorf("%w: account holds %s but payment is in %s", ErrCurrencyMismatch, currency, amount.Currency)
+	}
+	livemode, err := accountLivemode(ctx, tx, merchantID)
+	if err != nil {
+		return nil, err
+	}
+	settlementID, err := systemAccount(ctx, tx, SystemCardSettlement, amount.Currency, livemode)
+	if err != nil {
+		return nil, err
+	}
+	return insertHold(ctx, tx, settlementID, merchantID, amount, description, ttl, livemode)
+}
+
+// insertHold records a hold and books its authorization: DEBIT the payer, CREDIT HOLDS
+func insertHold(ctx context.Context, tx pgx.Tx, payerID, destinationID uuid.UUID, amount domain.Money, description string, ttl time.Duration, livemode bool) (*Hold, error) {
+	var authorizationID uuid.UUID
+	err := tx.QueryRow(ctx, `
+		INSERT INTO transactions (amount, currency, description, status, livemode)
+		VALUES ($1, $2, $3, 'COMPLETED', $4) RETURNING id`,
+		amount.Amount, amount.Currency, "Hold Authorization: "+description, livemode).Scan(&authorizationID)
+	if err != nil {
+		return nil, err
+	}
+
+	holdsID, err := systemAccount(ctx, tx, SystemHolds, amount.Currency, livemode)
+	if err != nil {
+		return nil, err
+	}
+	if err := postLimitedEntry(ctx, tx, authorizationID, payerID, "DEBIT", amount); err != nil {
+		return nil, err
+	}
+	if err := postEntry(ctx, tx, authorizationID, holdsID, "CREDIT", amount); err != nil {
+		return nil, err
+	}
+
+	return scanHold(tx.QueryRow(ctx, `
+		INSERT INTO holds (account_id, destination_account_id, amount, currency, description, expires_at, authorization_transaction_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING `+holdColumns,
+		payerID, destinationID, amount.Amount, amount.Currency, description, time.Now().Add(ttl), authorizationID))
+}
+
+// GetHold fetches a single hold
+func (r *LedgerRepository) GetHold(ctx context.Context, id uuid.UUID) (*Hold, error) {
+	return scanHold(r.Db.QueryRow(ctx, `SELECT `+holdColumns+` FROM holds WHERE id = $1`, id))
+}
+
+// CaptureHold moves amount (or the full hold when amount is 0) to the destination.
+// Any uncaptured remainder goes back to the payer. A card hold is booked like a card
+// payment, processing fee included.
+func (r *LedgerRepository) CaptureHold(ctx context.Context, id uuid.UUID, amount int64) (*Hold, error) {
+	tx, err := r.Db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	hold, err := lockCapturableHold(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if amount == 0 {
+		amount = hold.Amount
+	}
+	if amount < 0 || amount > hold.Amount {
+		return nil, ErrCaptureTooLarge
+	}
+
+	captured := domain.NewMoney(amount, domain.Currency(hold.Currency))
+	hold, _, err = r.captureHold(ctx, tx, hold, []Credit{{AccountID: hold.DestinationAccountID, Amount: captured}},
+		domain.NewMoney(0, captured.Currency))
+	if err != nil {
+		return nil, err
+	}
+
+	return hold, tx.Commit(ctx)
+}
+
+// CaptureCardHold captures a card hold, split over credits plus an optional platform fee the
+// way DepositSplit books a payment. credits[0] must be the hold's destination.
+func (r *LedgerRepository) CaptureCardHold(ctx context.Context, id uuid.UUID, credits []Credit, fee domain.Money) (*Hold, *Receipt, error) {
+	tx, err := r.Db.Begin(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	hold, err := lockCapturableHold(ctx, tx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	hold, receipt, err := r.captureHold(ctx, tx, hold, credits, fee)
+	if err != nil {
+		return nil, nil, err
+	}
+	return hold, receipt, tx.Commit(ctx)
+}
+
+// lockCapturableHold locks an authorized hold. A hold found past its expiry is released and
+// recorded as EXPIRED, and tx is committed before ErrHoldExpired is returned.
+func lockCapturableHold(ctx context.Context, tx pgx.Tx, id uuid.UUID) (*Hold, error) {
+	hold, err := scanHold(tx.QueryRow(ctx, `SELECT `+holdColumns+` FROM holds WHERE id = $1 FOR UPDATE`, id))
+	if err != nil {
+		return nil, err
+	}
+	if hold.Status != HoldAuthorized {
+		return nil, ErrHoldNotCapturable
+	}
+	if !hold.ExpiresAt.After(time.Now()) {
+		// Record the expiry so the hold stops being offered for capture
+		if _, err := resolveHold(ctx, tx, hold, HoldExpired); err != nil {
+			return nil, err
+		}
+		if err := tx.Commit(ctx); err != nil {
+			return nil, err
+		}
+		return nil, ErrHoldExpired
+	}
+	return hold, nil
+}
+
+// captureHold books the capture of a locked, authorized hold and gives back what isn't captured.
+// Customer money goes to the destination as is; card money is booked with DepositSplit
+// (card pricing, splits and platform fee). The receipt is nil for customer holds.
+func (r *LedgerRepository) captureHold(ctx context.Context, tx pgx.Tx, hold *Hold, credits []Credit, fee domain.Money) (*Hold, *Receipt, error) {
+	if len(credits) == 0 {
+		return nil, nil, fmt.Errorf("a capture needs an account to credit")
+	}
+	total := fee
+	var err error
+	for _, c := range credits {
+		if total, err = total.Add(c.Amount); err != nil {
+			return nil, nil, err
+		}
+	}
+	if total.Currency != domain.Currency(hold.Currency) {
+		return nil, nil, fmt.Errorf("%w: hold is in %s but capture is in %s", ErrCurrencyMismatch, hold.Currency, total.Currency)
+	}
+	if total.Amount <= 0 || total.Amount > hold.Amount {
+		return nil, nil, ErrCaptureTooLarge
+	}
+	if credits[0].AccountID != hold.DestinationAccountID {
+		return nil, nil, fmt.Errorf("a hold can only be captured for its destination")
+	}
+
+	cardHold, err := isSystemAccount(ctx, tx, hold.AccountID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var receipt *Receipt
+	var transactionID uuid.UUID
+	switch {
+	case cardHold:
+		if receipt, err = r.depositSplit(ctx, tx, SystemHolds, pricing.MethodCard, credits, fee, "Card Payment: "+hold.Description); err != nil {
+			return nil, nil, err
+		}
+		transactionID = receipt.TransactionID
+		if _, err := tx.Exec(ctx, `UPDATE transactions SET hold_id = $1 WHERE id = $2`, hold.ID, transactionID); err != nil {
+			return nil, nil, err
+		}
+
+	default:
+		if len(credits) > 1 || fee.Amount != 0 {
+			return nil, nil, fmt.Errorf("only card holds can be captured with a split")
+		}
+		// A frozen or closed payer can no longer be charged
+		payer, err := lockAccount(ctx, tx, hold.AccountID)
+		if err != nil {
+			return nil, nil, err
+		}
+		err = tx.QueryRow(ctx, `
+			INSERT INTO transactions (amount, currency, description, status, hold_id, livemode)
+			VALUES ($1, $2, $3, 'COMPLETED', $4, $5) RETURNING id`,
+			total.Amount, hold.Currency, "Hold Capture: "+hold.Description, hold.ID, payer.Livemode).Scan(&transactionID)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		// Older holds still have their money on the payer's balance
+		from := hold.AccountID
+		if hold.booked() {
+			if from, err = systemAccount(ctx, tx, SystemHolds, total.Currency, payer.Livemode); err != nil {
+				return nil, nil, err
+			}
+		} else if available := payer.spendable(0); available < total.Amount {
+			// The hold itself is part of what's pending, so only the balance and credit allowance count
+			return nil, nil, fmt.Errorf("%w: you have %d but tried to capture %d", ErrInsufficientFunds, available, total.Amount)
+		}
+		if err := postLimitedEntry(ctx, tx, transactionID, from, "DEBIT", total); err != nil {
+			return nil, nil, err
+		}
+		if err := postLimitedEntry(ctx, tx, transactionID, hold.DestinationAccountID, "CREDIT", total); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if remainder := hold.Amount - total.Amount; remainder > 0 && hold.booked() {
+		if _, err := releaseHold(ctx, tx, hold, remainder); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	hold, err = scanHold(tx.QueryRow(ctx, `
+		UPDATE holds SET status = 'CAPTURED', captured_amount = $2, updated_at = NOW()
+		WHERE id = $1 RETURNING `+holdColumns, hold.ID, total.Amount))
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := finishHeldIntent(ctx, tx, hold.ID, IntentSucceeded, &transactionID, nil); err != nil {
+		return nil, nil, err
+	}
+	return hold, receipt, nil
+}
+
+// VoidHold releases an authorized hold: its money goes back to the payer.
+func (r *LedgerRepository) VoidHold(ctx context.Context, id uuid.UUID) (*Hold, error) {
+	tx, err := r.Db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	hold, err := scanHold(tx.QueryRow(ctx, `SELECT `+holdColumns+` FROM holds WHERE id = $1 FOR UPDATE`, id))
+	if err != nil {
+		return nil, err
+	}
+	// Already captured, voided or expired
+	if hold.Status != HoldAuthorized {
+		return nil, ErrHoldNotCapturable
+	}
+
+	hold, err = resolveHold(ctx, tx, hold, HoldVoided)
+	if err != nil {
+		return nil, err
+	}
+	return hold, tx.Commit(ctx)
+}
+
+// ExpireHolds releases authorized holds whose expiry has passed and flags them EXPIRED.
+// Older holds already stop counting towards the pending balance; this keeps their status honest.
+// A hold that can't be expired is logged and skipped so it doesn't hold up the rest; the
+// errors are returned together with the count.
+func (r *LedgerRepository) ExpireHolds(ctx context.Context) (int64, error) {
+	rows, err := r.Db.Query(ctx, `SELECT id FROM holds WHERE status = 'AUTHORIZED' AND expires_at <= NOW()`)
+	if err != nil {
+		return 0, err
+	}
+	ids, err := pgx.CollectRows(rows, pgx.RowTo[uuid.UUID])
+	if err != nil {
+		return 0, err
+	}
+
+	var expired int64
+	var errs []error
+	for _, id := range ids {
+		ok, err := r.expireHold(ctx, id)
+		if err != nil {
+			slog.Error("❌ Failed to expire hold", "error", err, "hold_id", id)
+			errs = append(errs, fmt.Errorf("hold %s: %w", id, err))
+			continue
+		}
+		if ok {
+			expired++
+		}
+	}
+	return expired, errors.Join(errs...)
+}
+
+// expireHold expires one hold, unless it was captured or voided since it was listed
+func (r *LedgerRepository) expireHold(ctx context.Context, id uuid.UUID) (bool, error) {
+	tx, err := r.Db.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	hold, err := scanHold(tx.QueryRow(ctx, `SELECT `+holdColumns+` FROM holds WHERE id = $1 FOR UPDATE`, id))
+	if err != nil {
+		return false, err
+	}
+	if hold.Status != HoldAuthorized || hold.ExpiresAt.After(time.Now()) {
+		return false, nil
+	}
+	if _, err := resolveHold(ctx, tx, hold, HoldExpired); err != nil {
+		return false, err
+	}
+	return true, tx.Commit(ctx)
+}
+
+// resolveHold ends a locked, authorized hold without capturing it (VOIDED or EXPIRED),
+// giving its money back to the payer and failing the payment intent waiting on it
+func resolveHold(ctx context.Context, tx pgx.Tx, hold *Hold, status string) (*Hold, error) {
+	if hold.booked() {
+		if _, err := releaseHold(ctx, tx, hold, hold.Amount); err != nil {
+			return nil, err
+		}
+	}
+	hold, err := scanHold(tx.QueryRow(ctx, `
+		UPDATE holds SET status = $2, updated_at = NOW()
+		WHERE id = $1 RETURNING `+holdColumns, hold.ID, status))
+	if err != nil {
+		return nil, err
+	}
+	reason := "Authorization " + strings.ToLower(status)
+	if err := finishHeldIntent(ctx, tx, hold.ID, IntentFailed, nil, &reason); err != nil {
+		return nil, err
+	}
+	return hold, nil
+}
+
+// releaseHold books amount of a hold back from HOLDS to the payer. The release points at the
+// authorization it reverses, so like a refund it isn't counted towards the payer's limits.
+func releaseHold(ctx context.Context, tx pgx.Tx, hold *Hold, amount int64) (uuid.UUID, error) {
+	livemode, err := accountLivemode(ctx, tx, hold.AccountID)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	var releaseID uuid.UUID
+	err = tx.QueryRow(ctx, `
+		INSERT INTO transactions (amount, currency, description, status, original_transaction_id, livemode)
+		VALUES ($1, $2, $3, 'COMPLETED', $4, $5) RETURNING id`,
+		amount, hold.Currency, "Hold Release: "+hold.Description, hold.AuthorizationTransactionID, livemode).Scan(&releaseID)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	released := domain.NewMoney(amount, domain.Currency(hold.Currency))
+	holdsID, err := systemAccount(ctx, tx, SystemHolds, released.Currency, livemode)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if err := postEntry(ctx, tx, releaseID, holdsID, "DEBIT", released); err != nil {
+		return uuid.Nil, err
+	}
+	if err := postEntry(ctx, tx, releaseID, hold.AccountID, "CREDIT", released); err != nil {
+		return uuid.Nil, err
+	}
+
+	_, err = tx.Exec(ctx, `UPDATE holds SET release_transaction_id = $2 WHERE id = $1`, hold.ID, releaseID)
+	return releaseID, err
+}
+
+// capturedHoldPayer returns who paid the hold a capture transaction took its money from, and
+// the HOLDS account the money came out of. ok is false for anything but a booked capture.
+func capturedHoldPayer(ctx context.Context, tx pgx.Tx, transaction *Transaction) (payerID, holdsID uuid.UUID, ok bool, err error) {
+	var currency domain.Currency
+	err = tx.QueryRow(ctx, `
+		SELECT h.account_id, h.currency FROM holds h JOIN transactions t ON t.hold_id = h.id
+		WHERE t.id = $1 AND h.authorization_transaction_id IS NOT NULL`, transaction.ID).Scan(&payerID, &currency)
+	if err == pgx.ErrNoRows {
+		return uuid.Nil, uuid.Nil, false, nil
+	}
+	if err != nil {
+		return uuid.Nil, uuid.Nil, false, err
+	}
+	holdsID, err = systemAccount(ctx, tx, SystemHolds, currency, transaction.Livemode)
+	return payerID, holdsID, err == nil, err
+}
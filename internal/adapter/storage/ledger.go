@@ -2,138 +2,583 @@ package storage
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
+	"sort"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/ibrahimkeyboad/gopay/internal/core/domain"
+	"github.com/ibrahimkeyboad/gopay/internal/core/pricing"
+	"github.com/ibrahimkeyboad/gopay/internal/core/tax"
 )
 
 type LedgerRepository struct {
 	// CHANGE IS HERE: We changed 'db' to 'Db' (Capital D makes it public)
 	Db *pgxpool.Pool 
+
+	// Plans prices card and mobile money payments, Taxes taxes the fee (see DepositSplit)
+	Plans pricing.Plans
+	Taxes *tax.Table
 }
 
 func NewLedgerRepository(db *pgxpool.Pool) *LedgerRepository {
-	return &LedgerRepository{Db: db}
+	return &LedgerRepository{Db: db, Plans: pricing.DefaultPlans(), Taxes: tax.DefaultTable()}
+}
+
+var (
+	ErrAccountNotFound   = errors.New("account not found")
+	ErrCurrencyMismatch  = errors.New("currency mismatch")
+	ErrFeeExceedsShare   = errors.New("processing fee is larger than the merchant's share of the payment")
+	ErrInsufficientFunds = errors.New("insufficient funds")
+)
+
+// Deposit adds money to an account. The money must be in the account's currency.
+// It is booked as DEBIT source system account / CREDIT customer account, where
+// source says where the money came from (FUNDING, CARD_SETTLEMENT, a mobile money float...).
+func (r *LedgerRepository) Deposit(ctx context.Context, source SystemAccountKind, accountID uuid.UUID, amount domain.Money, description string) error {
+	_, err := r.DepositSplit(ctx, source, "", []Credit{{AccountID: accountID, Amount: amount}}, domain.NewMoney(0, amount.Currency), description)
+	return err
 }
 
-// Deposit adds money to an account
-func (r *LedgerRepository) Deposit(ctx context.Context, accountID uuid.UUID, amount int64, description string) error {
+// Credit is one customer account's part of an incoming payment
+type Credit struct {
+	AccountID uuid.UUID
+	Amount    domain.Money
+}
+
+// Receipt shows how a payment was divided. Net is what the merchant (the first credit) kept
+// after the processing fee and the tax charged on it; Credits are the amounts actually booked.
+type Receipt struct {
+	TransactionID uuid.UUID       `json:"transaction_id"`
+	Currency      domain.Currency `json:"currency"`
+	Gross         int64           `json:"gross"`
+	ProcessingFee int64           `json:"processing_fee"`
+	Tax           int64           `json:"tax"` // VAT on the processing fee, paid by the merchant
+	TaxLines      []tax.Line      `json:"tax_lines"`
+	PlatformFee   int64           `json:"platform_fee"`
+	Net           int64           `json:"net"`
+	Credits       []Credit        `json:"-"`
+}
+
+// DepositSplit books one incoming payment to several accounts, plus an optional platform fee,
+// in a single transaction: DEBIT source for the total, CREDIT each account its part and
+// PLATFORM_REVENUE the fee. Every part must be in the same currency as its account.
+//
+// When method is set, the merchant (credits[0]) also pays a processing fee from its pricing
+// plan, plus VAT on it. Both come out of the merchant's part: the fee is booked as its own
+// PLATFORM_REVENUE entry (less any withholding tax) and each tax to its TAX_PAYABLE account.
+func (r *LedgerRepository) DepositSplit(ctx context.Context, source SystemAccountKind, method pricing.Method, credits []Credit, fee domain.Money, description string) (*Receipt, error) {
 	// UPDATE HERE: Use r.Db instead of r.db
 	tx, err := r.Db.Begin(ctx)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer tx.Rollback(ctx)
 
-	var transactionID uuid.UUID
+	receipt, err := r.depositSplit(ctx, tx, source, method, credits, fee, description)
+	if err != nil {
+		return nil, err
+	}
+	return receipt, tx.Commit(ctx)
+}
+
+// depositSplit books a DepositSplit inside tx, so callers can record what the payment
+// was for (a payment intent, a captured hold) in the same transaction
+func (r *LedgerRepository) depositSplit(ctx context.Context, tx pgx.Tx, source SystemAccountKind, method pricing.Method, credits []Credit, fee domain.Money, description string) (*Receipt, error) {
+	if len(credits) == 0 {
+		return nil, fmt.Errorf("a deposit needs at least one account to credit")
+	}
+	total := fee
+	var err error
+	for _, c := range credits {
+		if total, err = total.Add(c.Amount); err != nil {
+			return nil, err
+		}
+	}
+
+	// Processing fee and its VAT, paid by the merchant out of its part
+	receipt := &Receipt{
+		Currency:    total.Currency,
+		Gross:       total.Amount,
+		PlatformFee: fee.Amount,
+		TaxLines:    []tax.Line{},
+		Credits:     append([]Credit(nil), credits...),
+	}
+	priced := pricedFee{Fee: domain.NewMoney(0, total.Currency)}
+	var withheld int64
+	if method != "" {
+		if priced, err = r.processingFee(ctx, tx, credits[0].AccountID, method, total); err != nil {
+			return nil, err
+		}
+		receipt.TaxLines = priced.Taxes
+		receipt.Tax, withheld = tax.Split(priced.Taxes)
+
+		charge := priced.Fee.Amount + receipt.Tax
+		merchant := &receipt.Credits[0]
+		if charge > merchant.Amount.Amount {
+			return nil, fmt.Errorf("%w: fee %d, share %d %s", ErrFeeExceedsShare, charge, merchant.Amount.Amount, merchant.Amount.Currency)
+		}
+		merchant.Amount.Amount -= charge
+	}
+	receipt.ProcessingFee = priced.Fee.Amount
+	receipt.Net = receipt.Credits[0].Amount.Amount
+
+	// Credit accounts in a stable order so concurrent splits can't deadlock
+	sorted := make([]Credit, 0, len(receipt.Credits))
+	for _, c := range receipt.Credits {
+		if c.Amount.Amount > 0 {
+			sorted = append(sorted, c)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].AccountID.String() < sorted[j].AccountID.String() })
+
+	for _, c := range sorted {
+		currency, err := destinationCurrency(ctx, tx, c.AccountID)
+		if err != nil {
+			return nil, err
+		}
+		if currency != c.Amount.Currency {
+			return nil, fmt.Errorf("%w: account %s holds %s but deposit is in %s", ErrCurrencyMismatch, c.AccountID, currency, c.Amount.Currency)
+		}
+	}
+
+	// The merchant's mode decides which system accounts take the other side
+	livemode, err := accountLivemode(ctx, tx, credits[0].AccountID)
+	if err != nil {
+		return nil, err
+	}
+
 	err = tx.QueryRow(ctx, `
-		INSERT INTO transactions (amount, currency, description, status)
-		VALUES ($1, 'TZS', $2, 'COMPLETED') RETURNING id`, amount, description).Scan(&transactionID)
+		INSERT INTO transactions (amount, currency, description, status, livemode, merchant_account_id)
+		VALUES ($1, $2, $3, 'COMPLETED', $4, $5) RETURNING id`, total.Amount, total.Currency, description, livemode, credits[0].AccountID).Scan(&receipt.TransactionID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Money entering the ledger is booked against its source so the books balance
+	sourceID, err := systemAccount(ctx, tx, source, total.Currency, livemode)
+	if err != nil {
+		return nil, err
+	}
+	if err := postEntry(ctx, tx, receipt.TransactionID, sourceID, "DEBIT", total); err != nil {
+		return nil, err
+	}
+
+	for _, c := range sorted {
+		if err := postLimitedEntry(ctx, tx, receipt.TransactionID, c.AccountID, "CREDIT", c.Amount); err != nil {
+			return nil, err
+		}
+	}
+
+	// Our revenue: the marketplace's platform fee and our processing fee, as separate entries.
+	// Withholding tax is kept back from the processing fee for the tax authority.
+	revenues := []domain.Money{fee, domain.NewMoney(priced.Fee.Amount-withheld, total.Currency)}
+	for _, revenue := range revenues {
+		if revenue.Amount <= 0 {
+			continue
+		}
+		revenueID, err := systemAccount(ctx, tx, SystemRevenue, revenue.Currency, livemode)
+		if err != nil {
+			return nil, err
+		}
+		if err := postEntry(ctx, tx, receipt.TransactionID, revenueID, "CREDIT", revenue); err != nil {
+			return nil, err
+		}
+	}
+
+	if method != "" {
+		_, err = tx.Exec(ctx, `
+			INSERT INTO fees (transaction_id, account_id, method, pricing_plan, currency, gross, fee, net)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+			receipt.TransactionID, credits[0].AccountID, method, priced.Plan, total.Currency, total.Amount, priced.Fee.Amount, receipt.Net)
+		if err != nil {
+			return nil, err
+		}
+		if err := bookTaxLines(ctx, tx, receipt.TransactionID, credits[0].AccountID, priced.Taxes, livemode); err != nil {
+			return nil, err
+		}
+	}
+
+	return receipt, nil
+}
+
+// pricedFee is the processing fee on one payment and the taxes due on it
+type pricedFee struct {
+	Plan  string
+	Fee   domain.Money
+	Taxes []tax.Line
+}
+
+// processingFee prices a payment for the merchant: its plan, this month's volume for the
+// same method and currency to pick the tier, and the taxes of its jurisdiction
+func (r *LedgerRepository) processingFee(ctx context.Context, tx pgx.Tx, merchantID uuid.UUID, method pricing.Method, gross domain.Money) (pricedFee, error) {
+	var planName, jurisdiction string
+	var withholding bool
+	err := tx.QueryRow(ctx, `SELECT pricing_plan, tax_jurisdiction, withholding_tax FROM accounts WHERE id = $1`, merchantID).
+		Scan(&planName, &jurisdiction, &withholding)
+	if err == pgx.ErrNoRows {
+		return pricedFee{}, ErrAccountNotFound
+	}
+	if err != nil {
+		return pricedFee{}, err
+	}
+
+	plan, err := r.Plans.Get(planName)
+	if err != nil {
+		// A plan removed from the pricing file shouldn't stop payments
+		slog.Warn("⚠️ Unknown pricing plan, using default", "plan", planName, "account_id", merchantID)
+		if plan, err = r.Plans.Get(pricing.DefaultPlan); err != nil {
+			return pricedFee{}, err
+		}
+	}
+
+	now := time.Now()
+	_, monthStart := domain.LimitWindows(now)
+	var volume int64
+	err = tx.QueryRow(ctx, `
+		SELECT COALESCE(SUM(gross), 0) FROM fees
+		WHERE account_id = $1 AND method = $2 AND currency = $3 AND created_at >= $4`,
+		merchantID, method, gross.Currency, monthStart).Scan(&volume)
+	if err != nil {
+		return pricedFee{}, err
+	}
+
+	fee, err := plan.Fee(method, gross, volume)
+	if err != nil {
+		return pricedFee{}, err
+	}
+
+	priced := pricedFee{Plan: plan.Name, Fee: fee, Taxes: []tax.Line{}}
+	if r.Taxes != nil {
+		if priced.Taxes, err = r.Taxes.Lines(jurisdiction, fee, withholding, now); err != nil {
+			return pricedFee{}, err
+		}
+	}
+	return priced, nil
+}
+
+// BookToSuspense records money we received from source but could not credit to
+// a customer (unknown or closed account, currency mismatch...). Operations
+// investigate the SUSPENSE account and move the money on by hand.
+func (r *LedgerRepository) BookToSuspense(ctx context.Context, source SystemAccountKind, amount domain.Money, description string, livemode bool) error {
+	tx, err := r.Db.Begin(ctx)
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback(ctx)
 
-	_, err = tx.Exec(ctx, `UPDATE accounts SET balance = balance + $1 WHERE id = $2`, amount, accountID)
+	sourceID, err := systemAccount(ctx, tx, source, amount.Currency, livemode)
+	if err != nil {
+		return err
+	}
+	suspenseID, err := systemAccount(ctx, tx, SystemSuspense, amount.Currency, livemode)
 	if err != nil {
 		return err
 	}
 
-	_, err = tx.Exec(ctx, `
-		INSERT INTO entries (transaction_id, account_id, direction, amount)
-		VALUES ($1, $2, 'CREDIT', $3)`, transactionID, accountID, amount)
+	var transactionID uuid.UUID
+	err = tx.QueryRow(ctx, `
+		INSERT INTO transactions (amount, currency, description, status, livemode)
+		VALUES ($1, $2, $3, 'COMPLETED', $4) RETURNING id`, amount.Amount, amount.Currency, "Suspense: "+description, livemode).Scan(&transactionID)
 	if err != nil {
 		return err
 	}
 
+	if err := postEntry(ctx, tx, transactionID, sourceID, "DEBIT", amount); err != nil {
+		return err
+	}
+	if err := postEntry(ctx, tx, transactionID, suspenseID, "CREDIT", amount); err != nil {
+		return err
+	}
+
 	return tx.Commit(ctx)
 }
 
-// Transfer moves money safely
-func (r *LedgerRepository) Transfer(ctx context.Context, fromID, toID uuid.UUID, amount int64) error {
+// Transfer moves money safely between two accounts of the same currency.
+// Cross-currency moves must go through an explicit conversion instead.
+func (r *LedgerRepository) Transfer(ctx context.Context, fromID, toID uuid.UUID, amount domain.Money) error {
+	_, err := r.transfer(ctx, fromID, toID, amount, "P2P Transfer", nil)
+	return err
+}
+
+// transfer books a same-currency transfer. check, when set, runs inside the
+// ledger transaction after the source account is locked and can veto it.
+func (r *LedgerRepository) transfer(ctx context.Context, fromID, toID uuid.UUID, amount domain.Money, description string, check func(pgx.Tx) error) (*Transaction, error) {
 	// UPDATE HERE: Use r.Db instead of r.db
 	tx, err := r.Db.Begin(ctx)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer tx.Rollback(ctx)
 
-	var balance int64
-	err = tx.QueryRow(ctx, `SELECT balance FROM accounts WHERE id = $1 FOR UPDATE`, fromID).Scan(&balance)
+	txn, err := transferTx(ctx, tx, fromID, toID, amount, description, check)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	return txn, tx.Commit(ctx)
+}
+
+// transferTx books a transfer inside the caller's transaction
+func transferTx(ctx context.Context, tx pgx.Tx, fromID, toID uuid.UUID, amount domain.Money, description string, check func(pgx.Tx) error) (*Transaction, error) {
+	from, err := lockAccount(ctx, tx, fromID)
+	if err != nil {
+		return nil, err
+	}
+	fromCurrency := from.Currency
+
+	if check != nil {
+		if err := check(tx); err != nil {
+			return nil, err
+		}
+	}
+
+	toCurrency, err := destinationCurrency(ctx, tx, toID)
+	if err != nil {
+		return nil, err
 	}
 
-	if balance < amount {
-		return fmt.Errorf("insufficient funds: you have %d but tried to send %d", balance, amount)
+	if fromCurrency != amount.Currency {
+		return nil, fmt.Errorf("%w: source account holds %s but transfer is in %s", ErrCurrencyMismatch, fromCurrency, amount.Currency)
+	}
+	if toCurrency != fromCurrency {
+		return nil, fmt.Errorf("%w: cannot send %s to a %s account without an explicit conversion (pass a quote_id)", ErrCurrencyMismatch, fromCurrency, toCurrency)
+	}
+
+	// Money reserved by active holds can't be spent
+	pending, err := pendingBalance(ctx, tx, fromID)
+	if err != nil {
+		return nil, err
+	}
+
+	if available := from.spendable(pending); available < amount.Amount {
+		return nil, fmt.Errorf("%w: you have %d available but tried to send %d", ErrInsufficientFunds, available, amount.Amount)
 	}
 
 	var transactionID uuid.UUID
 	err = tx.QueryRow(ctx, `
-		INSERT INTO transactions (amount, currency, description, status)
-		VALUES ($1, 'TZS', 'P2P Transfer', 'COMPLETED') RETURNING id`, amount).Scan(&transactionID)
+		INSERT INTO transactions (amount, currency, description, status, livemode)
+		VALUES ($1, $2, $3, 'COMPLETED', $4) RETURNING id`, amount.Amount, amount.Currency, description, from.Livemode).Scan(&transactionID)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	if _, err := tx.Exec(ctx, `UPDATE accounts SET balance = balance - $1 WHERE id = $2`, amount, fromID); err != nil {
-		return err
+	if err := postLimitedEntry(ctx, tx, transactionID, fromID, "DEBIT", amount); err != nil {
+		return nil, err
 	}
-	
-	if _, err := tx.Exec(ctx, `UPDATE accounts SET balance = balance + $1 WHERE id = $2`, amount, toID); err != nil {
-		return err
+	if err := postLimitedEntry(ctx, tx, transactionID, toID, "CREDIT", amount); err != nil {
+		return nil, err
 	}
 
-	if _, err := tx.Exec(ctx, `INSERT INTO entries (transaction_id, account_id, direction, amount) VALUES ($1, $2, 'DEBIT', $3)`, transactionID, fromID, amount); err != nil {
-		return err
+	return getTransaction(ctx, tx, transactionID, false)
+}
+
+// lockedAccount is a customer account held with SELECT ... FOR UPDATE
+type lockedAccount struct {
+	Balance  int64
+	Currency domain.Currency
+	// CreditAllowance is how far below zero the account may go (overdraft or credit line)
+	CreditAllowance int64
+	Livemode        bool
+}
+
+// spendable is what the account can send right now: its balance plus any overdraft or
+// credit line, minus money reserved by active holds
+func (a *lockedAccount) spendable(pending int64) int64 {
+	return a.Balance + a.CreditAllowance - pending
+}
+
+// lockAccount takes the row lock on a customer account money is about to leave.
+// System accounts are rejected: they only move as the counterparty of a booking.
+// Frozen and closed accounts are rejected because they cannot send.
+func lockAccount(ctx context.Context, tx pgx.Tx, accountID uuid.UUID) (*lockedAccount, error) {
+	var a lockedAccount
+	var system bool
+	var status AccountStatus
+	err := tx.QueryRow(ctx, `
+		SELECT balance, currency, `+creditAllowanceSQL+`, livemode, system_kind IS NOT NULL, status
+		FROM accounts WHERE id = $1 FOR UPDATE`, accountID).
+		Scan(&a.Balance, &a.Currency, &a.CreditAllowance, &a.Livemode, &system, &status)
+	if err == pgx.ErrNoRows {
+		return nil, ErrAccountNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if system {
+		return nil, ErrSystemAccount
+	}
+	if err := status.canSend(); err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// destinationCurrency returns the currency of the customer account receiving money.
+// Closed accounts are rejected.
+func destinationCurrency(ctx context.Context, tx pgx.Tx, accountID uuid.UUID) (domain.Currency, error) {
+	var currency domain.Currency
+	var system bool
+	var status AccountStatus
+	err := tx.QueryRow(ctx, `SELECT currency, system_kind IS NOT NULL, status FROM accounts WHERE id = $1`, accountID).Scan(&currency, &system, &status)
+	if err == pgx.ErrNoRows {
+		return "", fmt.Errorf("destination %w", ErrAccountNotFound)
+	}
+	if err != nil {
+		return "", err
 	}
-	if _, err := tx.Exec(ctx, `INSERT INTO entries (transaction_id, account_id, direction, amount) VALUES ($1, $2, 'CREDIT', $3)`, transactionID, toID, amount); err != nil {
+	if system {
+		return "", ErrSystemAccount
+	}
+	if err := status.canReceive(); err != nil {
+		return "", err
+	}
+	return currency, nil
+}
+
+// postEntry writes one side of a booking and moves the account balance with it.
+// CREDIT increases the balance, DEBIT decreases it.
+// The account status is checked again under the row lock the UPDATE takes, so an
+// account frozen or closed mid-booking can't slip through, and the account must be in the
+// same mode (live or test) as the transaction.
+func postEntry(ctx context.Context, tx pgx.Tx, transactionID, accountID uuid.UUID, direction string, amount domain.Money) error {
+	delta := amount.Amount
+	if direction == "DEBIT" {
+		delta = -delta
+	}
+
+	// overdrawn_since starts the grace clock when the balance goes negative and stops it on repayment
+	var status AccountStatus
+	var sameMode bool
+	err := tx.QueryRow(ctx, `
+		UPDATE accounts SET
+			balance = balance + $1,
+			overdrawn_since = CASE WHEN balance + $1 < 0 THEN COALESCE(overdrawn_since, NOW()) END
+		WHERE id = $2
+		RETURNING status, livemode = (SELECT livemode FROM transactions WHERE id = $3)`, delta, accountID, transactionID).Scan(&status, &sameMode)
+	if err == pgx.ErrNoRows {
+		return ErrAccountNotFound
+	}
+	if err != nil {
 		return err
 	}
+	// Test money never reaches a live account, and the other way round
+	if !sameMode {
+		return fmt.Errorf("%w: %s", ErrLivemodeMismatch, accountID)
+	}
+	if direction == "DEBIT" {
+		err = status.canSend()
+	} else {
+		err = status.canReceive()
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %s", err, accountID)
+	}
 
-	return tx.Commit(ctx)
+	_, err = tx.Exec(ctx, `
+		INSERT INTO entries (transaction_id, account_id, direction, amount, currency)
+		VALUES ($1, $2, $3, $4, $5)`, transactionID, accountID, direction, amount.Amount, amount.Currency)
+	return err
 }
 
-// GetHistory fetches the last 10 transactions
-func (r *LedgerRepository) GetHistory(ctx context.Context, accountID uuid.UUID) ([]map[string]interface{}, error) {
+// BalancesByCurrency sums the entries of the given accounts, one total per currency
+func (r *LedgerRepository) BalancesByCurrency(ctx context.Context, accountIDs ...uuid.UUID) (map[domain.Currency]int64, error) {
+	rows, err := r.Db.Query(ctx, `
+		SELECT currency, COALESCE(SUM(CASE WHEN direction = 'CREDIT' THEN amount ELSE -amount END), 0)
+		FROM entries
+		WHERE account_id = ANY($1)
+		GROUP BY currency`, accountIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	balances := make(map[domain.Currency]int64)
+	for rows.Next() {
+		var currency domain.Currency
+		var total int64
+		if err := rows.Scan(&currency, &total); err != nil {
+			return nil, err
+		}
+		balances[currency] = total
+	}
+	return balances, rows.Err()
+}
+
+// Transaction is a row from the transactions table together with its entries
+type Transaction struct {
+	ID                    uuid.UUID  `json:"id"`
+	Amount                int64      `json:"amount"`
+	Currency              string     `json:"currency"`
+	Description           string     `json:"description"`
+	Status                string     `json:"status"`
+	RefundedAmount        int64      `json:"refunded_amount"`
+	OriginalTransactionID *uuid.UUID `json:"original_transaction_id,omitempty"`
+	Livemode              bool       `json:"livemode"`
+	MerchantAccountID     *uuid.UUID `json:"merchant_account_id,omitempty"` // Set on payments; see Refund
+	CreatedAt             time.Time  `json:"created_at"`
+	Entries               []Entry    `json:"entries"`
+}
+
+// Entry is one side of a double-entry booking
+type Entry struct {
+	ID             uuid.UUID `json:"id"`
+	AccountID      uuid.UUID `json:"account_id"`
+	Direction      string    `json:"direction"` // "DEBIT" or "CREDIT"
+	Amount         int64     `json:"amount"`
+	Currency       string    `json:"currency"`
+	RefundedAmount int64     `json:"refunded_amount"` // Reversed by refunds so far
+}
+
+var ErrTransactionNotFound = errors.New("transaction not found")
+
+// GetTransaction fetches a transaction and its entries
+func (r *LedgerRepository) GetTransaction(ctx context.Context, id uuid.UUID) (*Transaction, error) {
+	return getTransaction(ctx, r.Db, id, false)
+}
+
+// queryer is satisfied by both *pgxpool.Pool and pgx.Tx
+type queryer interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+func getTransaction(ctx context.Context, q queryer, id uuid.UUID, forUpdate bool) (*Transaction, error) {
 	query := `
-		SELECT 
-			t.id, t.amount, t.currency, t.description, t.status, t.created_at, e.direction
-		FROM entries e
-		JOIN transactions t ON e.transaction_id = t.id
-		WHERE e.account_id = $1
-		ORDER BY t.created_at DESC
-		LIMIT 10
-	`
+		SELECT id, amount, currency, description, status, refunded_amount, original_transaction_id, livemode,
+			merchant_account_id, created_at
+		FROM transactions WHERE id = $1`
+	if forUpdate {
+		query += ` FOR UPDATE`
+	}
 
-	// UPDATE HERE: Use r.Db instead of r.db
-	rows, err := r.Db.Query(ctx, query, accountID)
+	var t Transaction
+	err := q.QueryRow(ctx, query, id).Scan(&t.ID, &t.Amount, &t.Currency, &t.Description, &t.Status,
+		&t.RefundedAmount, &t.OriginalTransactionID, &t.Livemode, &t.MerchantAccountID, &t.CreatedAt)
+	if err == pgx.ErrNoRows {
+		return nil, ErrTransactionNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
 
-	var history []map[string]interface{}
+	rows, err := q.Query(ctx, `SELECT id, account_id, direction, amount, currency, refunded_amount FROM entries WHERE transaction_id = $1`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
 
 	for rows.Next() {
-		var id uuid.UUID
-		var amount int64
-		var currency, description, status, direction string
-		var createdAt time.Time
-
-		rows.Scan(&id, &amount, &currency, &description, &status, &createdAt, &direction)
-
-		history = append(history, map[string]interface{}{
-			"id":          id,
-			"amount":      amount,
-			"currency":    currency,
-			"description": description,
-			"status":      status,
-			"direction":   direction,
-			"date":        createdAt,
-		})
-	}
-
-	return history, nil
-}
\ No newline at end of file
+		var e Entry
+		if err := rows.Scan(&e.ID, &e.AccountID, &e.Direction, &e.Amount, &e.Currency, &e.RefundedAmount); err != nil {
+			return nil, err
+		}
+		t.Entries = append(t.Entries, e)
+	}
+
+	return &t, rows.Err()
+}
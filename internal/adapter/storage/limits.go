@@ -0,0 +1,59 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/ibrahimkeyboad/gopay/internal/core/domain"
+)
+
+// postLimitedEntry posts an entry and then checks it against the account's KYC tier.
+// postEntry's UPDATE holds the account row lock, so concurrent bookings on the same
+// account are checked one after another and can't jointly exceed a limit.
+// Refunds use postEntry directly: giving money back never counts against a limit.
+func postLimitedEntry(ctx context.Context, tx pgx.Tx, transactionID, accountID uuid.UUID, direction string, amount domain.Money) error {
+	if err := postEntry(ctx, tx, transactionID, accountID, direction, amount); err != nil {
+		return err
+	}
+	return enforceLimits(ctx, tx, transactionID, accountID, direction, amount)
+}
+
+// enforceLimits checks amount against the account's per-transaction, daily and monthly
+// caps for direction. Entries of transactionID itself and refunds are not counted as used.
+// System accounts have no limits. Callers must hold the account row lock.
+func enforceLimits(ctx context.Context, tx pgx.Tx, transactionID, accountID uuid.UUID, direction string, amount domain.Money) error {
+	var tier domain.KYCTier
+	var system bool
+	err := tx.QueryRow(ctx, `SELECT kyc_tier, system_kind IS NOT NULL FROM accounts WHERE id = $1`, accountID).Scan(&tier, &system)
+	if err == pgx.ErrNoRows {
+		return ErrAccountNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if system {
+		return nil
+	}
+
+	dayStart, monthStart := domain.LimitWindows(time.Now())
+	var usedToday, usedThisMonth int64
+	err = tx.QueryRow(ctx, `
+		SELECT COALESCE(SUM(e.amount) FILTER (WHERE t.created_at >= $3), 0), COALESCE(SUM(e.amount), 0)
+		FROM entries e
+		JOIN transactions t ON t.id = e.transaction_id
+		WHERE e.account_id = $1
+			AND e.direction = $2
+			AND e.currency = $6
+			AND t.created_at >= $4
+			AND t.id <> $5
+			AND t.original_transaction_id IS NULL`,
+		accountID, direction, dayStart, monthStart, transactionID, amount.Currency).Scan(&usedToday, &usedThisMonth)
+	if err != nil {
+		return err
+	}
+
+	return domain.CheckLimits(tier, direction, amount, usedToday, usedThisMonth)
+}
@@ -0,0 +1,100 @@
+package storage
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+var ErrLivemodeMismatch = errors.New("live and test accounts cannot be mixed in one transaction")
+
+// accountLivemode reports whether an account holds live (true) or test (false) money
+func accountLivemode(ctx context.Context, q queryer, id uuid.UUID) (bool, error) {
+	var livemode bool
+	err := q.QueryRow(ctx, `SELECT livemode FROM accounts WHERE id = $1`, id).Scan(&livemode)
+	if err == pgx.ErrNoRows {
+		return false, ErrAccountNotFound
+	}
+	return livemode, err
+}
+
+// AccountLivemode reports whether an account holds live (true) or test (false) money
+func (r *LedgerRepository) AccountLivemode(ctx context.Context, id uuid.UUID) (bool, error) {
+	return accountLivemode(ctx, r.Db, id)
+}
+
+// TestAccount returns the test twin of a live account, creating it the first time.
+// The twin copies the account's settings but has its own, separate balance. A sub-account's
+// twin sits under its platform's twin, so test sub-accounts work like live ones.
+func (r *AccountRepository) TestAccount(ctx context.Context, liveID uuid.UUID) (*Account, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	twin, err := testTwin(ctx, tx, liveID)
+	if err != nil {
+		return nil, err
+	}
+	return twin, tx.Commit(ctx)
+}
+
+func testTwin(ctx context.Context, tx pgx.Tx, liveID uuid.UUID) (*Account, error) {
+	// The lock makes concurrent first calls wait instead of creating two twins
+	var system bool
+	live, err := scanAccount(tx.QueryRow(ctx, `SELECT `+accountColumns+` FROM accounts WHERE id = $1 FOR UPDATE`, liveID))
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.QueryRow(ctx, `SELECT system_kind IS NOT NULL FROM accounts WHERE id = $1`, liveID).Scan(&system); err != nil {
+		return nil, err
+	}
+	if system {
+		return nil, ErrSystemAccount
+	}
+	if !live.Livemode {
+		return live, nil
+	}
+
+	twin, err := scanAccount(tx.QueryRow(ctx, `SELECT `+accountColumns+` FROM accounts WHERE live_account_id = $1`, liveID))
+	if err == nil {
+		return twin, nil
+	}
+	if !errors.Is(err, ErrAccountNotFound) {
+		return nil, err
+	}
+
+	var parentTwin *uuid.UUID
+	if live.ParentID != nil {
+		parent, err := testTwin(ctx, tx, *live.ParentID)
+		if err != nil {
+			return nil, err
+		}
+		parentTwin = &parent.ID
+	}
+
+	return scanAccount(tx.QueryRow(ctx, `
+		INSERT INTO accounts (owner_name, currency, balance, livemode, live_account_id, parent_id,
+			kyc_tier, pricing_plan, tax_jurisdiction, withholding_tax)
+		VALUES ($1, $2, 0, FALSE, $3, $4, $5, $6, $7, $8)
+		RETURNING `+accountColumns,
+		live.OwnerName, live.Currency, live.ID, parentTwin, live.KYCTier, live.PricingPlan, live.TaxJurisdiction, live.WithholdingTax))
+}
+
+// sameMode fails with ErrLivemodeMismatch unless both accounts are live or both are test.
+// postEntry enforces this on every booking; call it to reject requests that book later.
+func sameMode(ctx context.Context, q queryer, a, b uuid.UUID) error {
+	var same bool
+	err := q.QueryRow(ctx, `
+		SELECT (SELECT livemode FROM accounts WHERE id = $1) = (SELECT livemode FROM accounts WHERE id = $2)`, a, b).Scan(&same)
+	if err != nil {
+		return err
+	}
+	if !same {
+		return ErrLivemodeMismatch
+	}
+	return nil
+}
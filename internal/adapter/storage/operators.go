@@ -0,0 +1,94 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	ErrOperatorNotFound = errors.New("operator not found")
+	ErrOperatorExists   = errors.New("an operator with this email already exists")
+)
+
+// Operator is a back-office user with their own gp_admin_ key
+type Operator struct {
+	ID         uuid.UUID  `json:"id"`
+	Name       string     `json:"name"`
+	Email      string     `json:"email"`
+	Last4      string     `json:"last4"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+
+	Hash string `json:"-"`
+}
+
+const operatorColumns = `id, name, email, last4, revoked_at, last_used_at, created_at`
+
+func scanOperator(row pgx.Row) (*Operator, error) {
+	var o Operator
+	err := row.Scan(&o.ID, &o.Name, &o.Email, &o.Last4, &o.RevokedAt, &o.LastUsedAt, &o.CreatedAt)
+	if err == pgx.ErrNoRows {
+		return nil, ErrOperatorNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &o, nil
+}
+
+// OperatorRepository stores back-office operators
+type OperatorRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewOperatorRepository(db *pgxpool.Pool) *OperatorRepository {
+	return &OperatorRepository{db: db}
+}
+
+// CreateOperator saves a new operator and the hash of their key
+func (r *OperatorRepository) CreateOperator(ctx context.Context, op Operator) (*Operator, error) {
+	var exists bool
+	if err := r.db.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM operators WHERE email = $1)`, op.Email).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, ErrOperatorExists
+	}
+
+	return scanOperator(r.db.QueryRow(ctx, `
+		INSERT INTO operators (name, email, key_hash, last4)
+		VALUES ($1, $2, $3, $4)
+		RETURNING `+operatorColumns, op.Name, op.Email, op.Hash, op.Last4))
+}
+
+// ListOperators returns every operator, revoked ones included
+func (r *OperatorRepository) ListOperators(ctx context.Context) ([]Operator, error) {
+	rows, err := r.db.Query(ctx, `SELECT `+operatorColumns+` FROM operators ORDER BY created_at, id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	operators := []Operator{}
+	for rows.Next() {
+		o, err := scanOperator(rows)
+		if err != nil {
+			return nil, err
+		}
+		operators = append(operators, *o)
+	}
+	return operators, rows.Err()
+}
+
+// RevokeOperator stops an operator's key from working. Revoking is final.
+func (r *OperatorRepository) RevokeOperator(ctx context.Context, id uuid.UUID) (*Operator, error) {
+	return scanOperator(r.db.QueryRow(ctx, `
+		UPDATE operators SET revoked_at = COALESCE(revoked_at, NOW())
+		WHERE id = $1 RETURNING `+operatorColumns, id))
+}
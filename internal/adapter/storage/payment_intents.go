@@ -0,0 +1,238 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/ibrahimkeyboad/gopay/internal/core/domain"
+	"github.com/ibrahimkeyboad/gopay/internal/core/pricing"
+)
+
+// Payment intent statuses
+const (
+	IntentProcessing      = "PROCESSING"
+	IntentRequiresCapture = "REQUIRES_CAPTURE"
+	IntentSucceeded       = "SUCCEEDED"
+	IntentFailed          = "FAILED"
+)
+
+var (
+	ErrPaymentIntentNotFound = errors.New("payment intent not found")
+	ErrPaymentIntentFinished = errors.New("payment intent has already succeeded or failed")
+)
+
+// PaymentIntent is a payment started from a checkout with a publishable key.
+// It is created PROCESSING and ends SUCCEEDED (with its ledger transaction) or FAILED.
+// A card intent waits in REQUIRES_CAPTURE, with the card's hold, until the merchant captures it.
+type PaymentIntent struct {
+	ID            uuid.UUID  `json:"id"`
+	AccountID     uuid.UUID  `json:"account_id"`
+	Amount        int64      `json:"amount"`
+	Currency      string     `json:"currency"`
+	Method        string     `json:"method"`
+	Status        string     `json:"status"`
+	Livemode      bool       `json:"livemode"`
+	PhoneNumber   *string    `json:"phone_number,omitempty"`
+	Provider      *string    `json:"provider,omitempty"`
+	CardBrand     *string    `json:"card_brand,omitempty"`
+	TransactionID *uuid.UUID `json:"transaction_id,omitempty"`
+	HoldID        *uuid.UUID `json:"hold_id,omitempty"`
+	FailureReason *string    `json:"failure_reason,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+
+	ClientSecretHash string `json:"-"`
+}
+
+const paymentIntentColumns = `id, account_id, amount, currency, method, status, livemode, phone_number, provider, card_brand,
+	transaction_id, hold_id, failure_reason, created_at, updated_at, client_secret_hash`
+
+func scanPaymentIntent(row pgx.Row) (*PaymentIntent, error) {
+	var pi PaymentIntent
+	err := row.Scan(&pi.ID, &pi.AccountID, &pi.Amount, &pi.Currency, &pi.Method, &pi.Status, &pi.Livemode,
+		&pi.PhoneNumber, &pi.Provider, &pi.CardBrand, &pi.TransactionID, &pi.HoldID, &pi.FailureReason, &pi.CreatedAt, &pi.UpdatedAt,
+		&pi.ClientSecretHash)
+	if err == pgx.ErrNoRows {
+		return nil, ErrPaymentIntentNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &pi, nil
+}
+
+// CreatePaymentIntent records a payment that is about to be collected
+func (r *LedgerRepository) CreatePaymentIntent(ctx context.Context, pi PaymentIntent) (*PaymentIntent, error) {
+	return scanPaymentIntent(r.Db.QueryRow(ctx, `
+		INSERT INTO payment_intents (account_id, amount, currency, method, livemode, client_secret_hash,
+			phone_number, provider, card_brand)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING `+paymentIntentColumns,
+		pi.AccountID, pi.Amount, pi.Currency, pi.Method, pi.Livemode, pi.ClientSecretHash,
+		pi.PhoneNumber, pi.Provider, pi.CardBrand))
+}
+
+// GetPaymentIntent fetches a single payment intent
+func (r *LedgerRepository) GetPaymentIntent(ctx context.Context, id uuid.UUID) (*PaymentIntent, error) {
+	return scanPaymentIntent(r.Db.QueryRow(ctx, `SELECT `+paymentIntentColumns+` FROM payment_intents WHERE id = $1`, id))
+}
+
+// SettlePaymentIntent books a collected payment (see DepositSplit) and marks its intent
+// succeeded in the same transaction, so a booked payment is never left PROCESSING
+func (r *LedgerRepository) SettlePaymentIntent(ctx context.Context, id uuid.UUID, source SystemAccountKind, method pricing.Method,
+	credits []Credit, fee domain.Money, description string) (*PaymentIntent, *Receipt, error) {
+	tx, err := r.Db.Begin(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := lockProcessingIntent(ctx, tx, id); err != nil {
+		return nil, nil, err
+	}
+
+	receipt, err := r.depositSplit(ctx, tx, source, method, credits, fee, description)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pi, err := finishPaymentIntent(ctx, tx, id, IntentSucceeded, &receipt.TransactionID, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pi, receipt, tx.Commit(ctx)
+}
+
+// AuthorizePaymentIntent authorizes a card intent: its money goes to a card hold (see
+// AuthorizeCardHold) and the intent waits in REQUIRES_CAPTURE until the hold is captured.
+// Nothing reaches the merchant until then, so a publishable key can't move funds on its own.
+func (r *LedgerRepository) AuthorizePaymentIntent(ctx context.Context, id uuid.UUID, description string, ttl time.Duration) (*PaymentIntent, error) {
+	tx, err := r.Db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := lockProcessingIntent(ctx, tx, id); err != nil {
+		return nil, err
+	}
+	var merchantID uuid.UUID
+	var amount domain.Money
+	err = tx.QueryRow(ctx, `SELECT account_id, amount, currency FROM payment_intents WHERE id = $1`, id).
+		Scan(&merchantID, &amount.Amount, &amount.Currency)
+	if err != nil {
+		return nil, err
+	}
+
+	hold, err := authorizeCardHold(ctx, tx, merchantID, amount, description, ttl)
+	if err != nil {
+		return nil, err
+	}
+
+	pi, err := scanPaymentIntent(tx.QueryRow(ctx, `
+		UPDATE payment_intents SET status = $2, hold_id = $3, updated_at = NOW()
+		WHERE id = $1
+		RETURNING `+paymentIntentColumns, id, IntentRequiresCapture, hold.ID))
+	if err != nil {
+		return nil, err
+	}
+	err = enqueueWebhook(ctx, tx, "payment_intent.requires_capture", map[string]interface{}{
+		"payment_intent_id": pi.ID,
+		"account_id":        pi.AccountID,
+		"amount":            pi.Amount,
+		"currency":          pi.Currency,
+		"method":            pi.Method,
+		"hold_id":           pi.HoldID,
+		"expires_at":        hold.ExpiresAt,
+		"livemode":          pi.Livemode,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pi, tx.Commit(ctx)
+}
+
+// FailPaymentIntent marks a processing intent as failed
+func (r *LedgerRepository) FailPaymentIntent(ctx context.Context, id uuid.UUID, reason string) (*PaymentIntent, error) {
+	tx, err := r.Db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := lockProcessingIntent(ctx, tx, id); err != nil {
+		return nil, err
+	}
+	pi, err := finishPaymentIntent(ctx, tx, id, IntentFailed, nil, &reason)
+	if err != nil {
+		return nil, err
+	}
+	return pi, tx.Commit(ctx)
+}
+
+// lockProcessingIntent locks an intent that is still waiting for its outcome
+func lockProcessingIntent(ctx context.Context, tx pgx.Tx, id uuid.UUID) error {
+	var status string
+	err := tx.QueryRow(ctx, `SELECT status FROM payment_intents WHERE id = $1 FOR UPDATE`, id).Scan(&status)
+	if err == pgx.ErrNoRows {
+		return ErrPaymentIntentNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if status != IntentProcessing {
+		return ErrPaymentIntentFinished
+	}
+	return nil
+}
+
+// finishHeldIntent finishes the intent waiting on a hold, if any, once the hold is
+// captured or released. The caller has locked the hold.
+func finishHeldIntent(ctx context.Context, tx pgx.Tx, holdID uuid.UUID, status string, transactionID *uuid.UUID, reason *string) error {
+	var id uuid.UUID
+	err := tx.QueryRow(ctx, `
+		SELECT id FROM payment_intents WHERE hold_id = $1 AND status = $2 FOR UPDATE`, holdID, IntentRequiresCapture).Scan(&id)
+	if err == pgx.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	_, err = finishPaymentIntent(ctx, tx, id, status, transactionID, reason)
+	return err
+}
+
+// finishPaymentIntent records an intent's outcome and queues its webhook. The caller has
+// locked the intent with lockProcessingIntent (or finishHeldIntent).
+func finishPaymentIntent(ctx context.Context, tx pgx.Tx, id uuid.UUID, status string, transactionID *uuid.UUID, reason *string) (*PaymentIntent, error) {
+	pi, err := scanPaymentIntent(tx.QueryRow(ctx, `
+		UPDATE payment_intents SET status = $2, transaction_id = $3, failure_reason = $4, updated_at = NOW()
+		WHERE id = $1
+		RETURNING `+paymentIntentColumns, id, status, transactionID, reason))
+	if err != nil {
+		return nil, err
+	}
+
+	event := "payment_intent.succeeded"
+	if status == IntentFailed {
+		event = "payment_intent.payment_failed"
+	}
+	err = enqueueWebhook(ctx, tx, event, map[string]interface{}{
+		"payment_intent_id": pi.ID,
+		"account_id":        pi.AccountID,
+		"amount":            pi.Amount,
+		"currency":          pi.Currency,
+		"method":            pi.Method,
+		"transaction_id":    pi.TransactionID,
+		"failure_reason":    pi.FailureReason,
+		"livemode":          pi.Livemode,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pi, nil
+}
@@ -0,0 +1,51 @@
+package storage
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// SetPricingPlan moves an account to another pricing plan and queues an account.updated webhook.
+// The plan name must already be validated against the loaded plans.
+func (r *AccountRepository) SetPricingPlan(ctx context.Context, id uuid.UUID, plan string) (*Account, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	var previous string
+	var system bool
+	err = tx.QueryRow(ctx, `SELECT pricing_plan, system_kind IS NOT NULL FROM accounts WHERE id = $1 FOR UPDATE`, id).Scan(&previous, &system)
+	if err == pgx.ErrNoRows {
+		return nil, ErrAccountNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if system {
+		return nil, ErrSystemAccount
+	}
+
+	if _, err := tx.Exec(ctx, `UPDATE accounts SET pricing_plan = $1 WHERE id = $2`, plan, id); err != nil {
+		return nil, err
+	}
+
+	err = enqueueWebhook(ctx, tx, "account.updated", map[string]interface{}{
+		"account_id":            id,
+		"pricing_plan":          plan,
+		"previous_pricing_plan": previous,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	account, err := scanAccount(tx.QueryRow(ctx, `SELECT `+accountColumns+` FROM accounts WHERE id = $1`, id))
+	if err != nil {
+		return nil, err
+	}
+
+	return account, tx.Commit(ctx)
+}
@@ -0,0 +1,264 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/ibrahimkeyboad/gopay/internal/core/domain"
+)
+
+// Transaction statuses set by refunds
+const (
+	StatusRefunded          = "REFUNDED"
+	StatusPartiallyRefunded = "PARTIALLY_REFUNDED"
+)
+
+var (
+	ErrNotRefundable        = errors.New("transaction cannot be refunded")
+	ErrRefundExceedsBalance = errors.New("refund exceeds the amount left to refund")
+)
+
+// Refund is the compensating transaction written against an original one
+type Refund struct {
+	ID                    uuid.UUID `json:"id"`
+	OriginalTransactionID uuid.UUID `json:"original_transaction_id"`
+	Amount                int64     `json:"amount"`
+	Currency              string    `json:"currency"`
+	Reason                string    `json:"reason"`
+	OriginalStatus        string    `json:"original_status"`
+	RemainingRefundable   int64     `json:"remaining_refundable"`
+	CreatedAt             time.Time `json:"created_at"`
+}
+
+// Refund reverses amount (or everything left when amount is 0) of a completed transaction.
+// Every entry of the original is mirrored with the opposite direction, so a
+// refunded transfer moves money back from the receiver to the sender.
+func (r *LedgerRepository) Refund(ctx context.Context, transactionID uuid.UUID, amount int64, reason string) (*Refund, error) {
+	tx, err := r.Db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	// 1. Lock the original so concurrent partial refunds can't exceed it
+	original, err := getTransaction(ctx, tx, transactionID, true)
+	if err != nil {
+		return nil, err
+	}
+
+	if original.OriginalTransactionID != nil {
+		return nil, fmt.Errorf("%w: refunds cannot be refunded", ErrNotRefundable)
+	}
+	if original.Status != "COMPLETED" && original.Status != StatusPartiallyRefunded {
+		return nil, fmt.Errorf("%w: status is %s", ErrNotRefundable, original.Status)
+	}
+	// An authorization only reserves money; the hold is voided or captured (and the capture refunded)
+	var authorization bool
+	if err := tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM holds WHERE authorization_transaction_id = $1)`, original.ID).Scan(&authorization); err != nil {
+		return nil, err
+	}
+	if authorization {
+		return nil, fmt.Errorf("%w: hold authorizations are voided, not refunded", ErrNotRefundable)
+	}
+	// Reversing a conversion would hand back the source amount at the old locked rate,
+	// turning every quote into a free option; convert back with a new quote instead
+	var conversion bool
+	if err := tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM fx_quotes WHERE transaction_id = $1)`, original.ID).Scan(&conversion); err != nil {
+		return nil, err
+	}
+	if conversion {
+		return nil, fmt.Errorf("%w: currency conversions cannot be refunded", ErrNotRefundable)
+	}
+
+	remaining := original.Amount - original.RefundedAmount
+	if amount == 0 {
+		amount = remaining
+	}
+	if amount <= 0 || amount > remaining {
+		return nil, ErrRefundExceedsBalance
+	}
+
+	// 2. Build the compensating entries
+	reversed, err := reverseEntries(original.Entries, amount, remaining)
+	if err != nil {
+		return nil, err
+	}
+	// A captured hold took its money from HOLDS, but the refund goes back to whoever paid the hold
+	payerID, holdsID, captured, err := capturedHoldPayer(ctx, tx, original)
+	if err != nil {
+		return nil, err
+	}
+	if captured {
+		for i := range reversed {
+			if reversed[i].AccountID == holdsID {
+				reversed[i].AccountID = payerID
+			}
+		}
+	}
+
+	// 3. Lock every affected account in a stable order to avoid deadlocks with transfers
+	accountIDs := make([]uuid.UUID, 0, len(reversed))
+	for _, e := range reversed {
+		accountIDs = append(accountIDs, e.AccountID)
+	}
+	sort.Slice(accountIDs, func(i, j int) bool { return accountIDs[i].String() < accountIDs[j].String() })
+
+	for _, id := range accountIDs {
+		if _, err := tx.Exec(ctx, `SELECT 1 FROM accounts WHERE id = $1 FOR UPDATE`, id); err != nil {
+			return nil, err
+		}
+	}
+
+	// 4. Whoever received the money must still have it available
+	for _, e := range reversed {
+		if e.Direction != "DEBIT" {
+			continue
+		}
+		// System accounts (funding, clearing...) are allowed to go negative
+		system, err := isSystemAccount(ctx, tx, e.AccountID)
+		if err != nil {
+			return nil, err
+		}
+		if system {
+			continue
+		}
+		// Overdrafts and credit lines may cover a refund too
+		var balance int64
+		if err := tx.QueryRow(ctx, `SELECT balance + `+creditAllowanceSQL+` FROM accounts WHERE id = $1`, e.AccountID).Scan(&balance); err != nil {
+			return nil, err
+		}
+		pending, err := pendingBalance(ctx, tx, e.AccountID)
+		if err != nil {
+			return nil, err
+		}
+		if available := balance - pending; available < e.Amount {
+			return nil, fmt.Errorf("%w to refund: %d available but refund needs %d", ErrInsufficientFunds, available, e.Amount)
+		}
+	}
+
+	// 5. Book the refund
+	refund := Refund{
+		OriginalTransactionID: original.ID,
+		Amount:                amount,
+		Currency:              original.Currency,
+		Reason:                reason,
+	}
+	err = tx.QueryRow(ctx, `
+		INSERT INTO transactions (amount, currency, description, status, original_transaction_id, livemode)
+		VALUES ($1, $2, $3, 'COMPLETED', $4, $5) RETURNING id, created_at`,
+		amount, original.Currency, "Refund: "+reason, original.ID, original.Livemode).Scan(&refund.ID, &refund.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, e := range reversed {
+		if e.Amount == 0 {
+			continue
+		}
+		if err := postEntry(ctx, tx, refund.ID, e.AccountID, e.Direction, domain.NewMoney(e.Amount, domain.Currency(e.Currency))); err != nil {
+			return nil, err
+		}
+		// reversed[i] mirrors original.Entries[i]
+		if _, err := tx.Exec(ctx, `UPDATE entries SET refunded_amount = refunded_amount + $1 WHERE id = $2`,
+			e.Amount, original.Entries[i].ID); err != nil {
+			return nil, err
+		}
+	}
+
+	// Taxes given back are reported against the refund
+	if err := reverseTaxLines(ctx, tx, original, refund.ID, reversed, amount); err != nil {
+		return nil, err
+	}
+
+	// 6. Move the original through PARTIALLY_REFUNDED -> REFUNDED
+	refund.OriginalStatus = StatusPartiallyRefunded
+	if amount == remaining {
+		refund.OriginalStatus = StatusRefunded
+	}
+	refund.RemainingRefundable = remaining - amount
+
+	_, err = tx.Exec(ctx, `UPDATE transactions SET refunded_amount = refunded_amount + $1, status = $2 WHERE id = $3`,
+		amount, refund.OriginalStatus, original.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 7. Tell the merchant (queued in the same transaction)
+	err = enqueueWebhook(ctx, tx, "refund.succeeded", map[string]interface{}{
+		"id":                      refund.ID,
+		"original_transaction_id": refund.OriginalTransactionID,
+		"amount":                  refund.Amount,
+		"currency":                refund.Currency,
+		"reason":                  refund.Reason,
+		"original_status":         refund.OriginalStatus,
+		"remaining_refundable":    refund.RemainingRefundable,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &refund, tx.Commit(ctx)
+}
+
+// reverseEntries mirrors entries for a refund of part out of remaining, the amount of the
+// transaction not yet refunded; reversed[i] mirrors entries[i]. Each leg gives back its share
+// of what it has left (Amount - RefundedAmount), scaled per currency and direction, and each
+// group's total is allocated back over its legs, so a transaction with several legs (splits,
+// fees) still balances after rounding. The last refund reverses exactly what is left on each leg.
+func reverseEntries(entries []Entry, part, remaining int64) ([]Entry, error) {
+	type group struct {
+		currency, direction string
+	}
+	members := make(map[group][]int)
+	for i, e := range entries {
+		g := group{e.Currency, e.Direction}
+		members[g] = append(members[g], i)
+	}
+
+	reversed := make([]Entry, len(entries))
+	for g, idx := range members {
+		var total int64
+		ratios := make([]int64, len(idx))
+		for i, j := range idx {
+			ratios[i] = max(entries[j].Amount-entries[j].RefundedAmount, 0)
+			total += ratios[i]
+		}
+
+		direction := "DEBIT"
+		if g.direction == "DEBIT" {
+			direction = "CREDIT"
+		}
+		for _, j := range idx {
+			reversed[j] = Entry{AccountID: entries[j].AccountID, Direction: direction, Currency: g.currency}
+		}
+		// Nothing left to give back on this side
+		if total <= 0 {
+			continue
+		}
+
+		scaled := domain.NewMoney(scaleAmount(total, part, remaining), domain.Currency(g.currency))
+		shares, err := scaled.Allocate(ratios...)
+		if err != nil {
+			return nil, err
+		}
+		for i, j := range idx {
+			reversed[j].Amount = shares[i].Amount
+		}
+	}
+	return reversed, nil
+}
+
+// scaleAmount returns entryAmount * part / whole without overflowing int64 on the way.
+func scaleAmount(entryAmount, part, whole int64) int64 {
+	if entryAmount == whole {
+		return part
+	}
+	n := new(big.Int).Mul(big.NewInt(entryAmount), big.NewInt(part))
+	return n.Quo(n, big.NewInt(whole)).Int64()
+}
@@ -0,0 +1,360 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/ibrahimkeyboad/gopay/internal/core/domain"
+	"github.com/ibrahimkeyboad/gopay/internal/core/schedule"
+)
+
+// ScheduleStatus is where a scheduled transfer is in its life
+type ScheduleStatus string
+
+const (
+	ScheduleActive    ScheduleStatus = "ACTIVE"
+	ScheduleCompleted ScheduleStatus = "COMPLETED" // Every occurrence has run (or was given up)
+	ScheduleCancelled ScheduleStatus = "CANCELLED"
+	ScheduleFailed    ScheduleStatus = "FAILED" // Stopped by an error retrying can't fix
+)
+
+var (
+	ErrScheduledTransferNotFound = errors.New("scheduled transfer not found")
+	ErrScheduleNotActive         = errors.New("scheduled transfer is no longer active")
+)
+
+// ScheduledTransfer moves Amount from one account to another at StartAt and, for recurring
+// schedules, every Frequency after that until EndAt or MaxRuns occurrences
+type ScheduledTransfer struct {
+	ID            uuid.UUID          `json:"id"`
+	FromAccountID uuid.UUID          `json:"from_account_id"`
+	ToAccountID   uuid.UUID          `json:"to_account_id"`
+	Amount        int64              `json:"amount"`
+	Currency      string             `json:"currency"`
+	Description   string             `json:"description"`
+	Frequency     schedule.Frequency `json:"frequency"`
+	StartAt       time.Time          `json:"start_at"`
+	EndAt         *time.Time         `json:"end_at,omitempty"`
+	MaxRuns       *int               `json:"max_runs,omitempty"`
+	Occurrence    int                `json:"occurrence"` // Index of the next occurrence
+	RunsCompleted int                `json:"runs_completed"`
+	Retries       int                `json:"retries"` // Retries made for the next occurrence
+	NextRunAt     *time.Time         `json:"next_run_at,omitempty"`
+	Status        ScheduleStatus     `json:"status"`
+	LastError     *string            `json:"last_error,omitempty"`
+	CreatedAt     time.Time          `json:"created_at"`
+
+	Runs []ScheduledTransferRun `json:"runs,omitempty"`
+}
+
+// ScheduledTransferRun is one attempt at an occurrence
+type ScheduledTransferRun struct {
+	Occurrence    int        `json:"occurrence"`
+	TransactionID *uuid.UUID `json:"transaction_id,omitempty"`
+	Status        string     `json:"status"` // SUCCEEDED or FAILED
+	Error         *string    `json:"error,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+const scheduledTransferColumns = `id, from_account_id, to_account_id, amount, currency, description, frequency,
+	start_at, end_at, max_runs, occurrence, runs_completed, retries, next_run_at, status, last_error, created_at`
+
+func scanScheduledTransfer(row pgx.Row) (*ScheduledTransfer, error) {
+	var s ScheduledTransfer
+	err := row.Scan(&s.ID, &s.FromAccountID, &s.ToAccountID, &s.Amount, &s.Currency, &s.Description, &s.Frequency,
+		&s.StartAt, &s.EndAt, &s.MaxRuns, &s.Occurrence, &s.RunsCompleted, &s.Retries, &s.NextRunAt, &s.Status, &s.LastError, &s.CreatedAt)
+	if err == pgx.ErrNoRows {
+		return nil, ErrScheduledTransferNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// CreateScheduledTransfer saves a schedule. Both accounts must exist, be customer accounts
+// and hold the transfer currency; funds are only checked when each run happens.
+func (r *LedgerRepository) CreateScheduledTransfer(ctx context.Context, s ScheduledTransfer) (*ScheduledTransfer, error) {
+	tx, err := r.Db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	var fromCurrency domain.Currency
+	var system bool
+	var status AccountStatus
+	err = tx.QueryRow(ctx, `SELECT currency, system_kind IS NOT NULL, status FROM accounts WHERE id = $1`, s.FromAccountID).
+		Scan(&fromCurrency, &system, &status)
+	if err == pgx.ErrNoRows {
+		return nil, ErrAccountNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if system {
+		return nil, ErrSystemAccount
+	}
+	if status == AccountClosed {
+		return nil, ErrAccountClosed
+	}
+
+	toCurrency, err := destinationCurrency(ctx, tx, s.ToAccountID)
+	if err != nil {
+		return nil, err
+	}
+	if fromCurrency != domain.Currency(s.Currency) || toCurrency != fromCurrency {
+		return nil, fmt.Errorf("%w: scheduled transfers must be in the currency of both accounts", ErrCurrencyMismatch)
+	}
+	if err := sameMode(ctx, tx, s.FromAccountID, s.ToAccountID); err != nil {
+		return nil, err
+	}
+
+	created, err := scanScheduledTransfer(tx.QueryRow(ctx, `
+		INSERT INTO scheduled_transfers (from_account_id, to_account_id, amount, currency, description, frequency,
+			start_at, end_at, max_runs, next_run_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $7)
+		RETURNING `+scheduledTransferColumns,
+		s.FromAccountID, s.ToAccountID, s.Amount, s.Currency, s.Description, s.Frequency, s.StartAt, s.EndAt, s.MaxRuns))
+	if err != nil {
+		return nil, err
+	}
+
+	return created, tx.Commit(ctx)
+}
+
+// GetScheduledTransfer fetches a schedule with its run history, newest first
+func (r *LedgerRepository) GetScheduledTransfer(ctx context.Context, id uuid.UUID) (*ScheduledTransfer, error) {
+	s, err := scanScheduledTransfer(r.Db.QueryRow(ctx, `SELECT `+scheduledTransferColumns+` FROM scheduled_transfers WHERE id = $1`, id))
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.Db.Query(ctx, `
+		SELECT occurrence, transaction_id, status, error, created_at
+		FROM scheduled_transfer_runs WHERE scheduled_transfer_id = $1
+		ORDER BY created_at DESC`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	s.Runs = []ScheduledTransferRun{}
+	for rows.Next() {
+		var run ScheduledTransferRun
+		if err := rows.Scan(&run.Occurrence, &run.TransactionID, &run.Status, &run.Error, &run.CreatedAt); err != nil {
+			return nil, err
+		}
+		s.Runs = append(s.Runs, run)
+	}
+	return s, rows.Err()
+}
+
+// ListScheduledTransfers returns the schedules paying out of an account, newest first
+func (r *LedgerRepository) ListScheduledTransfers(ctx context.Context, accountID uuid.UUID) ([]ScheduledTransfer, error) {
+	rows, err := r.Db.Query(ctx, `
+		SELECT `+scheduledTransferColumns+` FROM scheduled_transfers
+		WHERE from_account_id = $1
+		ORDER BY created_at DESC`, accountID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	schedules := []ScheduledTransfer{}
+	for rows.Next() {
+		s, err := scanScheduledTransfer(rows)
+		if err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, *s)
+	}
+	return schedules, rows.Err()
+}
+
+// CancelScheduledTransfer stops an active schedule. A run already in progress finishes first.
+func (r *LedgerRepository) CancelScheduledTransfer(ctx context.Context, id uuid.UUID) (*ScheduledTransfer, error) {
+	tx, err := r.Db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	s, err := scanScheduledTransfer(tx.QueryRow(ctx, `SELECT `+scheduledTransferColumns+` FROM scheduled_transfers WHERE id = $1 FOR UPDATE`, id))
+	if err != nil {
+		return nil, err
+	}
+	if s.Status != ScheduleActive {
+		return nil, fmt.Errorf("%w: status is %s", ErrScheduleNotActive, s.Status)
+	}
+
+	s, err = scanScheduledTransfer(tx.QueryRow(ctx, `
+		UPDATE scheduled_transfers SET status = $1, next_run_at = NULL, updated_at = NOW()
+		WHERE id = $2 RETURNING `+scheduledTransferColumns, ScheduleCancelled, id))
+	if err != nil {
+		return nil, err
+	}
+
+	err = enqueueWebhook(ctx, tx, "scheduled_transfer.cancelled", map[string]interface{}{
+		"scheduled_transfer_id": s.ID,
+		"from_account_id":       s.FromAccountID,
+		"runs_completed":        s.RunsCompleted,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s, tx.Commit(ctx)
+}
+
+// RunDueTransfer runs the oldest scheduled transfer due at now, if any, and reports whether
+// it found one. The schedule row is taken with FOR UPDATE SKIP LOCKED and held for the whole
+// run, so several workers can poll at once and an occurrence is never paid twice.
+//
+// A run that fails for reasons that can pass (see retryableRun: lack of funds, a limit, a
+// frozen account) is retried according to policy, as long as the retry comes before the next
+// occurrence; after that the occurrence is given up and the schedule carries on. Any other
+// error stops the schedule. Every outcome is recorded and sent as a webhook.
+func (r *LedgerRepository) RunDueTransfer(ctx context.Context, now time.Time, policy schedule.RetryPolicy) (bool, error) {
+	tx, err := r.Db.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	s, err := scanScheduledTransfer(tx.QueryRow(ctx, `
+		SELECT `+scheduledTransferColumns+` FROM scheduled_transfers
+		WHERE status = 'ACTIVE' AND next_run_at <= $1
+		ORDER BY next_run_at
+		LIMIT 1
+		FOR UPDATE SKIP LOCKED`, now))
+	if errors.Is(err, ErrScheduledTransferNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	// The transfer runs in a savepoint so a failed attempt can still be recorded
+	attempt, err := tx.Begin(ctx)
+	if err != nil {
+		return true, err
+	}
+	amount := domain.NewMoney(s.Amount, domain.Currency(s.Currency))
+	txn, runErr := transferTx(ctx, attempt, s.FromAccountID, s.ToAccountID, amount, s.Description, nil)
+	if runErr == nil {
+		err = attempt.Commit(ctx)
+	} else {
+		err = attempt.Rollback(ctx)
+	}
+	if err != nil {
+		return true, err
+	}
+
+	occurrence := s.Occurrence
+	event := "scheduled_transfer.succeeded"
+	data := map[string]interface{}{
+		"scheduled_transfer_id": s.ID,
+		"occurrence":            occurrence,
+		"from_account_id":       s.FromAccountID,
+		"to_account_id":         s.ToAccountID,
+		"amount":                s.Amount,
+		"currency":              s.Currency,
+	}
+
+	var transactionID *uuid.UUID
+	switch {
+	case runErr == nil:
+		transactionID = &txn.ID
+		data["transaction_id"] = txn.ID
+		s.RunsCompleted++
+		s.LastError = nil
+		s.advance()
+
+	case retryableRun(runErr):
+		event = "scheduled_transfer.failed"
+		message := runErr.Error()
+		s.LastError = &message
+		delay, ok := policy.Next(s.Retries)
+		retryAt := now.Add(delay)
+		if ok && (s.Frequency == schedule.Once || retryAt.Before(schedule.Occurrence(s.StartAt, s.Frequency, s.Occurrence+1))) {
+			s.Retries++
+			s.NextRunAt = &retryAt
+			data["next_attempt_at"] = retryAt
+		} else {
+			s.advance()
+			if s.Frequency == schedule.Once {
+				s.Status = ScheduleFailed
+			}
+		}
+
+	default:
+		event = "scheduled_transfer.failed"
+		message := runErr.Error()
+		s.LastError = &message
+		s.Status = ScheduleFailed
+		s.NextRunAt = nil
+	}
+	data["will_retry"] = runErr != nil && s.Status == ScheduleActive && s.Occurrence == occurrence
+	data["schedule_status"] = s.Status
+	if s.LastError != nil {
+		data["error"] = *s.LastError
+	}
+
+	runStatus := "SUCCEEDED"
+	if runErr != nil {
+		runStatus = "FAILED"
+	}
+	_, err = tx.Exec(ctx, `
+		INSERT INTO scheduled_transfer_runs (scheduled_transfer_id, occurrence, transaction_id, status, error)
+		VALUES ($1, $2, $3, $4, $5)`, s.ID, occurrence, transactionID, runStatus, s.LastError)
+	if err != nil {
+		return true, err
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE scheduled_transfers
+		SET occurrence = $1, runs_completed = $2, retries = $3, next_run_at = $4, status = $5, last_error = $6, updated_at = NOW()
+		WHERE id = $7`,
+		s.Occurrence, s.RunsCompleted, s.Retries, s.NextRunAt, s.Status, s.LastError, s.ID)
+	if err != nil {
+		return true, err
+	}
+
+	if err := enqueueWebhook(ctx, tx, event, data); err != nil {
+		return true, err
+	}
+
+	return true, tx.Commit(ctx)
+}
+
+// retryableRun reports whether a failed run may succeed later without anyone changing the
+// schedule: funds arrive, a daily or monthly limit resets, an account is unfrozen
+func retryableRun(err error) bool {
+	var limitErr *domain.LimitError
+	return errors.Is(err, ErrInsufficientFunds) || errors.As(err, &limitErr) || errors.Is(err, ErrAccountFrozen)
+}
+
+// advance moves a schedule on to its next occurrence, completing it when there is none
+func (s *ScheduledTransfer) advance() {
+	s.Occurrence++
+	s.Retries = 0
+
+	if s.Frequency == schedule.Once || (s.MaxRuns != nil && s.Occurrence >= *s.MaxRuns) {
+		s.Status = ScheduleCompleted
+		s.NextRunAt = nil
+		return
+	}
+	next := schedule.Occurrence(s.StartAt, s.Frequency, s.Occurrence)
+	if s.EndAt != nil && next.After(*s.EndAt) {
+		s.Status = ScheduleCompleted
+		s.NextRunAt = nil
+		return
+	}
+	s.NextRunAt = &next
+}
@@ -0,0 +1,141 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/ibrahimkeyboad/gopay/internal/core/statement"
+)
+
+// SnapshotLag is how far in the past a snapshot moment must be: transactions stamped before
+// it may still be uncommitted for a while, and a snapshot taken too early would miss them
+const SnapshotLag = 10 * time.Minute
+
+var ErrSnapshotTooRecent = errors.New("snapshot moment is too recent")
+
+// balanceBefore returns the sum of an account's entries from transactions created strictly
+// before t, starting from the latest snapshot at or before t
+func balanceBefore(ctx context.Context, q queryer, accountID uuid.UUID, t time.Time) (int64, error) {
+	var balance int64
+	err := q.QueryRow(ctx, `
+		SELECT COALESCE(s.balance, 0) + COALESCE((
+			SELECT SUM(CASE WHEN e.direction = 'CREDIT' THEN e.amount ELSE -e.amount END)
+			FROM entries e JOIN transactions t ON t.id = e.transaction_id
+			WHERE e.account_id = $1
+				AND t.created_at < $2
+				AND (s.snapshot_at IS NULL OR t.created_at >= s.snapshot_at)), 0)
+		FROM (SELECT 1) one
+		LEFT JOIN LATERAL (
+			SELECT balance, snapshot_at FROM balance_snapshots
+			WHERE account_id = $1 AND snapshot_at <= $2
+			ORDER BY snapshot_at DESC LIMIT 1
+		) s ON true`, accountID, t).Scan(&balance)
+	return balance, err
+}
+
+// TakeBalanceSnapshots records every account's balance as of at (entries created before it).
+// Each snapshot builds on the previous one, and existing snapshots are left alone, so it is
+// safe to call repeatedly. Moments less than SnapshotLag before the database's clock are
+// refused with ErrSnapshotTooRecent, since a transaction created before them may still be in flight.
+func (r *LedgerRepository) TakeBalanceSnapshots(ctx context.Context, at time.Time) (int64, error) {
+	// created_at is stamped by the database, so its clock decides what is settled
+	var now time.Time
+	if err := r.Db.QueryRow(ctx, `SELECT NOW()`).Scan(&now); err != nil {
+		return 0, err
+	}
+	if cutoff := now.Add(-SnapshotLag); at.After(cutoff) {
+		return 0, fmt.Errorf("%w: %s is after %s", ErrSnapshotTooRecent, at.Format(time.RFC3339), cutoff.Format(time.RFC3339))
+	}
+
+	tag, err := r.Db.Exec(ctx, `
+		INSERT INTO balance_snapshots (account_id, snapshot_at, currency, balance)
+		SELECT a.id, $1, a.currency, COALESCE(s.balance, 0) + COALESCE((
+			SELECT SUM(CASE WHEN e.direction = 'CREDIT' THEN e.amount ELSE -e.amount END)
+			FROM entries e JOIN transactions t ON t.id = e.transaction_id
+			WHERE e.account_id = a.id
+				AND t.created_at < $1
+				AND (s.snapshot_at IS NULL OR t.created_at >= s.snapshot_at)), 0)
+		FROM accounts a
+		LEFT JOIN LATERAL (
+			SELECT balance, snapshot_at FROM balance_snapshots
+			WHERE account_id = a.id AND snapshot_at <= $1
+			ORDER BY snapshot_at DESC LIMIT 1
+		) s ON true
+		ON CONFLICT (account_id, snapshot_at) DO NOTHING`, at)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// GetStatement builds a statement for [from, to): the opening balance, every entry in the
+// range with a running balance, and the closing balance. It reads a single snapshot of the
+// database so the totals always add up.
+func (r *LedgerRepository) GetStatement(ctx context.Context, accountID uuid.UUID, from, to time.Time) (*statement.Statement, error) {
+	tx, err := r.Db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `SET TRANSACTION ISOLATION LEVEL REPEATABLE READ READ ONLY`); err != nil {
+		return nil, err
+	}
+
+	s := &statement.Statement{AccountID: accountID, From: from, To: to, Lines: []statement.Line{}}
+	err = tx.QueryRow(ctx, `SELECT owner_name, currency FROM accounts WHERE id = $1`, accountID).Scan(&s.OwnerName, &s.Currency)
+	if err == pgx.ErrNoRows {
+		return nil, ErrAccountNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if s.OpeningBalance, err = balanceBefore(ctx, tx, accountID, from); err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.Query(ctx, `
+		SELECT t.id, t.created_at, t.description, e.direction, e.amount,
+			COALESCE((SELECT f.fee FROM fees f WHERE f.transaction_id = t.id AND f.account_id = e.account_id), 0),
+			COALESCE((SELECT SUM(tl.amount) FROM tax_lines tl
+				WHERE tl.transaction_id = t.id AND tl.account_id = e.account_id AND tl.kind = 'VAT'), 0)
+		FROM entries e
+		JOIN transactions t ON t.id = e.transaction_id
+		WHERE e.account_id = $1 AND t.created_at >= $2 AND t.created_at < $3
+		ORDER BY t.created_at, t.id, e.direction`, accountID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	balance := s.OpeningBalance
+	for rows.Next() {
+		var l statement.Line
+		if err := rows.Scan(&l.TransactionID, &l.CreatedAt, &l.Description, &l.Direction, &l.Amount, &l.Fee, &l.Tax); err != nil {
+			return nil, err
+		}
+		if l.Direction == "CREDIT" {
+			balance += l.Amount
+			s.TotalCredits += l.Amount
+		} else {
+			balance -= l.Amount
+			s.TotalDebits += l.Amount
+		}
+		l.Balance = balance
+		s.TotalFees += l.Fee
+		s.TotalTax += l.Tax
+		s.Lines = append(s.Lines, l)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	s.ClosingBalance = balance
+	return s, nil
+}
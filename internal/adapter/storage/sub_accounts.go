@@ -0,0 +1,124 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/ibrahimkeyboad/gopay/internal/core/domain"
+)
+
+var (
+	ErrNotLinked        = errors.New("accounts are not a parent and one of its children")
+	ErrNestedSubAccount = errors.New("a sub-account cannot have sub-accounts of its own")
+)
+
+// CreateChildAccount opens a sub-account owned by parentID
+func (r *AccountRepository) CreateChildAccount(ctx context.Context, parentID uuid.UUID, ownerName, currency string) (*Account, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	var grandparent *uuid.UUID
+	var system bool
+	var status AccountStatus
+	err = tx.QueryRow(ctx, `SELECT parent_id, system_kind IS NOT NULL, status FROM accounts WHERE id = $1 FOR SHARE`, parentID).
+		Scan(&grandparent, &system, &status)
+	if err == pgx.ErrNoRows {
+		return nil, ErrAccountNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case system:
+		return nil, ErrSystemAccount
+	case grandparent != nil:
+		return nil, ErrNestedSubAccount
+	case status == AccountClosed:
+		return nil, ErrAccountClosed
+	}
+
+	account, err := scanAccount(tx.QueryRow(ctx, `
+		INSERT INTO accounts (owner_name, currency, balance, parent_id, livemode)
+		VALUES ($1, $2, 0, $3, (SELECT livemode FROM accounts WHERE id = $3))
+		RETURNING `+accountColumns, ownerName, currency, parentID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create sub-account: %w", err)
+	}
+
+	return account, tx.Commit(ctx)
+}
+
+// ListChildren returns a platform's sub-accounts, oldest first
+func (r *AccountRepository) ListChildren(ctx context.Context, parentID uuid.UUID) ([]Account, error) {
+	rows, err := r.db.Query(ctx, `SELECT `+accountColumns+` FROM accounts WHERE parent_id = $1 ORDER BY created_at, id`, parentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	accounts := []Account{}
+	for rows.Next() {
+		acc, err := scanAccount(rows)
+		if err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, *acc)
+	}
+	return accounts, rows.Err()
+}
+
+// ChildIDs returns the IDs of a platform's sub-accounts
+func (r *AccountRepository) ChildIDs(ctx context.Context, parentID uuid.UUID) ([]uuid.UUID, error) {
+	rows, err := r.db.Query(ctx, `SELECT id FROM accounts WHERE parent_id = $1`, parentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []uuid.UUID{}
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// linked reports whether one of the two accounts is the parent of the other
+func linked(ctx context.Context, q queryer, a, b uuid.UUID) (bool, error) {
+	var ok bool
+	err := q.QueryRow(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM accounts
+			WHERE (id = $1 AND parent_id = $2) OR (id = $2 AND parent_id = $1)
+		)`, a, b).Scan(&ok)
+	return ok, err
+}
+
+// PlatformTransfer moves money between a platform and one of its sub-accounts, in
+// either direction (funding a seller, sweeping a seller's earnings back). The link is
+// checked inside the ledger transaction; otherwise it behaves like Transfer.
+func (r *LedgerRepository) PlatformTransfer(ctx context.Context, fromID, toID uuid.UUID, amount domain.Money, description string) (*Transaction, error) {
+	if description == "" {
+		description = "Platform Transfer"
+	}
+	return r.transfer(ctx, fromID, toID, amount, description, func(tx pgx.Tx) error {
+		ok, err := linked(ctx, tx, fromID, toID)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrNotLinked
+		}
+		return nil
+	})
+}
@@ -0,0 +1,108 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/ibrahimkeyboad/gopay/internal/core/domain"
+	"github.com/ibrahimkeyboad/gopay/internal/core/tax"
+)
+
+// SystemAccountKind identifies an internal ledger account. There is exactly one
+// account per kind and currency, and merchants can never hold keys for them.
+type SystemAccountKind string
+
+const (
+	SystemFunding        SystemAccountKind = "FUNDING"          // Counterparty for manual deposits
+	SystemRevenue        SystemAccountKind = "PLATFORM_REVENUE" // Fees we earn
+	SystemCardSettlement SystemAccountKind = "CARD_SETTLEMENT"  // Card money owed to us by the acquirer
+	SystemSuspense       SystemAccountKind = "SUSPENSE"         // Money we received but couldn't attribute
+	SystemFXClearing     SystemAccountKind = "FX_CLEARING"      // One leg of every currency conversion
+	SystemHolds          SystemAccountKind = "HOLDS"            // Money reserved by authorized holds until capture or release
+)
+
+// MobileMoneyFloat is the float we hold with a mobile money provider, e.g. "MOBILE_MONEY_FLOAT:VODACOM"
+func MobileMoneyFloat(provider domain.MobileProvider) SystemAccountKind {
+	return SystemAccountKind("MOBILE_MONEY_FLOAT:" + string(provider))
+}
+
+var (
+	ErrSystemAccount        = errors.New("system accounts cannot be used directly")
+	ErrSystemAccountMissing = errors.New("system account missing, has BootstrapSystemAccounts run?")
+)
+
+// TaxLiability is the account holding a tax we collected until it is paid to the authority
+func TaxLiability(kind tax.Kind) SystemAccountKind {
+	return SystemAccountKind("TAX_PAYABLE:" + string(kind))
+}
+
+// systemAccountKinds lists what BootstrapSystemAccounts creates for a currency
+func systemAccountKinds(currency domain.Currency) []SystemAccountKind {
+	kinds := []SystemAccountKind{SystemFunding, SystemRevenue, SystemCardSettlement, SystemSuspense, SystemFXClearing, SystemHolds}
+	for _, k := range tax.Kinds {
+		kinds = append(kinds, TaxLiability(k))
+	}
+
+	// Mobile money is only collected in TZS
+	if currency == domain.TZS {
+		for _, p := range domain.MobileProviders {
+			kinds = append(kinds, MobileMoneyFloat(p))
+		}
+	}
+	return kinds
+}
+
+// BootstrapSystemAccounts creates every system account for every supported currency,
+// once for live mode and once for test mode. It is idempotent and runs on each startup.
+func BootstrapSystemAccounts(ctx context.Context, db *pgxpool.Pool) error {
+	created := 0
+	for _, livemode := range []bool{true, false} {
+		for _, info := range domain.SupportedCurrencies() {
+			for _, kind := range systemAccountKinds(info.Code) {
+				name := fmt.Sprintf("GoPay %s (%s)", kind, info.Code)
+				if !livemode {
+					name += " [TEST]"
+				}
+				tag, err := db.Exec(ctx, `
+					INSERT INTO accounts (owner_name, currency, balance, system_kind, livemode)
+					VALUES ($1, $2, 0, $3, $4)
+					ON CONFLICT (system_kind, currency, livemode) WHERE system_kind IS NOT NULL DO NOTHING`,
+					name, info.Code, kind, livemode)
+				if err != nil {
+					return fmt.Errorf("failed to create %s account for %s: %w", kind, info.Code, err)
+				}
+				created += int(tag.RowsAffected())
+			}
+		}
+	}
+
+	slog.Info("🏦 System accounts ready", "created", created)
+	return nil
+}
+
+// systemAccount returns the internal account for kind and currency in live or test mode
+func systemAccount(ctx context.Context, tx pgx.Tx, kind SystemAccountKind, currency domain.Currency, livemode bool) (uuid.UUID, error) {
+	var id uuid.UUID
+	err := tx.QueryRow(ctx, `SELECT id FROM accounts WHERE system_kind = $1 AND currency = $2 AND livemode = $3`,
+		kind, currency, livemode).Scan(&id)
+	if err == pgx.ErrNoRows {
+		return uuid.Nil, fmt.Errorf("%w: %s %s", ErrSystemAccountMissing, kind, currency)
+	}
+	return id, err
+}
+
+// isSystemAccount reports whether id belongs to an internal account
+func isSystemAccount(ctx context.Context, q queryer, id uuid.UUID) (bool, error) {
+	var system bool
+	err := q.QueryRow(ctx, `SELECT system_kind IS NOT NULL FROM accounts WHERE id = $1`, id).Scan(&system)
+	if err == pgx.ErrNoRows {
+		return false, ErrAccountNotFound
+	}
+	return system, err
+}
@@ -0,0 +1,174 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/ibrahimkeyboad/gopay/internal/core/domain"
+	"github.com/ibrahimkeyboad/gopay/internal/core/tax"
+)
+
+// TaxSettings are how an account's fees are taxed
+type TaxSettings struct {
+	Jurisdiction   string `json:"jurisdiction"`
+	WithholdingTax bool   `json:"withholding_tax"`
+}
+
+// bookTaxLines credits each tax to its TAX_PAYABLE account and records the line for the
+// monthly report. The caller books the matching debit (the merchant's or our fee).
+func bookTaxLines(ctx context.Context, tx pgx.Tx, transactionID, accountID uuid.UUID, lines []tax.Line, livemode bool) error {
+	for _, l := range lines {
+		if l.Amount <= 0 {
+			continue
+		}
+		liabilityID, err := systemAccount(ctx, tx, TaxLiability(l.Kind), l.Currency, livemode)
+		if err != nil {
+			return err
+		}
+		if err := postEntry(ctx, tx, transactionID, liabilityID, "CREDIT", domain.NewMoney(l.Amount, l.Currency)); err != nil {
+			return err
+		}
+		if err := insertTaxLine(ctx, tx, transactionID, accountID, l); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func insertTaxLine(ctx context.Context, tx pgx.Tx, transactionID, accountID uuid.UUID, l tax.Line) error {
+	_, err := tx.Exec(ctx, `
+		INSERT INTO tax_lines (transaction_id, account_id, kind, jurisdiction, rate_bps, base, amount, currency)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		transactionID, accountID, l.Kind, l.Jurisdiction, l.Bps, l.Base, l.Amount, l.Currency)
+	return err
+}
+
+// reverseTaxLines records the tax given back by a refund as negative lines. Amounts are taken
+// from the refund's entries on the TAX_PAYABLE accounts so the report matches the ledger.
+func reverseTaxLines(ctx context.Context, tx pgx.Tx, original *Transaction, refundID uuid.UUID, reversed []Entry, part int64) error {
+	rows, err := tx.Query(ctx, `
+		SELECT account_id, kind, jurisdiction, rate_bps, base, amount, currency
+		FROM tax_lines WHERE transaction_id = $1`, original.ID)
+	if err != nil {
+		return err
+	}
+	type booked struct {
+		accountID uuid.UUID
+		line      tax.Line
+	}
+	var lines []booked
+	for rows.Next() {
+		var o booked
+		if err := rows.Scan(&o.accountID, &o.line.Kind, &o.line.Jurisdiction, &o.line.Bps, &o.line.Base, &o.line.Amount, &o.line.Currency); err != nil {
+			rows.Close()
+			return err
+		}
+		lines = append(lines, o)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, o := range lines {
+		liabilityID, err := systemAccount(ctx, tx, TaxLiability(o.line.Kind), o.line.Currency, original.Livemode)
+		if err != nil {
+			return err
+		}
+		var amount int64
+		for _, e := range reversed {
+			if e.AccountID == liabilityID {
+				amount += e.Amount
+			}
+		}
+		if amount == 0 {
+			continue
+		}
+		l := o.line
+		l.Base = -scaleAmount(l.Base, part, original.Amount)
+		l.Amount = -amount
+		if err := insertTaxLine(ctx, tx, refundID, o.accountID, l); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetTaxSettings changes how an account's fees are taxed and queues an account.updated webhook
+func (r *AccountRepository) SetTaxSettings(ctx context.Context, id uuid.UUID, settings TaxSettings) (*Account, error) {
+	settings.Jurisdiction = strings.ToUpper(settings.Jurisdiction)
+	if settings.Jurisdiction == "" {
+		return nil, fmt.Errorf("jurisdiction is required")
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	var system bool
+	err = tx.QueryRow(ctx, `SELECT system_kind IS NOT NULL FROM accounts WHERE id = $1 FOR UPDATE`, id).Scan(&system)
+	if err == pgx.ErrNoRows {
+		return nil, ErrAccountNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if system {
+		return nil, ErrSystemAccount
+	}
+
+	_, err = tx.Exec(ctx, `UPDATE accounts SET tax_jurisdiction = $1, withholding_tax = $2 WHERE id = $3`,
+		settings.Jurisdiction, settings.WithholdingTax, id)
+	if err != nil {
+		return nil, err
+	}
+
+	err = enqueueWebhook(ctx, tx, "account.updated", map[string]interface{}{
+		"account_id":       id,
+		"tax_jurisdiction": settings.Jurisdiction,
+		"withholding_tax":  settings.WithholdingTax,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	account, err := scanAccount(tx.QueryRow(ctx, `SELECT `+accountColumns+` FROM accounts WHERE id = $1`, id))
+	if err != nil {
+		return nil, err
+	}
+
+	return account, tx.Commit(ctx)
+}
+
+// TaxReport totals the live tax lines booked in [from, to) by jurisdiction, kind and currency.
+// Refunds in the period reduce the totals.
+func (r *LedgerRepository) TaxReport(ctx context.Context, month string, from, to time.Time) (*tax.Report, error) {
+	rows, err := r.Db.Query(ctx, `
+		SELECT tl.jurisdiction, tl.kind, tl.currency, COUNT(DISTINCT tl.transaction_id), SUM(tl.base), SUM(tl.amount)
+		FROM tax_lines tl
+		JOIN transactions t ON t.id = tl.transaction_id
+		WHERE tl.created_at >= $1 AND tl.created_at < $2 AND t.livemode
+		GROUP BY tl.jurisdiction, tl.kind, tl.currency
+		ORDER BY tl.jurisdiction, tl.kind, tl.currency`, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	report := &tax.Report{Month: month, From: from, To: to, Lines: []tax.ReportLine{}}
+	for rows.Next() {
+		var l tax.ReportLine
+		if err := rows.Scan(&l.Jurisdiction, &l.Kind, &l.Currency, &l.Transactions, &l.Base, &l.Amount); err != nil {
+			return nil, err
+		}
+		report.Lines = append(report.Lines, l)
+	}
+	return report, rows.Err()
+}
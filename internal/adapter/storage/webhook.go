@@ -0,0 +1,33 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// enqueueWebhook queues an event for the webhook worker inside the caller's
+// transaction, so the event is only sent if the ledger change commits.
+func enqueueWebhook(ctx context.Context, tx pgx.Tx, event string, data map[string]interface{}) error {
+	webhookURL := os.Getenv("WEBHOOK_URL")
+	if webhookURL == "" {
+		slog.Warn("⚠️ No WEBHOOK_URL found in .env, skipping webhook queue", "event", event)
+		return nil
+	}
+
+	data["timestamp"] = time.Now()
+	payloadJSON, err := json.Marshal(map[string]interface{}{
+		"event": event,
+		"data":  data,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, `INSERT INTO webhook_jobs (url, payload) VALUES ($1, $2)`, webhookURL, payloadJSON)
+	return err
+}
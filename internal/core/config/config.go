@@ -3,6 +3,8 @@ package config
 import (
 	"log/slog" // Use the new structured logger
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -12,6 +14,24 @@ type Config struct {
 	DatabaseURL string
 	WebhookURL  string
 	Env         string
+
+	// AdminAPIKey is the bootstrap credential for /v1/admin: it creates and revokes operators,
+	// who then use their own keys. Empty leaves only operator keys.
+	AdminAPIKey string
+
+	// FX: rates come from FXRatesFile when set, otherwise from the fx_rates table
+	FXRatesFile string
+	FXSpreadBps int64
+	FXQuoteTTL  time.Duration
+
+	// PricingPlansFile overrides the built-in pricing plans (see internal/core/pricing)
+	PricingPlansFile string
+
+	// TaxRatesFile overrides the built-in tax rates (see internal/core/tax)
+	TaxRatesFile string
+
+	// KeyRollOverlap is how long a rolled API key keeps working by default
+	KeyRollOverlap time.Duration
 }
 
 // LoadConfig reads .env file and returns a Config struct
@@ -28,6 +48,16 @@ func LoadConfig() *Config {
 		DatabaseURL: getEnv("DATABASE_URL", ""),
 		WebhookURL:  getEnv("WEBHOOK_URL", ""),
 		Env:         getEnv("ENV", "development"),
+		AdminAPIKey: getEnv("ADMIN_API_KEY", ""),
+
+		FXRatesFile: getEnv("FX_RATES_FILE", ""),
+		FXSpreadBps: getEnvInt("FX_SPREAD_BPS", 150),
+		FXQuoteTTL:  time.Duration(getEnvInt("FX_QUOTE_TTL_SECONDS", 60)) * time.Second,
+
+		PricingPlansFile: getEnv("PRICING_PLANS_FILE", ""),
+		TaxRatesFile:     getEnv("TAX_RATES_FILE", ""),
+
+		KeyRollOverlap: time.Duration(getEnvInt("API_KEY_ROLL_OVERLAP_SECONDS", 24*60*60)) * time.Second,
 	}
 }
 
@@ -37,4 +67,18 @@ func getEnv(key, fallback string) string {
 		return value
 	}
 	return fallback
-}
\ No newline at end of file
+}
+
+// Helper to get an integer env with a default fallback
+func getEnvInt(key string, fallback int64) int64 {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return fallback
+	}
+	parsed, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		slog.Warn("Invalid integer in env, using default", "key", key, "value", value)
+		return fallback
+	}
+	return parsed
+}
@@ -59,4 +59,20 @@ func passesLuhn(number string) bool {
 		alternate = !alternate
 	}
 	return sum%10 == 0
-}
\ No newline at end of file
+}
+// Test cards decide the outcome of charges made in test mode; any other valid card is approved.
+// They are refused outright in live mode.
+var testCards = map[string]string{
+	"4242424242424242": "",
+	"5555555555554444": "",
+	"4000000000000002": "card_declined",
+	"4000000000009995": "insufficient_funds",
+	"4000000000000069": "expired_card",
+}
+
+// TestCard reports whether number is a test card and the decline it simulates ("" = approved)
+func TestCard(number string) (decline string, ok bool) {
+	cleanNum := strings.NewReplacer(" ", "", "-", "").Replace(number)
+	decline, ok = testCards[cleanNum]
+	return decline, ok
+}
@@ -0,0 +1,175 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrUnsupportedCurrency = errors.New("unsupported currency")
+	ErrInvalidAmount       = errors.New("invalid amount")
+	ErrAmountTooSmall      = errors.New("amount too low")
+	ErrAmountTooLarge      = errors.New("amount too high")
+)
+
+// CurrencyInfo describes an ISO 4217 currency and the limits we apply to it.
+// MinAmount and MaxAmount are per transaction, in minor units.
+type CurrencyInfo struct {
+	Code      Currency `json:"code"`
+	Exponent  int      `json:"exponent"` // Digits after the decimal point (USD 2, UGX 0)
+	Symbol    string   `json:"symbol"`
+	MinAmount int64    `json:"min_amount"`
+	MaxAmount int64    `json:"max_amount"`
+}
+
+// currencies is the single source of truth for what we accept
+var currencies = map[Currency]CurrencyInfo{
+	TZS: {Code: TZS, Exponent: 2, Symbol: "TSh", MinAmount: 500_00, MaxAmount: 10_000_000_00},
+	USD: {Code: USD, Exponent: 2, Symbol: "$", MinAmount: 1_00, MaxAmount: 50_000_00},
+	KES: {Code: KES, Exponent: 2, Symbol: "KSh", MinAmount: 10_00, MaxAmount: 500_000_00},
+	UGX: {Code: UGX, Exponent: 0, Symbol: "USh", MinAmount: 500, MaxAmount: 20_000_000},
+	EUR: {Code: EUR, Exponent: 2, Symbol: "€", MinAmount: 1_00, MaxAmount: 50_000_00},
+}
+
+// LookupCurrency returns the registry entry for an ISO code ("tzs" and "TZS" both work)
+func LookupCurrency(code string) (CurrencyInfo, error) {
+	info, ok := currencies[Currency(strings.ToUpper(strings.TrimSpace(code)))]
+	if !ok {
+		return CurrencyInfo{}, fmt.Errorf("%w: %q", ErrUnsupportedCurrency, code)
+	}
+	return info, nil
+}
+
+// MinorUnits is how many minor units make one major unit (100 for USD, 1 for UGX)
+func (i CurrencyInfo) MinorUnits() int64 {
+	units := int64(1)
+	for n := 0; n < i.Exponent; n++ {
+		units *= 10
+	}
+	return units
+}
+
+// Info returns the registry entry for c
+func (c Currency) Info() (CurrencyInfo, error) {
+	return LookupCurrency(string(c))
+}
+
+// SupportedCurrencies lists the registry sorted by code
+func SupportedCurrencies() []CurrencyInfo {
+	list := make([]CurrencyInfo, 0, len(currencies))
+	for _, info := range currencies {
+		list = append(list, info)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Code < list[j].Code })
+	return list
+}
+
+// ValidateAmount checks a transaction amount against its currency's limits
+func ValidateAmount(m Money) error {
+	info, err := m.Currency.Info()
+	if err != nil {
+		return err
+	}
+	if m.Amount < info.MinAmount {
+		return fmt.Errorf("%w: minimum is %s (amounts are in minor units)", ErrAmountTooSmall, NewMoney(info.MinAmount, m.Currency))
+	}
+	if m.Amount > info.MaxAmount {
+		return fmt.Errorf("%w: maximum is %s", ErrAmountTooLarge, NewMoney(info.MaxAmount, m.Currency))
+	}
+	return nil
+}
+
+// ParseMoney turns a decimal string like "1,250.50" into minor units of currency.
+// More fraction digits than the currency allows is an error, never a silent rounding.
+func ParseMoney(value string, currency Currency) (Money, error) {
+	info, err := currency.Info()
+	if err != nil {
+		return Money{}, err
+	}
+
+	s := strings.ReplaceAll(strings.TrimSpace(value), ",", "")
+	negative := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(s, "-")
+
+	whole, frac, hasDot := strings.Cut(s, ".")
+	if whole == "" || (hasDot && frac == "") {
+		return Money{}, fmt.Errorf("%w: %q", ErrInvalidAmount, value)
+	}
+	if len(frac) > info.Exponent {
+		return Money{}, fmt.Errorf("%w: %s allows at most %d decimal places", ErrInvalidAmount, currency, info.Exponent)
+	}
+	frac += strings.Repeat("0", info.Exponent-len(frac))
+
+	for _, r := range whole + frac {
+		if r < '0' || r > '9' {
+			return Money{}, fmt.Errorf("%w: %q", ErrInvalidAmount, value)
+		}
+	}
+
+	amount, err := strconv.ParseInt(whole+frac, 10, 64)
+	if err != nil {
+		return Money{}, fmt.Errorf("%w: %q is out of range", ErrInvalidAmount, value)
+	}
+	if negative {
+		amount = -amount
+	}
+
+	return NewMoney(amount, info.Code), nil
+}
+
+// Decimal formats the amount in major units with the currency's exponent, e.g. "1250.50"
+func (m Money) Decimal() string {
+	exponent := 0
+	if info, err := m.Currency.Info(); err == nil {
+		exponent = info.Exponent
+	}
+
+	sign := ""
+	abs := uint64(m.Amount)
+	if m.Amount < 0 {
+		sign = "-"
+		abs = uint64(-(m.Amount + 1)) + 1 // safe for math.MinInt64
+	}
+
+	digits := strconv.FormatUint(abs, 10)
+	if exponent == 0 {
+		return sign + digits
+	}
+	if len(digits) <= exponent {
+		digits = strings.Repeat("0", exponent-len(digits)+1) + digits
+	}
+	cut := len(digits) - exponent
+	return sign + digits[:cut] + "." + digits[cut:]
+}
+
+// String formats Money for humans, e.g. "TSh 1,250.50"
+func (m Money) String() string {
+	decimal := m.Decimal()
+
+	sign := ""
+	if strings.HasPrefix(decimal, "-") {
+		sign, decimal = "-", decimal[1:]
+	}
+	whole, frac, hasFrac := strings.Cut(decimal, ".")
+
+	// Thousands separators
+	var grouped strings.Builder
+	for i, r := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			grouped.WriteByte(',')
+		}
+		grouped.WriteRune(r)
+	}
+	if hasFrac {
+		grouped.WriteString("." + frac)
+	}
+
+	symbol := string(m.Currency)
+	if info, err := m.Currency.Info(); err == nil {
+		symbol = info.Symbol
+	}
+	return sign + symbol + " " + grouped.String()
+}
@@ -0,0 +1,151 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// KYCTier is how far an account has been verified. Higher tiers move more money.
+type KYCTier string
+
+const (
+	TierUnverified KYCTier = "TIER_0" // Phone number only
+	TierBasic      KYCTier = "TIER_1" // National ID (NIDA) checked
+	TierFull       KYCTier = "TIER_2" // Full due diligence (business documents, address)
+)
+
+var KYCTiers = []KYCTier{TierUnverified, TierBasic, TierFull}
+
+// ParseKYCTier validates a tier from a request
+func ParseKYCTier(name string) (KYCTier, error) {
+	t := KYCTier(strings.ToUpper(strings.TrimSpace(name)))
+	for _, known := range KYCTiers {
+		if t == known {
+			return t, nil
+		}
+	}
+	return "", fmt.Errorf("unsupported KYC tier %q", name)
+}
+
+// Limits caps one direction of money movement, in minor units. Zero means no cap.
+type Limits struct {
+	PerTransaction int64 `json:"per_transaction"`
+	Daily          int64 `json:"daily"`
+	Monthly        int64 `json:"monthly"`
+}
+
+// TierLimits are the caps for money leaving (Debit) and arriving (Credit)
+type TierLimits struct {
+	Debit  Limits `json:"debit"`
+	Credit Limits `json:"credit"`
+}
+
+// same applies one set of caps to both directions
+func same(l Limits) TierLimits { return TierLimits{Debit: l, Credit: l} }
+
+// kycLimits is the single source of truth for tier limits, per currency.
+// TZS figures follow the BoT e-money caps for unverified and basic wallets.
+var kycLimits = map[KYCTier]map[Currency]TierLimits{
+	TierUnverified: {
+		TZS: same(Limits{PerTransaction: 1_000_000_00, Daily: 1_000_000_00, Monthly: 5_000_000_00}),
+		KES: same(Limits{PerTransaction: 50_000_00, Daily: 50_000_00, Monthly: 200_000_00}),
+		UGX: same(Limits{PerTransaction: 1_500_000, Daily: 1_500_000, Monthly: 7_000_000}),
+		USD: same(Limits{PerTransaction: 400_00, Daily: 400_00, Monthly: 2_000_00}),
+		EUR: same(Limits{PerTransaction: 400_00, Daily: 400_00, Monthly: 2_000_00}),
+	},
+	TierBasic: {
+		TZS: same(Limits{PerTransaction: 5_000_000_00, Daily: 5_000_000_00, Monthly: 20_000_000_00}),
+		KES: same(Limits{PerTransaction: 250_000_00, Daily: 250_000_00, Monthly: 1_000_000_00}),
+		UGX: same(Limits{PerTransaction: 7_000_000, Daily: 7_000_000, Monthly: 28_000_000}),
+		USD: same(Limits{PerTransaction: 2_000_00, Daily: 2_000_00, Monthly: 8_000_00}),
+		EUR: same(Limits{PerTransaction: 2_000_00, Daily: 2_000_00, Monthly: 8_000_00}),
+	},
+	TierFull: {
+		TZS: same(Limits{Daily: 50_000_000_00, Monthly: 500_000_000_00}),
+		KES: same(Limits{Daily: 2_500_000_00, Monthly: 25_000_000_00}),
+		UGX: same(Limits{Daily: 70_000_000, Monthly: 700_000_000}),
+		USD: same(Limits{Daily: 20_000_00, Monthly: 200_000_00}),
+		EUR: same(Limits{Daily: 20_000_00, Monthly: 200_000_00}),
+	},
+}
+
+// LimitsFor returns the caps for a tier in a currency. Unknown combinations get
+// the unverified caps in that currency, so a missing entry never means "unlimited".
+func LimitsFor(tier KYCTier, currency Currency) (TierLimits, error) {
+	if limits, ok := kycLimits[tier][currency]; ok {
+		return limits, nil
+	}
+	if limits, ok := kycLimits[TierUnverified][currency]; ok {
+		return limits, nil
+	}
+	return TierLimits{}, fmt.Errorf("%w: no KYC limits for %s", ErrUnsupportedCurrency, currency)
+}
+
+// limitsZone is where a "day" and a "month" start: East Africa Time (UTC+3, no DST)
+var limitsZone = time.FixedZone("EAT", 3*60*60)
+
+// LimitWindows returns the start of the current day and month for daily and monthly limits
+func LimitWindows(now time.Time) (dayStart, monthStart time.Time) {
+	local := now.In(limitsZone)
+	dayStart = time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, limitsZone)
+	monthStart = time.Date(local.Year(), local.Month(), 1, 0, 0, 0, 0, limitsZone)
+	return dayStart, monthStart
+}
+
+var ErrLimitExceeded = errors.New("transaction limit exceeded")
+
+// Limit error codes returned to API clients
+const (
+	CodePerTransactionLimit = "PER_TRANSACTION_LIMIT_EXCEEDED"
+	CodeDailyLimit          = "DAILY_LIMIT_EXCEEDED"
+	CodeMonthlyLimit        = "MONTHLY_LIMIT_EXCEEDED"
+)
+
+// LimitError says which limit a movement broke. errors.Is(err, ErrLimitExceeded) matches it.
+type LimitError struct {
+	Code      string   `json:"code"`
+	Window    string   `json:"window"` // per_transaction, daily or monthly
+	Tier      KYCTier  `json:"tier"`
+	Direction string   `json:"direction"` // DEBIT or CREDIT
+	Currency  Currency `json:"currency"`
+	Limit     int64    `json:"limit"`
+	Used      int64    `json:"used"`      // Already moved in the window, before this transaction
+	Attempted int64    `json:"attempted"` // This transaction
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%s: %s %s %s limit is %s (used %s, attempted %s)",
+		ErrLimitExceeded, e.Tier, strings.ReplaceAll(e.Window, "_", "-"), strings.ToLower(e.Direction),
+		NewMoney(e.Limit, e.Currency), NewMoney(e.Used, e.Currency), NewMoney(e.Attempted, e.Currency))
+}
+
+func (e *LimitError) Unwrap() error { return ErrLimitExceeded }
+
+// CheckLimits compares one movement against a tier's caps. used is what already moved
+// in the same direction today and this month, not counting amount.
+func CheckLimits(tier KYCTier, direction string, amount Money, usedToday, usedThisMonth int64) error {
+	limits, err := LimitsFor(tier, amount.Currency)
+	if err != nil {
+		return err
+	}
+	l := limits.Credit
+	if direction == "DEBIT" {
+		l = limits.Debit
+	}
+
+	fail := func(code, window string, limit, used int64) error {
+		return &LimitError{Code: code, Window: window, Tier: tier, Direction: direction, Currency: amount.Currency,
+			Limit: limit, Used: used, Attempted: amount.Amount}
+	}
+	switch {
+	case l.PerTransaction > 0 && amount.Amount > l.PerTransaction:
+		return fail(CodePerTransactionLimit, "per_transaction", l.PerTransaction, 0)
+	case l.Daily > 0 && usedToday+amount.Amount > l.Daily:
+		return fail(CodeDailyLimit, "daily", l.Daily, usedToday)
+	case l.Monthly > 0 && usedThisMonth+amount.Amount > l.Monthly:
+		return fail(CodeMonthlyLimit, "monthly", l.Monthly, usedThisMonth)
+	}
+	return nil
+}
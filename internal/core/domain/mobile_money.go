@@ -0,0 +1,38 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+)
+
+type MobileProvider string
+
+// Tanzanian mobile money networks we collect from
+const (
+	Vodacom MobileProvider = "VODACOM" // M-Pesa
+	Tigo    MobileProvider = "TIGO"    // Tigo Pesa
+	Airtel  MobileProvider = "AIRTEL"  // Airtel Money
+)
+
+// MobileProviders lists every supported network
+var MobileProviders = []MobileProvider{Vodacom, Tigo, Airtel}
+
+// ParseMobileProvider validates a provider name from a request
+func ParseMobileProvider(name string) (MobileProvider, error) {
+	p := MobileProvider(strings.ToUpper(strings.TrimSpace(name)))
+	for _, known := range MobileProviders {
+		if p == known {
+			return p, nil
+		}
+	}
+	return "", fmt.Errorf("unsupported mobile money provider %q", name)
+}
+
+// TestNumberCancelled is the phone number suffix that simulates a customer cancelling
+// the USSD prompt in test mode. Every other test payment succeeds.
+const TestNumberCancelled = "0000"
+
+// TestPaymentSucceeds decides a test mode mobile money payment from the phone number alone
+func TestPaymentSucceeds(phone string) bool {
+	return !strings.HasSuffix(phone, TestNumberCancelled)
+}
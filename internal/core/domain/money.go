@@ -3,13 +3,19 @@ package domain
 import (
 	"errors"
 	"fmt"
+	"math/big"
+	"sort"
 )
 
 type Currency string
 
+// Currencies we know about. Their exponents and limits live in the registry in currency.go.
 const (
 	USD Currency = "USD"
 	TZS Currency = "TZS"
+	KES Currency = "KES"
+	UGX Currency = "UGX"
+	EUR Currency = "EUR"
 )
 
 // Money struct holds amount in "minor units" (cents)
@@ -27,13 +33,33 @@ func NewMoney(amount int64, currency Currency) Money {
 	}
 }
 
+var (
+	ErrCurrencyMismatch = errors.New("currency mismatch")
+	ErrOverflow         = errors.New("amount overflows int64")
+	ErrInvalidRatios    = errors.New("ratios must be non-negative and sum to more than zero")
+)
+
+// RoundingMode decides what happens to fractions of a minor unit
+type RoundingMode int
+
+const (
+	RoundHalfEven RoundingMode = iota // Banker's rounding: 2.5 -> 2, 3.5 -> 4
+	RoundHalfUp                       // 2.5 -> 3, -2.5 -> -3 (away from zero)
+	RoundFloor                        // Towards negative infinity: 2.9 -> 2, -2.1 -> -3
+)
+
 // Add adds two Money instances safely
 func (m Money) Add(other Money) (Money, error) {
 	if m.Currency != other.Currency {
-		return Money{}, fmt.Errorf("currency mismatch: cannot add %s to %s", other.Currency, m.Currency)
+		return Money{}, fmt.Errorf("%w: cannot add %s to %s", ErrCurrencyMismatch, other.Currency, m.Currency)
+	}
+	sum := m.Amount + other.Amount
+	// Overflow happens only when both signs agree and the result's sign flips
+	if (other.Amount > 0 && sum < m.Amount) || (other.Amount < 0 && sum > m.Amount) {
+		return Money{}, ErrOverflow
 	}
 	return Money{
-		Amount:   m.Amount + other.Amount,
+		Amount:   sum,
 		Currency: m.Currency,
 	}, nil
 }
@@ -41,13 +67,144 @@ func (m Money) Add(other Money) (Money, error) {
 // Subtract subtracts Money safely
 func (m Money) Subtract(other Money) (Money, error) {
 	if m.Currency != other.Currency {
-		return Money{}, errors.New("currency mismatch")
+		return Money{}, ErrCurrencyMismatch
 	}
 	if m.Amount < other.Amount {
 		return Money{}, errors.New("insufficient balance")
 	}
+	diff := m.Amount - other.Amount
+	if (other.Amount < 0 && diff < m.Amount) || (other.Amount > 0 && diff > m.Amount) {
+		return Money{}, ErrOverflow
+	}
 	return Money{
-		Amount:   m.Amount - other.Amount,
+		Amount:   diff,
 		Currency: m.Currency,
 	}, nil
-}
\ No newline at end of file
+}
+
+// Multiply scales Money by a whole number (e.g. quantity)
+func (m Money) Multiply(factor int64) (Money, error) {
+	product := new(big.Int).Mul(big.NewInt(m.Amount), big.NewInt(factor))
+	if !product.IsInt64() {
+		return Money{}, ErrOverflow
+	}
+	return NewMoney(product.Int64(), m.Currency), nil
+}
+
+// MultiplyByRate scales Money by an exact rate (fee percentage, tax rate, FX rate)
+// and rounds the result to a whole minor unit using mode.
+func (m Money) MultiplyByRate(rate *big.Rat, mode RoundingMode) (Money, error) {
+	value := new(big.Rat).Mul(new(big.Rat).SetInt64(m.Amount), rate)
+	amount, err := RoundRat(value, mode)
+	if err != nil {
+		return Money{}, err
+	}
+	return NewMoney(amount, m.Currency), nil
+}
+
+// RoundRat rounds an exact fraction of minor units to a whole minor unit
+func RoundRat(value *big.Rat, mode RoundingMode) (int64, error) {
+	num, den := value.Num(), value.Denom() // den is always > 0
+
+	// Floor division: quotient rounded towards negative infinity
+	quo, rem := new(big.Int).DivMod(num, den, new(big.Int)) // Euclidean: 0 <= rem < den
+
+	switch mode {
+	case RoundFloor:
+		// DivMod already floors because rem is never negative
+	case RoundHalfUp, RoundHalfEven:
+		// Compare the remainder with half the denominator
+		twice := new(big.Int).Lsh(rem, 1)
+		cmp := twice.Cmp(den)
+		switch {
+		case cmp > 0:
+			quo.Add(quo, big.NewInt(1))
+		case cmp == 0:
+			if mode == RoundHalfUp {
+				// Away from zero: positives go up, negatives stay at the floor
+				if num.Sign() > 0 {
+					quo.Add(quo, big.NewInt(1))
+				}
+			} else if quo.Bit(0) == 1 {
+				// Banker's: move to the even neighbour
+				quo.Add(quo, big.NewInt(1))
+			}
+		}
+	default:
+		return 0, fmt.Errorf("unknown rounding mode %d", mode)
+	}
+
+	if !quo.IsInt64() {
+		return 0, ErrOverflow
+	}
+	return quo.Int64(), nil
+}
+
+// Allocate splits m by ratios without losing a single minor unit.
+// Each part gets its floored share; the leftover units go one at a time to
+// the parts with the largest remainders (earliest part wins ties), so the
+// parts always sum to m. Allocate(100, 1, 1, 1) -> 34, 33, 33.
+func (m Money) Allocate(ratios ...int64) ([]Money, error) {
+	if len(ratios) == 0 {
+		return nil, ErrInvalidRatios
+	}
+
+	total := new(big.Int)
+	for _, r := range ratios {
+		if r < 0 {
+			return nil, ErrInvalidRatios
+		}
+		total.Add(total, big.NewInt(r))
+	}
+	if total.Sign() == 0 {
+		return nil, ErrInvalidRatios
+	}
+
+	// Work on the magnitude so negative amounts split symmetrically
+	amount := big.NewInt(m.Amount)
+	negative := amount.Sign() < 0
+	amount.Abs(amount)
+
+	shares := make([]*big.Int, len(ratios))
+	remainders := make([]*big.Int, len(ratios))
+	allocated := new(big.Int)
+	for i, r := range ratios {
+		product := new(big.Int).Mul(amount, big.NewInt(r))
+		shares[i], remainders[i] = new(big.Int).QuoRem(product, total, new(big.Int))
+		allocated.Add(allocated, shares[i])
+	}
+
+	order := make([]int, len(ratios))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return remainders[order[a]].Cmp(remainders[order[b]]) > 0
+	})
+
+	leftover := new(big.Int).Sub(amount, allocated).Int64() // always < len(ratios)
+	for i := int64(0); i < leftover; i++ {
+		shares[order[i]].Add(shares[order[i]], big.NewInt(1))
+	}
+
+	parts := make([]Money, len(ratios))
+	for i, share := range shares {
+		if negative {
+			share.Neg(share)
+		}
+		parts[i] = NewMoney(share.Int64(), m.Currency)
+	}
+	return parts, nil
+}
+
+// Sum adds up Money values of the same currency, checking for overflow
+func Sum(currency Currency, values ...Money) (Money, error) {
+	total := NewMoney(0, currency)
+	for _, v := range values {
+		var err error
+		if total, err = total.Add(v); err != nil {
+			return Money{}, err
+		}
+	}
+	return total, nil
+}
@@ -0,0 +1,223 @@
+package domain
+
+import (
+	"errors"
+	"math"
+	"math/big"
+	"testing"
+)
+
+func TestMoneyAdd(t *testing.T) {
+	tests := []struct {
+		name    string
+		a, b    Money
+		want    int64
+		wantErr error
+	}{
+		{"positive", NewMoney(100, TZS), NewMoney(250, TZS), 350, nil},
+		{"negative", NewMoney(-100, TZS), NewMoney(-250, TZS), -350, nil},
+		{"mixed signs", NewMoney(100, TZS), NewMoney(-250, TZS), -150, nil},
+		{"up to max", NewMoney(math.MaxInt64-1, TZS), NewMoney(1, TZS), math.MaxInt64, nil},
+		{"down to min", NewMoney(math.MinInt64+1, TZS), NewMoney(-1, TZS), math.MinInt64, nil},
+		{"overflow", NewMoney(math.MaxInt64, TZS), NewMoney(1, TZS), 0, ErrOverflow},
+		{"underflow", NewMoney(math.MinInt64, TZS), NewMoney(-1, TZS), 0, ErrOverflow},
+		{"max plus min", NewMoney(math.MaxInt64, TZS), NewMoney(math.MinInt64, TZS), -1, nil},
+		{"currency mismatch", NewMoney(100, TZS), NewMoney(100, USD), 0, ErrCurrencyMismatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.a.Add(tt.b)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Add() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && (got.Amount != tt.want || got.Currency != tt.a.Currency) {
+				t.Errorf("Add() = %v, want %d %s", got, tt.want, tt.a.Currency)
+			}
+		})
+	}
+}
+
+func TestMoneySubtract(t *testing.T) {
+	tests := []struct {
+		name    string
+		a, b    Money
+		want    int64
+		wantErr error
+	}{
+		{"positive", NewMoney(350, TZS), NewMoney(100, TZS), 250, nil},
+		{"to zero", NewMoney(100, TZS), NewMoney(100, TZS), 0, nil},
+		{"negative operand", NewMoney(100, TZS), NewMoney(-50, TZS), 150, nil},
+		{"min minus min", NewMoney(math.MinInt64, TZS), NewMoney(math.MinInt64, TZS), 0, nil},
+		{"overflow", NewMoney(math.MaxInt64, TZS), NewMoney(-1, TZS), 0, ErrOverflow},
+		{"overflow from zero", NewMoney(0, TZS), NewMoney(math.MinInt64, TZS), 0, ErrOverflow},
+		{"currency mismatch", NewMoney(100, TZS), NewMoney(50, KES), 0, ErrCurrencyMismatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.a.Subtract(tt.b)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Subtract() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && got.Amount != tt.want {
+				t.Errorf("Subtract() = %d, want %d", got.Amount, tt.want)
+			}
+		})
+	}
+
+	// Going below zero is refused rather than returning a negative balance
+	if _, err := NewMoney(100, TZS).Subtract(NewMoney(101, TZS)); err == nil {
+		t.Error("Subtract() of a larger amount should fail")
+	}
+}
+
+func TestMoneyMultiply(t *testing.T) {
+	tests := []struct {
+		name    string
+		amount  int64
+		factor  int64
+		want    int64
+		wantErr error
+	}{
+		{"by zero", 1234, 0, 0, nil},
+		{"by quantity", 1250, 3, 3750, nil},
+		{"negative factor", 1250, -2, -2500, nil},
+		{"negative amount", -1250, 2, -2500, nil},
+		{"up to max", math.MaxInt64, 1, math.MaxInt64, nil},
+		{"min by one", math.MinInt64, 1, math.MinInt64, nil},
+		{"overflow", math.MaxInt64/2 + 1, 2, 0, ErrOverflow},
+		{"min by minus one", math.MinInt64, -1, 0, ErrOverflow},
+		{"large factors", 1 << 32, 1 << 32, 0, ErrOverflow},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewMoney(tt.amount, USD).Multiply(tt.factor)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Multiply() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && (got.Amount != tt.want || got.Currency != USD) {
+				t.Errorf("Multiply() = %v, want %d USD", got, tt.want)
+			}
+		})
+	}
+}
+
+var roundingModeNames = map[RoundingMode]string{
+	RoundHalfEven: "half-even",
+	RoundHalfUp:   "half-up",
+	RoundFloor:    "floor",
+}
+
+func TestMoneyMultiplyByRate(t *testing.T) {
+	tests := []struct {
+		name   string
+		amount int64
+		rate   string
+		want   map[RoundingMode]int64
+	}{
+		{"exact", 1000, "3/100", map[RoundingMode]int64{RoundHalfEven: 30, RoundHalfUp: 30, RoundFloor: 30}},
+		{"below half", 1010, "1/100", map[RoundingMode]int64{RoundHalfEven: 10, RoundHalfUp: 10, RoundFloor: 10}},
+		{"above half", 1070, "1/100", map[RoundingMode]int64{RoundHalfEven: 11, RoundHalfUp: 11, RoundFloor: 10}},
+		{"tie to even down", 25, "1/10", map[RoundingMode]int64{RoundHalfEven: 2, RoundHalfUp: 3, RoundFloor: 2}},
+		{"tie to even up", 35, "1/10", map[RoundingMode]int64{RoundHalfEven: 4, RoundHalfUp: 4, RoundFloor: 3}},
+		{"negative below half", -1010, "1/100", map[RoundingMode]int64{RoundHalfEven: -10, RoundHalfUp: -10, RoundFloor: -11}},
+		{"negative above half", -1070, "1/100", map[RoundingMode]int64{RoundHalfEven: -11, RoundHalfUp: -11, RoundFloor: -11}},
+		{"negative tie to even", -25, "1/10", map[RoundingMode]int64{RoundHalfEven: -2, RoundHalfUp: -3, RoundFloor: -3}},
+		{"negative tie odd", -35, "1/10", map[RoundingMode]int64{RoundHalfEven: -4, RoundHalfUp: -4, RoundFloor: -4}},
+		{"negative rate", 25, "-1/10", map[RoundingMode]int64{RoundHalfEven: -2, RoundHalfUp: -3, RoundFloor: -3}},
+		{"zero", 0, "18/100", map[RoundingMode]int64{RoundHalfEven: 0, RoundHalfUp: 0, RoundFloor: 0}},
+		{"fx rate", 10000, "2587.5", map[RoundingMode]int64{RoundHalfEven: 25875000, RoundHalfUp: 25875000, RoundFloor: 25875000}},
+		{"fx inverse", 100, "1/2587", map[RoundingMode]int64{RoundHalfEven: 0, RoundHalfUp: 0, RoundFloor: 0}},
+	}
+	for _, tt := range tests {
+		rate, ok := new(big.Rat).SetString(tt.rate)
+		if !ok {
+			t.Fatalf("bad rate %q", tt.rate)
+		}
+		for mode, want := range tt.want {
+			t.Run(tt.name+"/"+roundingModeNames[mode], func(t *testing.T) {
+				got, err := NewMoney(tt.amount, TZS).MultiplyByRate(rate, mode)
+				if err != nil {
+					t.Fatalf("MultiplyByRate(mode %d) error = %v", mode, err)
+				}
+				if got.Amount != want || got.Currency != TZS {
+					t.Errorf("MultiplyByRate(%d, %s, mode %d) = %v, want %d TZS", tt.amount, tt.rate, mode, got, want)
+				}
+			})
+		}
+	}
+}
+
+func TestMoneyMultiplyByRateErrors(t *testing.T) {
+	if _, err := NewMoney(math.MaxInt64, USD).MultiplyByRate(big.NewRat(2, 1), RoundHalfEven); !errors.Is(err, ErrOverflow) {
+		t.Errorf("MultiplyByRate() overflow error = %v, want %v", err, ErrOverflow)
+	}
+	if _, err := NewMoney(100, USD).MultiplyByRate(big.NewRat(1, 3), RoundingMode(99)); err == nil {
+		t.Error("MultiplyByRate() with an unknown rounding mode should fail")
+	}
+}
+
+func TestMoneyAllocate(t *testing.T) {
+	tests := []struct {
+		name    string
+		amount  int64
+		ratios  []int64
+		want    []int64
+		wantErr error
+	}{
+		{"even thirds", 100, []int64{1, 1, 1}, []int64{34, 33, 33}, nil},
+		{"remainder to largest fraction", 100, []int64{1, 2}, []int64{33, 67}, nil},
+		{"two leftovers", 101, []int64{1, 1, 1}, []int64{34, 34, 33}, nil},
+		{"exact", 1000, []int64{70, 20, 10}, []int64{700, 200, 100}, nil},
+		{"zero ratio gets nothing", 100, []int64{1, 0, 1}, []int64{50, 0, 50}, nil},
+		{"single part", 999, []int64{5}, []int64{999}, nil},
+		{"zero amount", 0, []int64{1, 2, 3}, []int64{0, 0, 0}, nil},
+		{"fewer units than parts", 2, []int64{1, 1, 1}, []int64{1, 1, 0}, nil},
+		{"negative is symmetric", -100, []int64{1, 1, 1}, []int64{-34, -33, -33}, nil},
+		{"ratios as amounts", 50, []int64{333, 667}, []int64{17, 33}, nil},
+		{"large amount", math.MaxInt64, []int64{1, 1}, []int64{math.MaxInt64/2 + 1, math.MaxInt64 / 2}, nil},
+		{"large ratios", 10, []int64{math.MaxInt64, math.MaxInt64}, []int64{5, 5}, nil},
+		{"no ratios", 100, nil, nil, ErrInvalidRatios},
+		{"all zero", 100, []int64{0, 0}, nil, ErrInvalidRatios},
+		{"negative ratio", 100, []int64{2, -1}, nil, ErrInvalidRatios},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parts, err := NewMoney(tt.amount, KES).Allocate(tt.ratios...)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Allocate() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if len(parts) != len(tt.want) {
+				t.Fatalf("Allocate() returned %d parts, want %d", len(parts), len(tt.want))
+			}
+			sum := new(big.Int)
+			for i, p := range parts {
+				if p.Amount != tt.want[i] || p.Currency != KES {
+					t.Errorf("part %d = %v, want %d KES", i, p, tt.want[i])
+				}
+				sum.Add(sum, big.NewInt(p.Amount))
+			}
+			if sum.Cmp(big.NewInt(tt.amount)) != 0 {
+				t.Errorf("parts sum to %s, want %d", sum, tt.amount)
+			}
+		})
+	}
+}
+
+func TestSum(t *testing.T) {
+	got, err := Sum(TZS, NewMoney(1, TZS), NewMoney(2, TZS), NewMoney(3, TZS))
+	if err != nil || got.Amount != 6 || got.Currency != TZS {
+		t.Errorf("Sum() = %v, %v; want 6 TZS", got, err)
+	}
+	if got, err := Sum(USD); err != nil || got.Amount != 0 {
+		t.Errorf("Sum() of nothing = %v, %v; want 0 USD", got, err)
+	}
+	if _, err := Sum(TZS, NewMoney(math.MaxInt64, TZS), NewMoney(1, TZS)); !errors.Is(err, ErrOverflow) {
+		t.Errorf("Sum() overflow error = %v, want %v", err, ErrOverflow)
+	}
+	if _, err := Sum(TZS, NewMoney(1, USD)); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Errorf("Sum() mismatch error = %v, want %v", err, ErrCurrencyMismatch)
+	}
+}
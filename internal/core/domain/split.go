@@ -0,0 +1,66 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrSplitExceedsTotal = errors.New("splits add up to more than the payment")
+
+// BasisPoints is a whole share: 10000 bps = 100%
+const BasisPoints = 10_000
+
+// SplitRule is one recipient's share of a payment: either a fixed Amount
+// (minor units) or Bps basis points of the total.
+type SplitRule struct {
+	Amount int64
+	Bps    int64
+}
+
+// Split divides total between rules and returns one part per rule plus the remainder,
+// which goes to the primary recipient. Percentage shares are allocated with Allocate,
+// so nothing is lost to rounding: the parts and the remainder always sum to total.
+func Split(total Money, rules []SplitRule) ([]Money, Money, error) {
+	if total.Amount <= 0 {
+		return nil, Money{}, fmt.Errorf("%w: split total must be positive", ErrInvalidAmount)
+	}
+
+	// Percentage shares, with the rest of 100% as the last ratio
+	var ratios []int64
+	var bpsTotal int64
+	for _, r := range rules {
+		switch {
+		case r.Amount < 0 || r.Bps < 0 || (r.Amount > 0 && r.Bps > 0):
+			return nil, Money{}, fmt.Errorf("%w: each split needs either an amount or a percentage", ErrInvalidAmount)
+		case r.Bps > 0:
+			bpsTotal += r.Bps
+			ratios = append(ratios, r.Bps)
+		}
+	}
+	if bpsTotal > BasisPoints {
+		return nil, Money{}, fmt.Errorf("%w: percentages add up to more than 100%%", ErrSplitExceedsTotal)
+	}
+	ratios = append(ratios, BasisPoints-bpsTotal)
+
+	shares, err := total.Allocate(ratios...)
+	if err != nil {
+		return nil, Money{}, err
+	}
+	remainder := shares[len(shares)-1]
+
+	parts := make([]Money, len(rules))
+	next := 0
+	for i, r := range rules {
+		if r.Bps > 0 {
+			parts[i] = shares[next]
+			next++
+			continue
+		}
+		parts[i] = NewMoney(r.Amount, total.Currency)
+		if parts[i].Amount > remainder.Amount {
+			return nil, Money{}, ErrSplitExceedsTotal
+		}
+		remainder.Amount -= parts[i].Amount
+	}
+	return parts, remainder, nil
+}
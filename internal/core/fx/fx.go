@@ -0,0 +1,163 @@
+package fx
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ibrahimkeyboad/gopay/internal/core/domain"
+)
+
+var ErrRateNotFound = errors.New("no exchange rate for currency pair")
+
+// RateSource returns the mid-market rate for converting one major unit of
+// base into quote (e.g. USD->TZS = 2650 means $1 buys TSh 2,650).
+type RateSource interface {
+	Rate(ctx context.Context, base, quote domain.Currency) (*big.Rat, error)
+}
+
+// Quote is a locked conversion offer. Once booked the rate no longer moves.
+type Quote struct {
+	From      domain.Money
+	To        domain.Money
+	MidRate   *big.Rat
+	Rate      *big.Rat // MidRate with the spread taken off
+	SpreadBps int64
+	ExpiresAt time.Time
+}
+
+// Engine prices conversions from a RateSource plus a spread in basis points
+type Engine struct {
+	Source    RateSource
+	SpreadBps int64
+	QuoteTTL  time.Duration
+	Rounding  domain.RoundingMode
+}
+
+// NewEngine creates an Engine. spreadBps of 150 means the customer gets 1.5% less than mid-market.
+// Converted amounts round half-up unless Rounding is changed.
+func NewEngine(source RateSource, spreadBps int64, quoteTTL time.Duration) *Engine {
+	return &Engine{Source: source, SpreadBps: spreadBps, QuoteTTL: quoteTTL, Rounding: domain.RoundHalfUp}
+}
+
+// Quote prices converting amount into the target currency
+func (e *Engine) Quote(ctx context.Context, amount domain.Money, to domain.Currency) (*Quote, error) {
+	if amount.Amount <= 0 {
+		return nil, fmt.Errorf("amount must be positive")
+	}
+	if amount.Currency == to {
+		return nil, fmt.Errorf("cannot convert %s to itself", to)
+	}
+
+	mid, err := e.lookup(ctx, amount.Currency, to)
+	if err != nil {
+		return nil, err
+	}
+
+	// rate = mid * (10000 - spread) / 10000
+	rate := new(big.Rat).Mul(mid, big.NewRat(10000-e.SpreadBps, 10000))
+
+	converted, err := Convert(amount, rate, to, e.Rounding)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Quote{
+		From:      amount,
+		To:        converted,
+		MidRate:   mid,
+		Rate:      rate,
+		SpreadBps: e.SpreadBps,
+		ExpiresAt: time.Now().Add(e.QuoteTTL),
+	}, nil
+}
+
+// lookup tries the direct pair first and falls back to inverting the reverse pair
+func (e *Engine) lookup(ctx context.Context, from, to domain.Currency) (*big.Rat, error) {
+	rate, err := e.Source.Rate(ctx, from, to)
+	if err == nil {
+		return rate, nil
+	}
+	if !errors.Is(err, ErrRateNotFound) {
+		return nil, err
+	}
+
+	inverse, err := e.Source.Rate(ctx, to, from)
+	if err != nil {
+		return nil, err
+	}
+	if inverse.Sign() == 0 {
+		return nil, fmt.Errorf("%w: %s/%s is zero", ErrRateNotFound, to, from)
+	}
+	return new(big.Rat).Inv(inverse), nil
+}
+
+// Convert applies rate (quoted in major units) to amount and rounds to a
+// minor unit of the target currency with mode. Currencies with different
+// exponents (e.g. USD cents -> whole UGX) are rescaled on the way.
+func Convert(amount domain.Money, rate *big.Rat, to domain.Currency, mode domain.RoundingMode) (domain.Money, error) {
+	fromInfo, err := amount.Currency.Info()
+	if err != nil {
+		return domain.Money{}, err
+	}
+	toInfo, err := to.Info()
+	if err != nil {
+		return domain.Money{}, err
+	}
+
+	// minor_to = minor_from * rate * 10^toExp / 10^fromExp
+	scaled := new(big.Rat).Mul(rate, big.NewRat(toInfo.MinorUnits(), fromInfo.MinorUnits()))
+	converted, err := amount.MultiplyByRate(scaled, mode)
+	if err != nil {
+		return domain.Money{}, err
+	}
+
+	return domain.NewMoney(converted.Amount, to), nil
+}
+
+// FileRates is a RateSource backed by a JSON file of "BASE/QUOTE": "rate" pairs,
+// e.g. {"USD/TZS": "2650.00", "USD/KES": "129.50"}
+type FileRates struct {
+	rates map[string]*big.Rat
+}
+
+// LoadFileRates reads and validates a rate file
+func LoadFileRates(path string) (*FileRates, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rate file: %w", err)
+	}
+
+	var pairs map[string]string
+	if err := json.Unmarshal(raw, &pairs); err != nil {
+		return nil, fmt.Errorf("failed to parse rate file: %w", err)
+	}
+
+	rates := make(map[string]*big.Rat, len(pairs))
+	for pair, value := range pairs {
+		if len(strings.Split(pair, "/")) != 2 {
+			return nil, fmt.Errorf("invalid currency pair %q, expected BASE/QUOTE", pair)
+		}
+		rate, ok := new(big.Rat).SetString(value)
+		if !ok || rate.Sign() <= 0 {
+			return nil, fmt.Errorf("invalid rate %q for %s", value, pair)
+		}
+		rates[strings.ToUpper(pair)] = rate
+	}
+
+	return &FileRates{rates: rates}, nil
+}
+
+// Rate implements RateSource
+func (f *FileRates) Rate(_ context.Context, base, quote domain.Currency) (*big.Rat, error) {
+	rate, ok := f.rates[string(base)+"/"+string(quote)]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s/%s", ErrRateNotFound, base, quote)
+	}
+	return new(big.Rat).Set(rate), nil
+}
@@ -0,0 +1,194 @@
+// Package pricing works out what we charge merchants for taking payments.
+package pricing
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/ibrahimkeyboad/gopay/internal/core/domain"
+)
+
+var ErrUnknownPlan = errors.New("unknown pricing plan")
+
+// Method is how the customer paid
+type Method string
+
+const (
+	MethodCard        Method = "CARD"
+	MethodMobileMoney Method = "MOBILE_MONEY"
+)
+
+// DefaultPlan is given to accounts that haven't been assigned one
+const DefaultPlan = "STANDARD"
+
+// Tier lowers the percentage once the merchant's monthly volume (gross, same method
+// and currency, before this payment) reaches FromVolume
+type Tier struct {
+	FromVolume int64 `json:"from_volume"`
+	Bps        int64 `json:"bps"`
+}
+
+// Rule prices one payment method in one currency. Amounts are in minor units.
+// The fee is Bps of the gross (or the matching tier's Bps) plus Fixed, kept within
+// [Min, Max] when they are set, and never more than the gross.
+type Rule struct {
+	Method   Method          `json:"method"`
+	Currency domain.Currency `json:"currency"`
+	Bps      int64           `json:"bps"`
+	Fixed    int64           `json:"fixed"`
+	Min      int64           `json:"min"`
+	Max      int64           `json:"max"` // 0 = no cap
+	Tiers    []Tier          `json:"tiers,omitempty"`
+}
+
+// Plan is a named set of rules. Payments with no matching rule are free.
+type Plan struct {
+	Name  string `json:"name"`
+	Rules []Rule `json:"rules"`
+}
+
+// Plans is the catalogue of pricing plans, by name
+type Plans map[string]Plan
+
+// Get returns a plan by name
+func (p Plans) Get(name string) (Plan, error) {
+	plan, ok := p[strings.ToUpper(name)]
+	if !ok {
+		return Plan{}, fmt.Errorf("%w %q", ErrUnknownPlan, name)
+	}
+	return plan, nil
+}
+
+// Rule finds the rule for a method and currency
+func (p Plan) Rule(method Method, currency domain.Currency) (Rule, bool) {
+	for _, r := range p.Rules {
+		if r.Method == method && r.Currency == currency {
+			return r, true
+		}
+	}
+	return Rule{}, false
+}
+
+// Fee prices one payment. monthVolume is the merchant's gross volume this month for the
+// same method and currency, before this payment; it picks the tier.
+func (p Plan) Fee(method Method, gross domain.Money, monthVolume int64) (domain.Money, error) {
+	rule, ok := p.Rule(method, gross.Currency)
+	if !ok {
+		return domain.NewMoney(0, gross.Currency), nil
+	}
+	return rule.Fee(gross, monthVolume)
+}
+
+// bps returns the percentage that applies at a monthly volume
+func (r Rule) bps(monthVolume int64) int64 {
+	bps := r.Bps
+	for _, t := range r.Tiers { // sorted by FromVolume
+		if monthVolume >= t.FromVolume {
+			bps = t.Bps
+		}
+	}
+	return bps
+}
+
+// Fee prices gross under this rule. The percentage part rounds half-up.
+func (r Rule) Fee(gross domain.Money, monthVolume int64) (domain.Money, error) {
+	rate := big.NewRat(r.bps(monthVolume), domain.BasisPoints)
+	fee, err := gross.MultiplyByRate(rate, domain.RoundHalfUp)
+	if err != nil {
+		return domain.Money{}, err
+	}
+	if fee, err = fee.Add(domain.NewMoney(r.Fixed, gross.Currency)); err != nil {
+		return domain.Money{}, err
+	}
+
+	if fee.Amount < r.Min {
+		fee.Amount = r.Min
+	}
+	if r.Max > 0 && fee.Amount > r.Max {
+		fee.Amount = r.Max
+	}
+	if fee.Amount > gross.Amount {
+		fee.Amount = gross.Amount
+	}
+	return fee, nil
+}
+
+// validate checks a plan loaded from a file
+func (p Plan) validate() error {
+	for _, r := range p.Rules {
+		if r.Method != MethodCard && r.Method != MethodMobileMoney {
+			return fmt.Errorf("plan %s: unsupported payment method %q", p.Name, r.Method)
+		}
+		if _, err := domain.LookupCurrency(string(r.Currency)); err != nil {
+			return fmt.Errorf("plan %s: %w", p.Name, err)
+		}
+		if r.Bps < 0 || r.Bps > domain.BasisPoints || r.Fixed < 0 || r.Min < 0 || r.Max < 0 || (r.Max > 0 && r.Min > r.Max) {
+			return fmt.Errorf("plan %s: invalid %s/%s rule", p.Name, r.Method, r.Currency)
+		}
+		for _, t := range r.Tiers {
+			if t.FromVolume < 0 || t.Bps < 0 || t.Bps > domain.BasisPoints {
+				return fmt.Errorf("plan %s: invalid tier in %s/%s rule", p.Name, r.Method, r.Currency)
+			}
+		}
+	}
+	return nil
+}
+
+// LoadPlans reads plans from a JSON file: a list of {"name": ..., "rules": [...]}.
+// The STANDARD plan must be among them.
+func LoadPlans(path string) (Plans, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pricing file: %w", err)
+	}
+
+	var list []Plan
+	if err := json.Unmarshal(raw, &list); err != nil {
+		return nil, fmt.Errorf("failed to parse pricing file: %w", err)
+	}
+
+	plans := make(Plans, len(list))
+	for _, p := range list {
+		p.Name = strings.ToUpper(p.Name)
+		if err := p.validate(); err != nil {
+			return nil, err
+		}
+		for i := range p.Rules {
+			p.Rules[i].Currency = domain.Currency(strings.ToUpper(string(p.Rules[i].Currency)))
+			sort.Slice(p.Rules[i].Tiers, func(a, b int) bool { return p.Rules[i].Tiers[a].FromVolume < p.Rules[i].Tiers[b].FromVolume })
+		}
+		plans[p.Name] = p
+	}
+	if _, ok := plans[DefaultPlan]; !ok {
+		return nil, fmt.Errorf("pricing file has no %s plan", DefaultPlan)
+	}
+	return plans, nil
+}
+
+// DefaultPlans is used when no pricing file is configured
+func DefaultPlans() Plans {
+	return Plans{
+		DefaultPlan: {
+			Name: DefaultPlan,
+			Rules: []Rule{
+				{Method: MethodCard, Currency: domain.TZS, Bps: 290, Fixed: 300_00, Tiers: []Tier{
+					{FromVolume: 50_000_000_00, Bps: 250},
+					{FromVolume: 200_000_000_00, Bps: 200},
+				}},
+				{Method: MethodCard, Currency: domain.USD, Bps: 290, Fixed: 30},
+				{Method: MethodCard, Currency: domain.EUR, Bps: 290, Fixed: 25},
+				// KSh 10 is the smallest payment: the fee and its VAT must still fit inside it
+				{Method: MethodCard, Currency: domain.KES, Bps: 290, Fixed: 5_00},
+				{Method: MethodCard, Currency: domain.UGX, Bps: 290, Fixed: 300},
+				{Method: MethodMobileMoney, Currency: domain.TZS, Bps: 150, Min: 100_00, Max: 10_000_00},
+			},
+		},
+		// Nonprofits and internal accounts
+		"FREE": {Name: "FREE"},
+	}
+}
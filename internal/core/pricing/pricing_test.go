@@ -0,0 +1,167 @@
+package pricing
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ibrahimkeyboad/gopay/internal/core/domain"
+)
+
+func TestRuleFee(t *testing.T) {
+	card := Rule{Method: MethodCard, Currency: domain.USD, Bps: 290, Fixed: 30}
+	mobile := Rule{Method: MethodMobileMoney, Currency: domain.TZS, Bps: 150, Min: 100_00, Max: 10_000_00}
+	tests := []struct {
+		name  string
+		rule  Rule
+		gross int64
+		want  int64
+	}{
+		{"percentage plus fixed", card, 100_00, 290 + 30},
+		{"rounds down below half", card, 100, 3 + 30},                                     // 2.9
+		{"percentage under half a unit", Rule{Bps: 290}, 17, 0},                           // 0.493
+		{"half rounds up", Rule{Bps: 250}, 20, 1},                                         // 0.5
+		{"just under half", Rule{Bps: 250}, 19, 0},                                        // 0.475
+		{"one and a half rounds up", Rule{Bps: 150}, 100, 2},                              // 1.5
+		{"fixed only", Rule{Fixed: 25}, 1_00, 25},                                         //
+		{"free", Rule{}, 1_000_00, 0},                                                     //
+		{"raised to min", mobile, 500_00, 100_00},                                         // 7.50
+		{"just under min", mobile, 666_633, 100_00},                                       // 99.99495
+		{"min boundary", mobile, 666_667, 100_00},                                         // 100.00005
+		{"between min and max", mobile, 1_000_000, 150_00},                                //
+		{"capped at max", mobile, 1_000_000_00, 10_000_00},                                //
+		{"max boundary", mobile, 66_666_667, 10_000_00},                                   // 1000000.005
+		{"never more than gross", card, 20, 20},                                           // 0.58 + 30
+		{"min never more than gross", Rule{Min: 500}, 100, 100},                           //
+		{"whole gross", Rule{Bps: domain.BasisPoints}, 12_345, 12_345},                    //
+		{"max and min equal", Rule{Bps: 100, Min: 50, Max: 50}, 1_000_000, 50},            //
+		{"fixed pushes over max", Rule{Bps: 100, Fixed: 100, Max: 150}, 10_000, 150},      // 100 + 100
+		{"fixed within max", Rule{Bps: 100, Fixed: 10, Max: 150}, 10_000, 110},            //
+		{"large gross", Rule{Bps: 290, Fixed: 30}, 5_000_000_000_00, 145_000_000_00 + 30}, //
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fee, err := tt.rule.Fee(domain.NewMoney(tt.gross, domain.USD), 0)
+			if err != nil {
+				t.Fatalf("Fee() error = %v", err)
+			}
+			if fee.Amount != tt.want || fee.Currency != domain.USD {
+				t.Errorf("Fee(%d) = %v, want %d USD", tt.gross, fee, tt.want)
+			}
+			if fee.Amount < 0 || fee.Amount > tt.gross {
+				t.Errorf("Fee(%d) = %d is outside [0, gross]", tt.gross, fee.Amount)
+			}
+		})
+	}
+}
+
+func TestRuleFeeTiers(t *testing.T) {
+	rule := Rule{Bps: 290, Tiers: []Tier{{FromVolume: 50_000_000_00, Bps: 250}, {FromVolume: 200_000_000_00, Bps: 200}}}
+	tests := []struct {
+		name   string
+		volume int64
+		want   int64
+	}{
+		{"no volume", 0, 290},
+		{"just under first tier", 50_000_000_00 - 1, 290},
+		{"first tier", 50_000_000_00, 250},
+		{"just under second tier", 200_000_000_00 - 1, 250},
+		{"second tier", 200_000_000_00, 200},
+		{"above every tier", 1_000_000_000_00, 200},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fee, err := rule.Fee(domain.NewMoney(10_000, domain.TZS), tt.volume)
+			if err != nil {
+				t.Fatalf("Fee() error = %v", err)
+			}
+			if fee.Amount != tt.want {
+				t.Errorf("Fee() at volume %d = %d, want %d", tt.volume, fee.Amount, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlanFee(t *testing.T) {
+	plan := DefaultPlans()[DefaultPlan]
+
+	fee, err := plan.Fee(MethodCard, domain.NewMoney(100_00, domain.USD), 0)
+	if err != nil || fee.Amount != 290+30 || fee.Currency != domain.USD {
+		t.Errorf("Fee() = %v, %v; want 320 USD", fee, err)
+	}
+	// No rule for the method and currency: the payment is free
+	fee, err = plan.Fee(MethodMobileMoney, domain.NewMoney(100_00, domain.USD), 0)
+	if err != nil || fee.Amount != 0 || fee.Currency != domain.USD {
+		t.Errorf("Fee() without a rule = %v, %v; want 0 USD", fee, err)
+	}
+	fee, err = DefaultPlans()["FREE"].Fee(MethodCard, domain.NewMoney(100_00, domain.TZS), 0)
+	if err != nil || fee.Amount != 0 {
+		t.Errorf("FREE plan Fee() = %v, %v; want 0", fee, err)
+	}
+}
+
+func TestPlansGet(t *testing.T) {
+	plans := DefaultPlans()
+	if p, err := plans.Get("standard"); err != nil || p.Name != DefaultPlan {
+		t.Errorf("Get(standard) = %q, %v; want %s", p.Name, err, DefaultPlan)
+	}
+	if _, err := plans.Get("GOLD"); !errors.Is(err, ErrUnknownPlan) {
+		t.Errorf("Get(GOLD) error = %v, want %v", err, ErrUnknownPlan)
+	}
+}
+
+func TestDefaultPlansValid(t *testing.T) {
+	for name, plan := range DefaultPlans() {
+		if err := plan.validate(); err != nil {
+			t.Errorf("default plan %s: %v", name, err)
+		}
+	}
+}
+
+func TestLoadPlans(t *testing.T) {
+	tests := []struct {
+		name    string
+		json    string
+		wantErr bool
+	}{
+		{"valid", `[{"name": "standard", "rules": [{"method": "CARD", "currency": "usd", "bps": 290, "fixed": 30,
+			"tiers": [{"from_volume": 500, "bps": 200}, {"from_volume": 100, "bps": 250}]}]}]`, false},
+		{"no standard plan", `[{"name": "GOLD", "rules": []}]`, true},
+		{"unsupported method", `[{"name": "STANDARD", "rules": [{"method": "CHEQUE", "currency": "USD", "bps": 100}]}]`, true},
+		{"unknown currency", `[{"name": "STANDARD", "rules": [{"method": "CARD", "currency": "XYZ", "bps": 100}]}]`, true},
+		{"bps above 100%", `[{"name": "STANDARD", "rules": [{"method": "CARD", "currency": "USD", "bps": 10001}]}]`, true},
+		{"negative fixed", `[{"name": "STANDARD", "rules": [{"method": "CARD", "currency": "USD", "fixed": -1}]}]`, true},
+		{"min above max", `[{"name": "STANDARD", "rules": [{"method": "CARD", "currency": "USD", "min": 200, "max": 100}]}]`, true},
+		{"bad tier", `[{"name": "STANDARD", "rules": [{"method": "CARD", "currency": "USD", "tiers": [{"from_volume": -1, "bps": 100}]}]}]`, true},
+		{"not json", `rules: []`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "pricing.json")
+			if err := os.WriteFile(path, []byte(tt.json), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			plans, err := LoadPlans(path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadPlans() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			// Names and currencies are normalized and tiers sorted by volume
+			rule, ok := plans[DefaultPlan].Rule(MethodCard, domain.USD)
+			if !ok {
+				t.Fatalf("LoadPlans() has no CARD/USD rule: %+v", plans)
+			}
+			if len(rule.Tiers) != 2 || rule.Tiers[0].FromVolume != 100 || rule.Tiers[1].FromVolume != 500 {
+				t.Errorf("tiers = %+v, want sorted by from_volume", rule.Tiers)
+			}
+		})
+	}
+
+	if _, err := LoadPlans(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("LoadPlans() of a missing file should fail")
+	}
+}
@@ -0,0 +1,69 @@
+// Package schedule works out when scheduled and recurring transfers run.
+package schedule
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Frequency is how often a scheduled transfer repeats
+type Frequency string
+
+const (
+	Once    Frequency = "ONCE"
+	Daily   Frequency = "DAILY"
+	Weekly  Frequency = "WEEKLY"
+	Monthly Frequency = "MONTHLY"
+)
+
+// ParseFrequency accepts ONCE, DAILY, WEEKLY and MONTHLY (any case). Empty means ONCE.
+func ParseFrequency(v string) (Frequency, error) {
+	switch f := Frequency(strings.ToUpper(v)); f {
+	case "":
+		return Once, nil
+	case Once, Daily, Weekly, Monthly:
+		return f, nil
+	}
+	return "", fmt.Errorf("unsupported frequency %q (use ONCE, DAILY, WEEKLY or MONTHLY)", v)
+}
+
+// Occurrence returns when run n (0 = the first) of a schedule starting at start is due.
+// Monthly runs keep the day of month of start, moved back to the last day of shorter
+// months (a schedule starting on the 31st runs on Feb 28/29).
+func Occurrence(start time.Time, f Frequency, n int) time.Time {
+	switch f {
+	case Daily:
+		return start.AddDate(0, 0, n)
+	case Weekly:
+		return start.AddDate(0, 0, 7*n)
+	case Monthly:
+		first := time.Date(start.Year(), start.Month()+time.Month(n), 1,
+			start.Hour(), start.Minute(), start.Second(), start.Nanosecond(), start.Location())
+		lastDay := first.AddDate(0, 1, -1).Day()
+		day := start.Day()
+		if day > lastDay {
+			day = lastDay
+		}
+		return first.AddDate(0, 0, day-1)
+	}
+	return start
+}
+
+// RetryPolicy says when a run that failed for lack of funds (or another passing reason,
+// such as a limit or a frozen account) is tried again.
+// Delays[i] is the wait before retry i+1; once they are used up the run is given up.
+type RetryPolicy struct {
+	Delays []time.Duration
+}
+
+// DefaultRetryPolicy retries after 1 hour, 6 hours and 24 hours
+var DefaultRetryPolicy = RetryPolicy{Delays: []time.Duration{time.Hour, 6 * time.Hour, 24 * time.Hour}}
+
+// Next returns the wait before the next attempt, given how many retries were already made
+func (p RetryPolicy) Next(retries int) (time.Duration, bool) {
+	if retries < 0 || retries >= len(p.Delays) {
+		return 0, false
+	}
+	return p.Delays[retries], true
+}
@@ -5,19 +5,51 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
+	"strings"
 )
 
+// Key prefixes. A gp_test_ key only ever reaches the account's test twin (sandbox data).
+const (
+	PrefixLive = "gp_live_"
+	PrefixTest = "gp_test_"
+
+	// Publishable keys can be embedded in checkouts: they only start payments
+	PrefixPublishableLive = "gp_pk_live_"
+	PrefixPublishableTest = "gp_pk_test_"
+
+	// PrefixAdmin marks back-office operator keys; they only work on /v1/admin
+	PrefixAdmin = "gp_admin_"
+)
+
+// KeyPrefix returns the mode prefix a key was issued with ("" if it has none)
+func KeyPrefix(key string) string {
+	for _, prefix := range []string{PrefixLive, PrefixTest, PrefixPublishableLive, PrefixPublishableTest} {
+		if strings.HasPrefix(key, prefix) {
+			return prefix
+		}
+	}
+	return ""
+}
+
+// Last4 returns the end of a key, shown when listing keys so they can be told apart
+func Last4(key string) string {
+	if len(key) <= 4 {
+		return key
+	}
+	return key[len(key)-4:]
+}
+
 // GenerateAPIKey creates a secure random API key and its SHA256 hash.
 //
 // Returns:
-//   - realKey: The actual API key to show the user (e.g., "sk_live_abc123...")
+//   - realKey: The actual API key to show the user (e.g., "gp_live_abc123...")
 //   - keyHash: SHA256 hash to store in the database
 //   - error: Any error during random byte generation
 //
 // Example:
-//   realKey, keyHash, err := GenerateAPIKey()
+//   realKey, keyHash, err := GenerateAPIKey(PrefixTest)
 
-func GenerateAPIKey() (string, string, error) {
+func GenerateAPIKey(prefix string) (string, string, error) {
 	// 1. Generate 32 random bytes using crypto/rand (cryptographically secure)
 	bytes := make([]byte, 32)
 	if _, err := rand.Read(bytes); err != nil {
@@ -27,9 +59,8 @@ func GenerateAPIKey() (string, string, error) {
 	// 2. Convert to hexadecimal string (64 characters)
 	randomString := hex.EncodeToString(bytes)
 
-	// 3. Add prefix (similar to Stripe's API key format)
-// GOOD (Use your own custom prefix, e.g., 'gp' for GoPay)
-realKey := fmt.Sprintf("gp_live_%s", randomString)
+	// 3. Add prefix (similar to Stripe's API key format): gp_live_ or gp_test_
+	realKey := prefix + randomString
 
 	// 4. Hash the key with SHA256 - this is what we store in the database
 	hash := sha256.Sum256([]byte(realKey))
@@ -49,7 +80,7 @@ realKey := fmt.Sprintf("gp_live_%s", randomString)
 //   - false if the key is invalid or has been tampered with
 //
 // Example:
-//   isValid := ValidateKey("sk_live_abc123...", "b94d27b9934d3e08...")
+//   isValid := ValidateKey("gp_live_abc123...", "b94d27b9934d3e08...")
 func ValidateKey(providedKey, storedHash string) bool {
 	// Hash the provided key
 	hash := sha256.Sum256([]byte(providedKey))
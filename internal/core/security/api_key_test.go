@@ -0,0 +1,49 @@
+package security
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestKeyPrefix(t *testing.T) {
+	tests := []struct {
+		key, want string
+	}{
+		{"gp_live_abc", PrefixLive},
+		{"gp_test_abc", PrefixTest},
+		{"gp_pk_live_abc", PrefixPublishableLive},
+		{"gp_pk_test_abc", PrefixPublishableTest},
+		{"gp_admin_abc", ""},
+		{"sk_live_abc", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := KeyPrefix(tt.key); got != tt.want {
+			t.Errorf("KeyPrefix(%q) = %q, want %q", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestGenerateAPIKey(t *testing.T) {
+	key, hash, err := GenerateAPIKey(PrefixTest)
+	if err != nil {
+		t.Fatalf("GenerateAPIKey() error = %v", err)
+	}
+	if !strings.HasPrefix(key, PrefixTest) || len(key) != len(PrefixTest)+64 {
+		t.Errorf("GenerateAPIKey() key = %q, want %s + 64 hex characters", key, PrefixTest)
+	}
+	if !ValidateKey(key, hash) {
+		t.Error("ValidateKey() rejected the key it was generated with")
+	}
+	if ValidateKey(key+"0", hash) || ValidateKey(strings.TrimPrefix(key, PrefixTest), hash) {
+		t.Error("ValidateKey() accepted a different key")
+	}
+
+	other, _, err := GenerateAPIKey(PrefixTest)
+	if err != nil {
+		t.Fatalf("GenerateAPIKey() error = %v", err)
+	}
+	if other == key {
+		t.Error("GenerateAPIKey() returned the same key twice")
+	}
+}
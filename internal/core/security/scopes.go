@@ -0,0 +1,157 @@
+package security
+
+import (
+	"fmt"
+	"net/netip"
+	"slices"
+	"strings"
+)
+
+// Scope is a permission a restricted API key carries. A key without scopes is unrestricted.
+type Scope string
+
+const (
+	ScopeAccountsRead     Scope = "accounts:read"     // Balances, account details, sub-accounts
+	ScopeAccountsWrite    Scope = "accounts:write"    // Open sub-accounts
+	ScopeTransactionsRead Scope = "transactions:read" // History, statements, transactions, holds, schedules
+	ScopeTransfersWrite   Scope = "transfers:write"   // Transfers, scheduled transfers, FX
+	ScopeChargesWrite     Scope = "charges:write"     // Mobile money collections and holds
+	ScopeRefundsWrite     Scope = "refunds:write"
+	ScopeKeysManage       Scope = "keys:manage" // List, revoke, expire and roll keys
+)
+
+// Scopes lists every scope a key can be given
+var Scopes = []Scope{
+	ScopeAccountsRead, ScopeAccountsWrite, ScopeTransactionsRead, ScopeTransfersWrite,
+	ScopeChargesWrite, ScopeRefundsWrite, ScopeKeysManage,
+}
+
+// ParseScopes validates the scopes requested for a restricted key
+func ParseScopes(names []string) ([]string, error) {
+	if len(names) == 0 {
+		return nil, fmt.Errorf("a restricted key needs at least one scope")
+	}
+	scopes := make([]string, 0, len(names))
+	seen := map[Scope]bool{}
+	for _, name := range names {
+		s := Scope(strings.ToLower(strings.TrimSpace(name)))
+		if !slices.Contains(Scopes, s) {
+			return nil, fmt.Errorf("unknown scope %q", name)
+		}
+		if !seen[s] {
+			seen[s] = true
+			scopes = append(scopes, string(s))
+		}
+	}
+	return scopes, nil
+}
+
+// HasScope reports whether a key's scopes allow scope. Nil scopes mean an unrestricted key.
+func HasScope(scopes []string, scope Scope) bool {
+	return scopes == nil || slices.Contains(scopes, string(scope))
+}
+
+// ScopesCover reports whether a key with caller scopes holds every power of a key with
+// target scopes. An unrestricted caller covers anything; an unrestricted target is only
+// covered by an unrestricted caller.
+func ScopesCover(caller, target []string) bool {
+	if caller == nil {
+		return true
+	}
+	if target == nil {
+		return false
+	}
+	for _, s := range target {
+		if !HasScope(caller, Scope(s)) {
+			return false
+		}
+	}
+	return true
+}
+
+// ParseAllowlist validates an IP allowlist: single addresses ("41.59.1.10") or CIDR ranges ("41.59.0.0/16")
+func ParseAllowlist(entries []string) ([]string, error) {
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("an IP allowlist needs at least one address or range")
+	}
+	list := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if prefix, err := netip.ParsePrefix(entry); err == nil {
+			list = append(list, prefix.Masked().String())
+			continue
+		}
+		addr, err := netip.ParseAddr(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid IP address or range %q", entry)
+		}
+		list = append(list, addr.String())
+	}
+	return list, nil
+}
+
+// IPAllowed reports whether ip is on the allowlist. A nil allowlist allows every address.
+func IPAllowed(allowlist []string, ip string) bool {
+	if allowlist == nil {
+		return true
+	}
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, entry := range allowlist {
+		if prefix, err := netip.ParsePrefix(entry); err == nil {
+			if prefix.Contains(addr) {
+				return true
+			}
+			continue
+		}
+		if allowed, err := netip.ParseAddr(entry); err == nil && allowed.Unmap() == addr {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowlistCovers reports whether every address target allows is also allowed by caller.
+// A nil caller allowlist covers anything; a nil target (any address) is only covered by nil.
+func AllowlistCovers(caller, target []string) bool {
+	if caller == nil {
+		return true
+	}
+	if target == nil {
+		return false
+	}
+	for _, entry := range target {
+		want, ok := allowlistPrefix(entry)
+		if !ok {
+			return false
+		}
+		covered := false
+		for _, c := range caller {
+			have, ok := allowlistPrefix(c)
+			if ok && have.Bits() <= want.Bits() && have.Contains(want.Addr()) {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			return false
+		}
+	}
+	return true
+}
+
+// allowlistPrefix reads an allowlist entry as a range; a single address is a range of one
+func allowlistPrefix(entry string) (netip.Prefix, bool) {
+	if prefix, err := netip.ParsePrefix(entry); err == nil {
+		return prefix.Masked(), true
+	}
+	addr, err := netip.ParseAddr(entry)
+	if err != nil {
+		return netip.Prefix{}, false
+	}
+	addr = addr.Unmap()
+	return netip.PrefixFrom(addr, addr.BitLen()), true
+}
@@ -0,0 +1,187 @@
+package security
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseScopes(t *testing.T) {
+	tests := []struct {
+		name    string
+		names   []string
+		want    []string
+		wantErr bool
+	}{
+		{"single", []string{"charges:write"}, []string{"charges:write"}, false},
+		{"normalized", []string{" Accounts:READ "}, []string{"accounts:read"}, false},
+		{"duplicates dropped", []string{"refunds:write", "refunds:write", "keys:manage"}, []string{"refunds:write", "keys:manage"}, false},
+		{"unknown", []string{"charges:write", "admin"}, nil, true},
+		{"nil", nil, nil, true},
+		{"empty", []string{}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseScopes(tt.names)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseScopes(%q) error = %v, wantErr %v", tt.names, err, tt.wantErr)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("ParseScopes(%q) = %q, want %q", tt.names, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHasScope(t *testing.T) {
+	tests := []struct {
+		name   string
+		scopes []string
+		scope  Scope
+		want   bool
+	}{
+		{"unrestricted", nil, ScopeKeysManage, true},
+		{"no scopes", []string{}, ScopeAccountsRead, false},
+		{"granted", []string{"accounts:read", "charges:write"}, ScopeChargesWrite, true},
+		{"not granted", []string{"accounts:read"}, ScopeAccountsWrite, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := HasScope(tt.scopes, tt.scope); got != tt.want {
+				t.Errorf("HasScope(%q, %s) = %v, want %v", tt.scopes, tt.scope, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScopesCover(t *testing.T) {
+	tests := []struct {
+		name           string
+		caller, target []string
+		want           bool
+	}{
+		{"unrestricted covers unrestricted", nil, nil, true},
+		{"unrestricted covers restricted", nil, []string{"refunds:write"}, true},
+		// A restricted key must not roll, revoke or re-expire an unrestricted one
+		{"restricted does not cover unrestricted", []string{"keys:manage"}, nil, false},
+		{"restricted with every scope does not cover unrestricted", scopeNames(Scopes), nil, false},
+		{"subset", []string{"keys:manage", "accounts:read"}, []string{"accounts:read"}, true},
+		{"same", []string{"keys:manage"}, []string{"keys:manage"}, true},
+		{"wider target", []string{"keys:manage"}, []string{"keys:manage", "transfers:write"}, false},
+		{"disjoint", []string{"keys:manage"}, []string{"charges:write"}, false},
+		{"no scopes covers no scopes", []string{}, []string{}, true},
+		{"no scopes does not cover a scope", []string{}, []string{"accounts:read"}, false},
+		{"no scopes does not cover unrestricted", []string{}, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ScopesCover(tt.caller, tt.target); got != tt.want {
+				t.Errorf("ScopesCover(%q, %q) = %v, want %v", tt.caller, tt.target, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseAllowlist(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []string
+		want    []string
+		wantErr bool
+	}{
+		{"single address", []string{"41.59.1.10"}, []string{"41.59.1.10"}, false},
+		{"range", []string{"41.59.0.0/16"}, []string{"41.59.0.0/16"}, false},
+		{"range is masked", []string{"41.59.1.10/16"}, []string{"41.59.0.0/16"}, false},
+		{"whitespace", []string{" 10.0.0.1 ", "\t10.1.0.0/24"}, []string{"10.0.0.1", "10.1.0.0/24"}, false},
+		{"ipv6", []string{"2001:db8::1", "2001:db8:1::/48"}, []string{"2001:db8::1", "2001:db8:1::/48"}, false},
+		{"hostname", []string{"example.com"}, nil, true},
+		{"bad prefix length", []string{"10.0.0.0/33"}, nil, true},
+		{"one bad entry", []string{"10.0.0.1", "10.0.0"}, nil, true},
+		{"nil", nil, nil, true},
+		{"empty", []string{}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseAllowlist(tt.entries)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseAllowlist(%q) error = %v, wantErr %v", tt.entries, err, tt.wantErr)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("ParseAllowlist(%q) = %q, want %q", tt.entries, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIPAllowed(t *testing.T) {
+	tests := []struct {
+		name      string
+		allowlist []string
+		ip        string
+		want      bool
+	}{
+		{"no allowlist", nil, "203.0.113.7", true},
+		{"empty allowlist", []string{}, "203.0.113.7", false},
+		{"single match", []string{"41.59.1.10"}, "41.59.1.10", true},
+		{"single miss", []string{"41.59.1.10"}, "41.59.1.11", false},
+		{"in range", []string{"41.59.0.0/16"}, "41.59.200.3", true},
+		{"range edge", []string{"41.59.0.0/16"}, "41.60.0.0", false},
+		{"second entry", []string{"10.0.0.1", "41.59.0.0/16"}, "41.59.0.1", true},
+		{"ipv4-mapped caller", []string{"41.59.1.10"}, "::ffff:41.59.1.10", true},
+		{"ipv4-mapped caller in range", []string{"41.59.0.0/16"}, "::ffff:41.59.1.10", true},
+		{"ipv6 range", []string{"2001:db8::/32"}, "2001:db8::42", true},
+		{"ipv6 does not match ipv4", []string{"41.59.0.0/16"}, "2001:db8::42", false},
+		{"unparsable ip", []string{"41.59.0.0/16"}, "not-an-ip", false},
+		{"empty ip", []string{"41.59.0.0/16"}, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IPAllowed(tt.allowlist, tt.ip); got != tt.want {
+				t.Errorf("IPAllowed(%q, %q) = %v, want %v", tt.allowlist, tt.ip, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAllowlistCovers(t *testing.T) {
+	tests := []struct {
+		name           string
+		caller, target []string
+		want           bool
+	}{
+		{"any covers any", nil, nil, true},
+		{"any covers a list", nil, []string{"41.59.1.10"}, true},
+		// A key bound to an allowlist must not issue or manage a key usable from anywhere
+		{"list does not cover any", []string{"0.0.0.0/0"}, nil, false},
+		{"same address", []string{"41.59.1.10"}, []string{"41.59.1.10"}, true},
+		{"address in range", []string{"41.59.0.0/16"}, []string{"41.59.1.10"}, true},
+		{"narrower range", []string{"41.59.0.0/16"}, []string{"41.59.1.0/24"}, true},
+		{"same range", []string{"41.59.0.0/16"}, []string{"41.59.0.0/16"}, true},
+		{"wider range", []string{"41.59.1.0/24"}, []string{"41.59.0.0/16"}, false},
+		{"range from an address", []string{"41.59.1.10"}, []string{"41.59.1.10/32"}, true},
+		{"range wider than an address", []string{"41.59.1.10"}, []string{"41.59.1.0/24"}, false},
+		{"unmasked target range", []string{"41.59.1.0/24"}, []string{"41.59.1.77/24"}, true},
+		{"address outside", []string{"41.59.0.0/16"}, []string{"10.0.0.1"}, false},
+		{"one entry outside", []string{"41.59.0.0/16"}, []string{"41.59.1.10", "10.0.0.1"}, false},
+		{"entries under different caller ranges", []string{"41.59.0.0/16", "10.0.0.0/8"}, []string{"41.59.1.10", "10.2.0.0/16"}, true},
+		{"ipv4-mapped target", []string{"41.59.0.0/16"}, []string{"::ffff:41.59.1.10"}, true},
+		{"ipv6 not under ipv4", []string{"0.0.0.0/0"}, []string{"2001:db8::1"}, false},
+		{"ipv6 range", []string{"2001:db8::/32"}, []string{"2001:db8:1::/48"}, true},
+		{"invalid target entry", []string{"0.0.0.0/0"}, []string{"example.com"}, false},
+		{"empty target", []string{"41.59.1.10"}, []string{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := AllowlistCovers(tt.caller, tt.target); got != tt.want {
+				t.Errorf("AllowlistCovers(%q, %q) = %v, want %v", tt.caller, tt.target, got, tt.want)
+			}
+		})
+	}
+}
+
+func scopeNames(scopes []Scope) []string {
+	names := make([]string, len(scopes))
+	for i, s := range scopes {
+		names[i] = string(s)
+	}
+	return names
+}
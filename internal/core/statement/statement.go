@@ -0,0 +1,177 @@
+// Package statement renders account statements for customers and the finance team.
+package statement
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/ibrahimkeyboad/gopay/internal/core/domain"
+)
+
+// Format is an export format for a statement
+type Format string
+
+const (
+	FormatJSON Format = "json"
+	FormatCSV  Format = "csv"
+	FormatText Format = "txt"
+)
+
+// ParseFormat accepts json, csv and txt (or text). Empty means JSON.
+func ParseFormat(v string) (Format, error) {
+	switch v {
+	case "", "json":
+		return FormatJSON, nil
+	case "csv":
+		return FormatCSV, nil
+	case "txt", "text":
+		return FormatText, nil
+	}
+	return "", fmt.Errorf("unsupported statement format %q (use json, csv or txt)", v)
+}
+
+// Line is one entry on the account, with the balance right after it.
+// Fee and Tax are what was deducted from a payment before it was credited (Amount is net).
+type Line struct {
+	TransactionID uuid.UUID `json:"transaction_id"`
+	CreatedAt     time.Time `json:"created_at"`
+	Description   string    `json:"description"`
+	Direction     string    `json:"direction"`
+	Amount        int64     `json:"amount"`
+	Fee           int64     `json:"fee"`
+	Tax           int64     `json:"tax"`
+	Balance       int64     `json:"balance"`
+}
+
+// Statement covers [From, To): the opening balance is everything booked before From,
+// the closing balance everything booked before To.
+type Statement struct {
+	AccountID      uuid.UUID `json:"account_id"`
+	OwnerName      string    `json:"owner_name"`
+	Currency       string    `json:"currency"`
+	From           time.Time `json:"from"`
+	To             time.Time `json:"to"`
+	OpeningBalance int64     `json:"opening_balance"`
+	TotalCredits   int64     `json:"total_credits"`
+	TotalDebits    int64     `json:"total_debits"`
+	TotalFees      int64     `json:"total_fees"`
+	TotalTax       int64     `json:"total_tax"`
+	ClosingBalance int64     `json:"closing_balance"`
+	Lines          []Line    `json:"lines"`
+}
+
+func (s *Statement) money(amount int64) domain.Money {
+	return domain.NewMoney(amount, domain.Currency(s.Currency))
+}
+
+// WriteCSV writes one row per line, framed by OPENING and CLOSING rows.
+// Amounts are decimals in major units (e.g. 1250.50) so spreadsheets can sum them.
+func WriteCSV(w io.Writer, s *Statement) error {
+	cw := csv.NewWriter(w)
+	rows := [][]string{
+		{"date", "transaction_id", "description", "direction", "amount", "fee", "tax", "balance", "currency"},
+		{s.From.UTC().Format(time.RFC3339), "", "OPENING BALANCE", "", "", "", "", s.money(s.OpeningBalance).Decimal(), s.Currency},
+	}
+	for _, l := range s.Lines {
+		rows = append(rows, []string{
+			l.CreatedAt.UTC().Format(time.RFC3339),
+			l.TransactionID.String(),
+			l.Description,
+			l.Direction,
+			s.money(l.Amount).Decimal(),
+			s.money(l.Fee).Decimal(),
+			s.money(l.Tax).Decimal(),
+			s.money(l.Balance).Decimal(),
+			s.Currency,
+		})
+	}
+	rows = append(rows, []string{s.To.UTC().Format(time.RFC3339), "", "CLOSING BALANCE", "", "", "", "", s.money(s.ClosingBalance).Decimal(), s.Currency})
+
+	if err := cw.WriteAll(rows); err != nil {
+		return err
+	}
+	return cw.Error()
+}
+
+// WriteText writes a plain-text statement meant to be read or printed as is
+func WriteText(w io.Writer, s *Statement) error {
+	ew := &errWriter{w: w}
+	ew.printf("GoPay Account Statement\n")
+	ew.printf("Account:  %s (%s)\n", s.OwnerName, s.AccountID)
+	ew.printf("Currency: %s\n", s.Currency)
+	ew.printf("Period:   %s to %s\n\n", s.From.UTC().Format(time.RFC3339), s.To.UTC().Format(time.RFC3339))
+
+	tw := tabwriter.NewWriter(ew, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(tw, "Date\tDescription\tDebit\tCredit\tBalance\t\n")
+	fmt.Fprintf(tw, "%s\t%s\t\t\t%s\t\n", s.From.UTC().Format("2006-01-02"), "Opening balance", s.money(s.OpeningBalance))
+	for _, l := range s.Lines {
+		debit, credit := "", ""
+		if l.Direction == "DEBIT" {
+			debit = s.money(l.Amount).String()
+		} else {
+			credit = s.money(l.Amount).String()
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t\n", l.CreatedAt.UTC().Format("2006-01-02 15:04"), l.Description, debit, credit, s.money(l.Balance))
+	}
+	fmt.Fprintf(tw, "%s\t%s\t\t\t%s\t\n", s.To.UTC().Format("2006-01-02"), "Closing balance", s.money(s.ClosingBalance))
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	ew.printf("\nEntries:       %d\n", len(s.Lines))
+	ew.printf("Total credits: %s\n", s.money(s.TotalCredits))
+	ew.printf("Total debits:  %s\n", s.money(s.TotalDebits))
+	ew.printf("Fees paid:     %s\n", s.money(s.TotalFees))
+	ew.printf("Tax paid:      %s\n", s.money(s.TotalTax))
+	return ew.err
+}
+
+// errWriter remembers the first write error so the text layout isn't buried in checks
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (e *errWriter) Write(p []byte) (int, error) {
+	if e.err != nil {
+		return 0, e.err
+	}
+	n, err := e.w.Write(p)
+	e.err = err
+	return n, err
+}
+
+func (e *errWriter) printf(format string, args ...any) {
+	fmt.Fprintf(e, format, args...)
+}
+
+// MaxRange keeps a single statement to about a year of entries
+const MaxRange = 366 * 24 * time.Hour
+
+// ParseTime accepts a date (2024-01-31, midnight UTC) or an RFC 3339 timestamp
+func ParseTime(v string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", v); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q, use 2024-01-31 or RFC 3339", v)
+	}
+	return t, nil
+}
+
+// ValidateRange checks a [from, to) statement period
+func ValidateRange(from, to time.Time) error {
+	if !from.Before(to) {
+		return fmt.Errorf("from must be before to")
+	}
+	if to.Sub(from) > MaxRange {
+		return fmt.Errorf("statement period cannot be longer than %d days", int(MaxRange.Hours()/24))
+	}
+	return nil
+}
@@ -0,0 +1,211 @@
+// Package tax works out the taxes due on the fees we charge merchants.
+package tax
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ibrahimkeyboad/gopay/internal/core/domain"
+)
+
+var ErrUnknownKind = errors.New("unknown tax kind")
+
+// Kind is a type of tax
+type Kind string
+
+const (
+	// VAT is charged on top of our fee: the merchant pays fee + VAT and we owe the VAT
+	// to the tax authority.
+	VAT Kind = "VAT"
+	// WithholdingTax is withheld from our fee on the merchant's behalf: we keep fee - WHT
+	// and owe the WHT to the tax authority. Only for merchants that require it.
+	WithholdingTax Kind = "WHT"
+)
+
+// Kinds lists every tax kind, in the order lines are computed
+var Kinds = []Kind{VAT, WithholdingTax}
+
+// Rate is a tax rate in one jurisdiction from EffectiveFrom until the next rate of the
+// same kind takes over
+type Rate struct {
+	Jurisdiction  string    `json:"jurisdiction"` // ISO 3166 country code, e.g. TZ
+	Kind          Kind      `json:"kind"`
+	Bps           int64     `json:"bps"`
+	EffectiveFrom time.Time `json:"effective_from"`
+}
+
+// Table holds every known rate, oldest first
+type Table struct {
+	rates []Rate
+}
+
+// NewTable builds a table from rates in any order
+func NewTable(rates []Rate) (*Table, error) {
+	sorted := make([]Rate, 0, len(rates))
+	for _, r := range rates {
+		r.Jurisdiction = strings.ToUpper(r.Jurisdiction)
+		r.Kind = Kind(strings.ToUpper(string(r.Kind)))
+		if r.Kind != VAT && r.Kind != WithholdingTax {
+			return nil, fmt.Errorf("%w %q", ErrUnknownKind, r.Kind)
+		}
+		if r.Jurisdiction == "" || r.Bps < 0 || r.Bps > domain.BasisPoints || r.EffectiveFrom.IsZero() {
+			return nil, fmt.Errorf("invalid %s rate for %q", r.Kind, r.Jurisdiction)
+		}
+		sorted = append(sorted, r)
+	}
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].EffectiveFrom.Before(sorted[j].EffectiveFrom) })
+	return &Table{rates: sorted}, nil
+}
+
+// Rate returns the rate in force at a moment, if there is one
+func (t *Table) Rate(jurisdiction string, kind Kind, at time.Time) (Rate, bool) {
+	var found Rate
+	ok := false
+	for _, r := range t.rates {
+		if r.Jurisdiction == jurisdiction && r.Kind == kind && !r.EffectiveFrom.After(at) {
+			found, ok = r, true
+		}
+	}
+	return found, ok
+}
+
+// Line is one tax on one fee. Base is the fee it was computed on.
+type Line struct {
+	Kind         Kind            `json:"kind"`
+	Jurisdiction string          `json:"jurisdiction"`
+	Bps          int64           `json:"bps"`
+	Base         int64           `json:"base"`
+	Amount       int64           `json:"amount"`
+	Currency     domain.Currency `json:"currency"`
+}
+
+// Lines computes the taxes on a fee charged at a moment. VAT always applies where the
+// jurisdiction has a rate; withholding tax only when withholding is set. Amounts round half-up.
+func (t *Table) Lines(jurisdiction string, fee domain.Money, withholding bool, at time.Time) ([]Line, error) {
+	lines := []Line{}
+	if fee.Amount <= 0 {
+		return lines, nil
+	}
+	for _, kind := range Kinds {
+		if kind == WithholdingTax && !withholding {
+			continue
+		}
+		rate, ok := t.Rate(jurisdiction, kind, at)
+		if !ok || rate.Bps == 0 {
+			continue
+		}
+		amount, err := fee.MultiplyByRate(big.NewRat(rate.Bps, domain.BasisPoints), domain.RoundHalfUp)
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, Line{
+			Kind:         kind,
+			Jurisdiction: jurisdiction,
+			Bps:          rate.Bps,
+			Base:         fee.Amount,
+			Amount:       amount.Amount,
+			Currency:     fee.Currency,
+		})
+	}
+	return lines, nil
+}
+
+// Split totals lines into what is added on top of the fee (VAT) and what is withheld from it
+func Split(lines []Line) (onTop, withheld int64) {
+	for _, l := range lines {
+		if l.Kind == WithholdingTax {
+			withheld += l.Amount
+		} else {
+			onTop += l.Amount
+		}
+	}
+	return onTop, withheld
+}
+
+// LoadTable reads rates from a JSON file: a list of
+// {"jurisdiction": "TZ", "kind": "VAT", "bps": 1800, "effective_from": "2015-07-01T00:00:00+03:00"}
+func LoadTable(path string) (*Table, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tax rates file: %w", err)
+	}
+	var rates []Rate
+	if err := json.Unmarshal(raw, &rates); err != nil {
+		return nil, fmt.Errorf("failed to parse tax rates file: %w", err)
+	}
+	return NewTable(rates)
+}
+
+// DefaultTable is used when no tax rates file is configured
+func DefaultTable() *Table {
+	t, _ := NewTable([]Rate{
+		{Jurisdiction: "TZ", Kind: VAT, Bps: 1800, EffectiveFrom: time.Date(2015, 7, 1, 0, 0, 0, 0, reportZone)},
+		{Jurisdiction: "TZ", Kind: WithholdingTax, Bps: 500, EffectiveFrom: time.Date(2004, 7, 1, 0, 0, 0, 0, reportZone)},
+		{Jurisdiction: "KE", Kind: VAT, Bps: 1600, EffectiveFrom: time.Date(2013, 9, 2, 0, 0, 0, 0, reportZone)},
+		{Jurisdiction: "KE", Kind: WithholdingTax, Bps: 500, EffectiveFrom: time.Date(2013, 9, 2, 0, 0, 0, 0, reportZone)},
+		{Jurisdiction: "UG", Kind: VAT, Bps: 1800, EffectiveFrom: time.Date(2005, 7, 1, 0, 0, 0, 0, reportZone)},
+		{Jurisdiction: "UG", Kind: WithholdingTax, Bps: 600, EffectiveFrom: time.Date(2005, 7, 1, 0, 0, 0, 0, reportZone)},
+	})
+	return t
+}
+
+// reportZone is where a tax month starts: East Africa Time (UTC+3, no DST)
+var reportZone = time.FixedZone("EAT", 3*60*60)
+
+// ReportPeriod returns [from, to) for a tax month written as 2024-01
+func ReportPeriod(month string) (from, to time.Time, err error) {
+	m, err := time.Parse("2006-01", month)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid month %q, use 2024-01", month)
+	}
+	from = time.Date(m.Year(), m.Month(), 1, 0, 0, 0, 0, reportZone)
+	return from, from.AddDate(0, 1, 0), nil
+}
+
+// ReportLine totals one tax in one jurisdiction and currency. Refunds count negatively.
+type ReportLine struct {
+	Jurisdiction string          `json:"jurisdiction"`
+	Kind         Kind            `json:"kind"`
+	Currency     domain.Currency `json:"currency"`
+	Transactions int64           `json:"transactions"`
+	Base         int64           `json:"base"`
+	Amount       int64           `json:"amount"`
+}
+
+// Report is what we owe for a month, for the finance team to file
+type Report struct {
+	Month string       `json:"month"`
+	From  time.Time    `json:"from"`
+	To    time.Time    `json:"to"`
+	Lines []ReportLine `json:"lines"`
+}
+
+// WriteCSV writes one row per report line, amounts in major units
+func WriteCSV(w io.Writer, r *Report) error {
+	cw := csv.NewWriter(w)
+	rows := [][]string{{"month", "jurisdiction", "kind", "currency", "transactions", "base", "amount"}}
+	for _, l := range r.Lines {
+		rows = append(rows, []string{
+			r.Month,
+			l.Jurisdiction,
+			string(l.Kind),
+			string(l.Currency),
+			strconv.FormatInt(l.Transactions, 10),
+			domain.NewMoney(l.Base, l.Currency).Decimal(),
+			domain.NewMoney(l.Amount, l.Currency).Decimal(),
+		})
+	}
+	if err := cw.WriteAll(rows); err != nil {
+		return err
+	}
+	return cw.Error()
+}
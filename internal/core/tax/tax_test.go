@@ -0,0 +1,232 @@
+package tax
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ibrahimkeyboad/gopay/internal/core/domain"
+	"github.com/ibrahimkeyboad/gopay/internal/core/pricing"
+)
+
+var (
+	vatChange = time.Date(2024, 7, 1, 0, 0, 0, 0, reportZone)
+	testTable = mustTable([]Rate{
+		{Jurisdiction: "TZ", Kind: VAT, Bps: 1800, EffectiveFrom: vatChange},
+		{Jurisdiction: "TZ", Kind: VAT, Bps: 1500, EffectiveFrom: vatChange.AddDate(-5, 0, 0)},
+		{Jurisdiction: "TZ", Kind: WithholdingTax, Bps: 500, EffectiveFrom: vatChange.AddDate(-5, 0, 0)},
+		{Jurisdiction: "ZZ", Kind: VAT, Bps: 0, EffectiveFrom: vatChange.AddDate(-5, 0, 0)},
+	})
+)
+
+func mustTable(rates []Rate) *Table {
+	t, err := NewTable(rates)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestNewTable(t *testing.T) {
+	from := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		rate     Rate
+		wantErr  bool
+		wantKind bool // the error is ErrUnknownKind
+	}{
+		{"valid", Rate{Jurisdiction: "tz", Kind: "vat", Bps: 1800, EffectiveFrom: from}, false, false},
+		{"zero rate", Rate{Jurisdiction: "TZ", Kind: VAT, Bps: 0, EffectiveFrom: from}, false, false},
+		{"whole fee", Rate{Jurisdiction: "TZ", Kind: "wht", Bps: domain.BasisPoints, EffectiveFrom: from}, false, false},
+		{"unknown kind", Rate{Jurisdiction: "TZ", Kind: "GST", Bps: 1000, EffectiveFrom: from}, true, true},
+		{"no jurisdiction", Rate{Kind: VAT, Bps: 1800, EffectiveFrom: from}, true, false},
+		{"negative", Rate{Jurisdiction: "TZ", Kind: VAT, Bps: -1, EffectiveFrom: from}, true, false},
+		{"above 100%", Rate{Jurisdiction: "TZ", Kind: VAT, Bps: domain.BasisPoints + 1, EffectiveFrom: from}, true, false},
+		{"no effective date", Rate{Jurisdiction: "TZ", Kind: VAT, Bps: 1800}, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			table, err := NewTable([]Rate{tt.rate})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewTable() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if errors.Is(err, ErrUnknownKind) != tt.wantKind {
+				t.Fatalf("NewTable() error = %v, want ErrUnknownKind %v", err, tt.wantKind)
+			}
+			if err != nil {
+				return
+			}
+
+			// Jurisdiction and kind are normalized
+			kind := Kind(strings.ToUpper(string(tt.rate.Kind)))
+			if rate, ok := table.Rate("TZ", kind, from); !ok || rate.Bps != tt.rate.Bps {
+				t.Errorf("Rate(TZ, %s) = %+v, %v; want %d bps", kind, rate, ok, tt.rate.Bps)
+			}
+		})
+	}
+}
+
+func TestTableRate(t *testing.T) {
+	tests := []struct {
+		name         string
+		jurisdiction string
+		kind         Kind
+		at           time.Time
+		wantBps      int64
+		wantOK       bool
+	}{
+		{"before any rate", "TZ", VAT, vatChange.AddDate(-6, 0, 0), 0, false},
+		{"old rate", "TZ", VAT, vatChange.AddDate(0, -1, 0), 1500, true},
+		{"moment before the change", "TZ", VAT, vatChange.Add(-time.Nanosecond), 1500, true},
+		{"moment of the change", "TZ", VAT, vatChange, 1800, true},
+		{"same moment in UTC", "TZ", VAT, vatChange.UTC(), 1800, true},
+		{"after the change", "TZ", VAT, vatChange.AddDate(1, 0, 0), 1800, true},
+		{"other kind", "TZ", WithholdingTax, vatChange, 500, true},
+		{"other jurisdiction", "KE", VAT, vatChange, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rate, ok := testTable.Rate(tt.jurisdiction, tt.kind, tt.at)
+			if ok != tt.wantOK || rate.Bps != tt.wantBps {
+				t.Errorf("Rate(%s, %s, %v) = %d, %v; want %d, %v", tt.jurisdiction, tt.kind, tt.at, rate.Bps, ok, tt.wantBps, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestTableLines(t *testing.T) {
+	tests := []struct {
+		name         string
+		jurisdiction string
+		fee          int64
+		withholding  bool
+		wantVAT      int64
+		wantWHT      int64
+		wantLines    int
+	}{
+		{"vat only", "TZ", 320_00, false, 57_60, 0, 1},
+		{"vat and withholding", "TZ", 320_00, true, 57_60, 16_00, 2},
+		{"vat half rounds up", "TZ", 25, false, 5, 0, 1},        // 4.5
+		{"vat just under half", "TZ", 36, false, 6, 0, 1},       // 6.48
+		{"vat just over half", "TZ", 3, false, 1, 0, 1},         // 0.54
+		{"vat rounds to zero", "TZ", 2, false, 0, 0, 1},         // 0.36
+		{"withholding half rounds up", "TZ", 10, true, 2, 1, 2}, // 1.8, 0.5
+		{"withholding just under half", "TZ", 9, true, 2, 0, 2}, // 1.62, 0.45
+		{"zero fee", "TZ", 0, true, 0, 0, 0},                    //
+		{"negative fee", "TZ", -100, true, 0, 0, 0},             //
+		{"zero rate is skipped", "ZZ", 320_00, false, 0, 0, 0},  //
+		{"no rates", "KE", 320_00, true, 0, 0, 0},               //
+		{"large fee", "TZ", 1_000_000_000_00, true, 180_000_000_00, 50_000_000_00, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fee := domain.NewMoney(tt.fee, domain.TZS)
+			lines, err := testTable.Lines(tt.jurisdiction, fee, tt.withholding, vatChange)
+			if err != nil {
+				t.Fatalf("Lines() error = %v", err)
+			}
+			if lines == nil || len(lines) != tt.wantLines {
+				t.Fatalf("Lines() = %+v, want %d lines", lines, tt.wantLines)
+			}
+			for _, l := range lines {
+				if l.Base != tt.fee || l.Currency != domain.TZS || l.Jurisdiction != tt.jurisdiction {
+					t.Errorf("line %+v: want base %d TZS in %s", l, tt.fee, tt.jurisdiction)
+				}
+			}
+			vat, wht := Split(lines)
+			if vat != tt.wantVAT || wht != tt.wantWHT {
+				t.Errorf("Split(Lines(%d)) = %d, %d; want %d, %d", tt.fee, vat, wht, tt.wantVAT, tt.wantWHT)
+			}
+			if wht > max(tt.fee, 0) {
+				t.Errorf("withheld %d more than the fee %d", wht, tt.fee)
+			}
+		})
+	}
+}
+
+// The merchant pays the fee and its VAT out of the payment, so under the default plans
+// and rates that must never come to more than the payment itself (see depositSplit)
+func TestDefaultFeeAndVATWithinGross(t *testing.T) {
+	table := DefaultTable()
+	now := time.Now()
+	for _, plan := range pricing.DefaultPlans() {
+		for _, rule := range plan.Rules {
+			info, err := rule.Currency.Info()
+			if err != nil {
+				t.Fatalf("plan %s: %v", plan.Name, err)
+			}
+			amounts := []int64{info.MinAmount, info.MinAmount + 1, info.MinAmount * 2, info.MinAmount * 10, info.MaxAmount - 1, info.MaxAmount}
+			for _, gross := range amounts {
+				for _, volume := range []int64{0, 1_000_000_000_00} {
+					fee, err := rule.Fee(domain.NewMoney(gross, rule.Currency), volume)
+					if err != nil {
+						t.Fatalf("Fee() error = %v", err)
+					}
+					for _, jurisdiction := range []string{"TZ", "KE", "UG"} {
+						lines, err := table.Lines(jurisdiction, fee, true, now)
+						if err != nil {
+							t.Fatalf("Lines() error = %v", err)
+						}
+						vat, wht := Split(lines)
+						if fee.Amount+vat > gross || wht > fee.Amount {
+							t.Errorf("%s %s %s in %s: gross %d, fee %d, VAT %d, WHT %d",
+								plan.Name, rule.Method, rule.Currency, jurisdiction, gross, fee.Amount, vat, wht)
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestReportPeriod(t *testing.T) {
+	tests := []struct {
+		month    string
+		from, to time.Time
+		wantErr  bool
+	}{
+		{"2024-01", time.Date(2024, 1, 1, 0, 0, 0, 0, reportZone), time.Date(2024, 2, 1, 0, 0, 0, 0, reportZone), false},
+		{"2024-02", time.Date(2024, 2, 1, 0, 0, 0, 0, reportZone), time.Date(2024, 3, 1, 0, 0, 0, 0, reportZone), false},
+		{"2024-12", time.Date(2024, 12, 1, 0, 0, 0, 0, reportZone), time.Date(2025, 1, 1, 0, 0, 0, 0, reportZone), false},
+		{"2024-13", time.Time{}, time.Time{}, true},
+		{"2024-1", time.Time{}, time.Time{}, true},
+		{"January", time.Time{}, time.Time{}, true},
+		{"", time.Time{}, time.Time{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.month, func(t *testing.T) {
+			from, to, err := ReportPeriod(tt.month)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ReportPeriod(%q) error = %v, wantErr %v", tt.month, err, tt.wantErr)
+			}
+			if !from.Equal(tt.from) || !to.Equal(tt.to) {
+				t.Errorf("ReportPeriod(%q) = [%v, %v), want [%v, %v)", tt.month, from, to, tt.from, tt.to)
+			}
+		})
+	}
+
+	// A month starts at midnight in East Africa, which is still the previous day in UTC
+	from, _, _ := ReportPeriod("2024-01")
+	if want := time.Date(2023, 12, 31, 21, 0, 0, 0, time.UTC); !from.Equal(want) {
+		t.Errorf("ReportPeriod(2024-01) starts at %v, want %v", from.UTC(), want)
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	report := &Report{Month: "2024-01", Lines: []ReportLine{
+		{Jurisdiction: "TZ", Kind: VAT, Currency: domain.TZS, Transactions: 3, Base: 960_00, Amount: 172_80},
+		{Jurisdiction: "UG", Kind: WithholdingTax, Currency: domain.UGX, Transactions: 1, Base: 315, Amount: -19},
+	}}
+	var buf bytes.Buffer
+	if err := WriteCSV(&buf, report); err != nil {
+		t.Fatalf("WriteCSV() error = %v", err)
+	}
+	want := "month,jurisdiction,kind,currency,transactions,base,amount\n" +
+		"2024-01,TZ,VAT,TZS,3,960.00,172.80\n" +
+		"2024-01,UG,WHT,UGX,1,315,-19\n"
+	if buf.String() != want {
+		t.Errorf("WriteCSV() =\n%s\nwant\n%s", buf.String(), want)
+	}
+}
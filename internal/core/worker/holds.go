@@ -0,0 +1,26 @@
+package worker
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/ibrahimkeyboad/gopay/internal/adapter/storage"
+)
+
+// StartHoldExpiryWorker periodically marks authorized holds past their expiry as EXPIRED
+func StartHoldExpiryWorker(repo *storage.LedgerRepository) {
+	go func() {
+		slog.Info("👷 Hold Expiry Worker started")
+		for {
+			expired, err := repo.ExpireHolds(context.Background())
+			if err != nil {
+				slog.Error("Worker: Failed to expire holds", "error", err)
+			}
+			if expired > 0 {
+				slog.Info("Worker: Expired holds released", "count", expired)
+			}
+			time.Sleep(time.Minute)
+		}
+	}()
+}
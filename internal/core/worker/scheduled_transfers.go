@@ -0,0 +1,41 @@
+package worker
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/ibrahimkeyboad/gopay/internal/adapter/storage"
+	"github.com/ibrahimkeyboad/gopay/internal/core/schedule"
+)
+
+// StartScheduledTransferWorker runs due scheduled transfers every 30 seconds.
+// Several instances can run side by side: each due transfer is picked by one of them.
+func StartScheduledTransferWorker(repo *storage.LedgerRepository, policy schedule.RetryPolicy) {
+	go func() {
+		slog.Info("👷 Scheduled Transfer Worker started")
+		for {
+			runDueTransfers(repo, policy)
+			time.Sleep(30 * time.Second)
+		}
+	}()
+}
+
+// runDueTransfers works through everything due now, one transfer at a time
+func runDueTransfers(repo *storage.LedgerRepository, policy schedule.RetryPolicy) {
+	ran := 0
+	for {
+		found, err := repo.RunDueTransfer(context.Background(), time.Now(), policy)
+		if err != nil {
+			slog.Error("Worker: Scheduled transfer run failed", "error", err)
+			return
+		}
+		if !found {
+			break
+		}
+		ran++
+	}
+	if ran > 0 {
+		slog.Info("Worker: Scheduled transfers processed", "count", ran)
+	}
+}
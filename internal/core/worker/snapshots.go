@@ -0,0 +1,28 @@
+package worker
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/ibrahimkeyboad/gopay/internal/adapter/storage"
+)
+
+// StartSnapshotWorker records every account's balance at midnight UTC, once a day.
+// It checks hourly; snapshots that already exist are skipped. It waits storage.SnapshotLag
+// past midnight so transactions that started before it have committed.
+func StartSnapshotWorker(repo *storage.LedgerRepository) {
+	go func() {
+		slog.Info("👷 Balance Snapshot Worker started")
+		for {
+			at := time.Now().UTC().Add(-storage.SnapshotLag).Truncate(24 * time.Hour)
+			taken, err := repo.TakeBalanceSnapshots(context.Background(), at)
+			if err != nil {
+				slog.Error("Worker: Failed to take balance snapshots", "error", err)
+			} else if taken > 0 {
+				slog.Info("Worker: Balance snapshots taken", "count", taken, "snapshot_at", at)
+			}
+			time.Sleep(time.Hour)
+		}
+	}()
+}
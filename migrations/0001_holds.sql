@@ -0,0 +1,21 @@
+-- Authorization holds: funds reserved against an account's available balance
+-- until they are captured (in full or partially), voided or expire.
+CREATE TABLE IF NOT EXISTS holds (
+    id                     UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    account_id             UUID NOT NULL REFERENCES accounts(id),
+    destination_account_id UUID NOT NULL REFERENCES accounts(id),
+    amount                 BIGINT NOT NULL CHECK (amount > 0),
+    captured_amount        BIGINT NOT NULL DEFAULT 0 CHECK (captured_amount >= 0 AND captured_amount <= amount),
+    currency               TEXT NOT NULL,
+    description            TEXT NOT NULL DEFAULT '',
+    status                 TEXT NOT NULL DEFAULT 'AUTHORIZED', -- AUTHORIZED, CAPTURED, VOIDED, EXPIRED
+    expires_at             TIMESTAMPTZ NOT NULL,
+    created_at             TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at             TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_holds_active ON holds (account_id) WHERE status = 'AUTHORIZED';
+
+-- A capture books exactly one transaction. The unique constraint makes a
+-- second capture of the same hold impossible even if the status check races.
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS hold_id UUID UNIQUE REFERENCES holds(id);
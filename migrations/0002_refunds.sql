@@ -0,0 +1,6 @@
+-- Refunds are ordinary transactions that point back at the transaction they reverse.
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS original_transaction_id UUID REFERENCES transactions(id);
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS refunded_amount BIGINT NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS idx_transactions_original ON transactions (original_transaction_id)
+    WHERE original_transaction_id IS NOT NULL;
@@ -0,0 +1,8 @@
+-- Every entry records its own currency so balances can be reported per currency.
+ALTER TABLE entries ADD COLUMN IF NOT EXISTS currency TEXT;
+
+UPDATE entries e SET currency = t.currency
+FROM transactions t
+WHERE e.transaction_id = t.id AND e.currency IS NULL;
+
+ALTER TABLE entries ALTER COLUMN currency SET NOT NULL;
@@ -0,0 +1,36 @@
+-- Locally managed exchange rates: 1 unit of base_currency buys `rate` units of quote_currency.
+CREATE TABLE IF NOT EXISTS fx_rates (
+    base_currency  TEXT NOT NULL,
+    quote_currency TEXT NOT NULL,
+    rate           NUMERIC(24, 10) NOT NULL CHECK (rate > 0),
+    updated_at     TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (base_currency, quote_currency)
+);
+
+-- A quote locks a rate for a short time. It can be booked exactly once.
+CREATE TABLE IF NOT EXISTS fx_quotes (
+    id             UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    account_id     UUID NOT NULL REFERENCES accounts(id),
+    from_currency  TEXT NOT NULL,
+    from_amount    BIGINT NOT NULL CHECK (from_amount > 0),
+    to_currency    TEXT NOT NULL,
+    to_amount      BIGINT NOT NULL CHECK (to_amount > 0),
+    mid_rate       TEXT NOT NULL,
+    rate           TEXT NOT NULL,
+    spread_bps     BIGINT NOT NULL,
+    expires_at     TIMESTAMPTZ NOT NULL,
+    transaction_id UUID UNIQUE REFERENCES transactions(id),
+    created_at     TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Internal ledger accounts (e.g. FX clearing) are flagged with a kind. Customer accounts leave it NULL.
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS system_kind TEXT;
+
+-- 0014 replaces this index with one per mode; don't bring it back when re-run after that
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_indexes WHERE indexname = 'idx_accounts_system_kind_mode') THEN
+        CREATE UNIQUE INDEX IF NOT EXISTS idx_accounts_system_kind
+            ON accounts (system_kind, currency) WHERE system_kind IS NOT NULL;
+    END IF;
+END $$;
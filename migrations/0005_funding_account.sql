@@ -0,0 +1,31 @@
+-- Deposits used to write a single CREDIT entry. From now on they are booked
+-- against a FUNDING system account per currency. This backfills the missing
+-- DEBIT side for historical deposits so the books balance.
+--
+-- Once 0014 has run the backfill is done, FUNDING exists per mode and the index this
+-- relies on is gone, so the migration does nothing when re-run.
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_indexes WHERE indexname = 'idx_accounts_system_kind_mode') THEN
+        INSERT INTO accounts (owner_name, currency, balance, system_kind)
+        SELECT DISTINCT 'GoPay FUNDING (' || e.currency || ')', e.currency, 0, 'FUNDING'
+        FROM entries e
+        ON CONFLICT (system_kind, currency) WHERE system_kind IS NOT NULL DO NOTHING;
+
+        WITH one_sided AS (
+            SELECT e.transaction_id, e.currency, SUM(e.amount) AS amount
+            FROM entries e
+            GROUP BY e.transaction_id, e.currency
+            HAVING bool_and(e.direction = 'CREDIT')
+        ), inserted AS (
+            INSERT INTO entries (transaction_id, account_id, direction, amount, currency)
+            SELECT o.transaction_id, a.id, 'DEBIT', o.amount, o.currency
+            FROM one_sided o
+            JOIN accounts a ON a.system_kind = 'FUNDING' AND a.currency = o.currency
+            RETURNING account_id, amount
+        )
+        UPDATE accounts a SET balance = a.balance - t.total
+        FROM (SELECT account_id, SUM(amount) AS total FROM inserted GROUP BY account_id) t
+        WHERE a.id = t.account_id;
+    END IF;
+END $$;
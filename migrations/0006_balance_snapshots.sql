@@ -0,0 +1,16 @@
+-- Periodic balance snapshots. A snapshot holds the sum of an account's entries
+-- from transactions created strictly before snapshot_at, so a balance at any
+-- moment is the latest snapshot plus the entries since, instead of a scan of
+-- the account's whole history.
+CREATE TABLE IF NOT EXISTS balance_snapshots (
+    account_id  UUID NOT NULL REFERENCES accounts(id),
+    snapshot_at TIMESTAMPTZ NOT NULL,
+    currency    TEXT NOT NULL,
+    balance     BIGINT NOT NULL,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (account_id, snapshot_at)
+);
+
+-- Statements and snapshots read an account's entries by transaction time
+CREATE INDEX IF NOT EXISTS idx_transactions_created_at ON transactions (created_at, id);
+CREATE INDEX IF NOT EXISTS idx_entries_account ON entries (account_id);
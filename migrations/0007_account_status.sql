@@ -0,0 +1,19 @@
+-- Account lifecycle. FROZEN accounts can receive money but not send it,
+-- CLOSED accounts can do neither and never reopen.
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'ACTIVE'
+    CHECK (status IN ('ACTIVE', 'FROZEN', 'CLOSED'));
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS status_reason TEXT;
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS status_changed_at TIMESTAMPTZ;
+
+-- Every status change, for compliance
+CREATE TABLE IF NOT EXISTS account_status_changes (
+    id              UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    account_id      UUID NOT NULL REFERENCES accounts(id),
+    previous_status TEXT NOT NULL,
+    status          TEXT NOT NULL,
+    reason          TEXT NOT NULL,
+    note            TEXT NOT NULL DEFAULT '',
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_account_status_changes_account ON account_status_changes (account_id, created_at);
@@ -0,0 +1,17 @@
+-- KYC tiers. New accounts start unverified (TIER_0) and are capped accordingly.
+-- Accounts opened before tiers existed were vetted by hand, so they start at
+-- TIER_2; compliance downgrades any that need it.
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS kyc_tier TEXT;
+UPDATE accounts SET kyc_tier = 'TIER_2' WHERE kyc_tier IS NULL;
+ALTER TABLE accounts ALTER COLUMN kyc_tier SET DEFAULT 'TIER_0';
+ALTER TABLE accounts ALTER COLUMN kyc_tier SET NOT NULL;
+-- Postgres has no ADD CONSTRAINT IF NOT EXISTS, so guard it to keep the migration re-runnable
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'accounts_kyc_tier_check' AND conrelid = 'accounts'::regclass) THEN
+        ALTER TABLE accounts ADD CONSTRAINT accounts_kyc_tier_check CHECK (kyc_tier IN ('TIER_0', 'TIER_1', 'TIER_2'));
+    END IF;
+END $$;
+
+-- Limit checks sum an account's entries per direction
+CREATE INDEX IF NOT EXISTS idx_entries_account_direction ON entries (account_id, direction);
@@ -0,0 +1,12 @@
+-- Overdrafts and credit lines. A STANDARD account may go negative down to
+-- -overdraft_limit; a CREDIT_LINE account down to -credit_limit.
+-- overdrawn_since marks when the balance last went below zero, so the
+-- interest-free grace period can be tracked; it is cleared on repayment.
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS account_type TEXT NOT NULL DEFAULT 'STANDARD'
+    CHECK (account_type IN ('STANDARD', 'CREDIT_LINE'));
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS overdraft_limit BIGINT NOT NULL DEFAULT 0 CHECK (overdraft_limit >= 0);
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS credit_limit BIGINT NOT NULL DEFAULT 0 CHECK (credit_limit >= 0);
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS grace_period_days INT NOT NULL DEFAULT 0 CHECK (grace_period_days >= 0);
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS overdrawn_since TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_accounts_overdrawn ON accounts (overdrawn_since) WHERE overdrawn_since IS NOT NULL;
@@ -0,0 +1,5 @@
+-- Sub-accounts: a platform (e.g. a marketplace) owns child accounts (its sellers).
+-- Only one level deep: a child can't have children of its own.
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS parent_id UUID REFERENCES accounts(id);
+
+CREATE INDEX IF NOT EXISTS idx_accounts_parent ON accounts (parent_id) WHERE parent_id IS NOT NULL;
@@ -0,0 +1,18 @@
+-- Pricing plans (see internal/core/pricing). Every account is on a plan.
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS pricing_plan TEXT NOT NULL DEFAULT 'STANDARD';
+
+-- One row per priced payment: what the customer paid, what we kept, what the
+-- merchant got. Also the source of monthly volume for tiered pricing.
+CREATE TABLE IF NOT EXISTS fees (
+    transaction_id UUID PRIMARY KEY REFERENCES transactions(id),
+    account_id     UUID NOT NULL REFERENCES accounts(id),
+    method         TEXT NOT NULL,
+    pricing_plan   TEXT NOT NULL,
+    currency       TEXT NOT NULL,
+    gross          BIGINT NOT NULL,
+    fee            BIGINT NOT NULL,
+    net            BIGINT NOT NULL,
+    created_at     TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_fees_volume ON fees (account_id, method, currency, created_at);
@@ -0,0 +1,21 @@
+-- Taxes on our fees (see internal/core/tax)
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS tax_jurisdiction TEXT NOT NULL DEFAULT 'TZ';
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS withholding_tax BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- One row per tax on a fee. Refunds add rows with negative base and amount,
+-- so summing a month gives what we owe.
+CREATE TABLE IF NOT EXISTS tax_lines (
+    id             UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    transaction_id UUID NOT NULL REFERENCES transactions(id),
+    account_id     UUID NOT NULL REFERENCES accounts(id),
+    kind           TEXT NOT NULL,
+    jurisdiction   TEXT NOT NULL,
+    rate_bps       BIGINT NOT NULL,
+    base           BIGINT NOT NULL,
+    amount         BIGINT NOT NULL,
+    currency       TEXT NOT NULL,
+    created_at     TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_tax_lines_transaction ON tax_lines (transaction_id);
+CREATE INDEX IF NOT EXISTS idx_tax_lines_created ON tax_lines (created_at);
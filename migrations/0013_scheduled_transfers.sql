@@ -0,0 +1,37 @@
+-- Future-dated and recurring transfers, run by the scheduled transfer worker
+CREATE TABLE IF NOT EXISTS scheduled_transfers (
+    id              UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    from_account_id UUID NOT NULL REFERENCES accounts(id),
+    to_account_id   UUID NOT NULL REFERENCES accounts(id),
+    amount          BIGINT NOT NULL CHECK (amount > 0),
+    currency        TEXT NOT NULL,
+    description     TEXT NOT NULL,
+    frequency       TEXT NOT NULL,              -- ONCE, DAILY, WEEKLY, MONTHLY
+    start_at        TIMESTAMPTZ NOT NULL,
+    end_at          TIMESTAMPTZ,                -- No run after this
+    max_runs        INT,                        -- Number of occurrences, run or given up
+    occurrence      INT NOT NULL DEFAULT 0,     -- Index of the occurrence due at next_run_at
+    runs_completed  INT NOT NULL DEFAULT 0,
+    retries         INT NOT NULL DEFAULT 0,     -- Retries made for the current occurrence
+    next_run_at     TIMESTAMPTZ,                -- NULL once the schedule is over
+    status          TEXT NOT NULL DEFAULT 'ACTIVE', -- ACTIVE, COMPLETED, CANCELLED, FAILED
+    last_error      TEXT,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at      TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_scheduled_transfers_due ON scheduled_transfers (next_run_at) WHERE status = 'ACTIVE';
+CREATE INDEX IF NOT EXISTS idx_scheduled_transfers_from ON scheduled_transfers (from_account_id, created_at);
+
+-- Every attempt, successful or not
+CREATE TABLE IF NOT EXISTS scheduled_transfer_runs (
+    id                    UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    scheduled_transfer_id UUID NOT NULL REFERENCES scheduled_transfers(id),
+    occurrence            INT NOT NULL,
+    transaction_id        UUID REFERENCES transactions(id),
+    status                TEXT NOT NULL,        -- SUCCEEDED, FAILED
+    error                 TEXT,
+    created_at            TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_scheduled_transfer_runs_schedule ON scheduled_transfer_runs (scheduled_transfer_id, created_at);
@@ -0,0 +1,16 @@
+-- Test mode. Test data lives on separate accounts (livemode = false): each live account
+-- gets a test twin the first time a gp_test_ key is issued for it, and the system accounts
+-- exist once per mode. A transaction only ever touches accounts of its own mode.
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS livemode BOOLEAN NOT NULL DEFAULT TRUE;
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS live_account_id UUID REFERENCES accounts(id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_accounts_test_twin ON accounts (live_account_id) WHERE live_account_id IS NOT NULL;
+
+DROP INDEX IF EXISTS idx_accounts_system_kind;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_accounts_system_kind_mode
+    ON accounts (system_kind, currency, livemode) WHERE system_kind IS NOT NULL;
+
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS livemode BOOLEAN NOT NULL DEFAULT TRUE;
+
+ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS livemode BOOLEAN NOT NULL DEFAULT TRUE;
+-- Keys were always gp_live_ keys but were recorded with the wrong prefix
+UPDATE api_keys SET key_prefix = 'gp_live_' WHERE key_prefix = 'sk_live_';
@@ -0,0 +1,14 @@
+-- API key management: keys can be listed, revoked, given an expiry and rolled.
+-- Only the hash is stored, so keys issued before this migration have no last4.
+ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS id UUID NOT NULL DEFAULT gen_random_uuid();
+CREATE UNIQUE INDEX IF NOT EXISTS idx_api_keys_id ON api_keys (id);
+
+ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ NOT NULL DEFAULT NOW();
+ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS last4 TEXT;
+ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS expires_at TIMESTAMPTZ;
+ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS revoked_at TIMESTAMPTZ;
+ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS last_used_at TIMESTAMPTZ;
+-- Set when the key is rolled: the key that replaces it
+ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS replaced_by UUID REFERENCES api_keys(id);
+
+CREATE INDEX IF NOT EXISTS idx_api_keys_account ON api_keys (account_id, created_at);
@@ -0,0 +1,3 @@
+-- Restricted API keys. NULL scopes means an unrestricted key; NULL allowed_ips means any address.
+ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS scopes TEXT[];
+ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS allowed_ips TEXT[]; -- Addresses or CIDR ranges
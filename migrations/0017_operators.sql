@@ -0,0 +1,27 @@
+-- Back-office operators. Each has their own gp_admin_ key; ADMIN_API_KEY is only the
+-- bootstrap credential used to create and revoke operators.
+CREATE TABLE IF NOT EXISTS operators (
+    id           UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name         TEXT NOT NULL,
+    email        TEXT NOT NULL UNIQUE,
+    key_hash     TEXT NOT NULL UNIQUE,
+    last4        TEXT NOT NULL,
+    revoked_at   TIMESTAMPTZ,
+    last_used_at TIMESTAMPTZ,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Who issued, rolled, revoked or changed each API key, for audit
+CREATE TABLE IF NOT EXISTS api_key_events (
+    id          UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    api_key_id  UUID NOT NULL REFERENCES api_keys(id),
+    account_id  UUID NOT NULL REFERENCES accounts(id),
+    action      TEXT NOT NULL,           -- ISSUED, ROLLED, REVOKED, EXPIRY_CHANGED
+    actor_type  TEXT NOT NULL,           -- ADMIN_KEY, OPERATOR, API_KEY
+    actor_id    UUID,                    -- Operator or API key id (NULL for ADMIN_KEY)
+    ip          TEXT NOT NULL DEFAULT '',
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_api_key_events_key ON api_key_events (api_key_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_api_key_events_account ON api_key_events (account_id, created_at);
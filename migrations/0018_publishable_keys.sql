@@ -0,0 +1,24 @@
+-- Publishable keys (gp_pk_live_ / gp_pk_test_) are safe to embed in web and mobile
+-- checkouts: they can only start payments (payment intents), never read data or move funds.
+ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS publishable BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- A payment a customer started from a checkout
+CREATE TABLE IF NOT EXISTS payment_intents (
+    id                 UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    account_id         UUID NOT NULL REFERENCES accounts(id),
+    amount             BIGINT NOT NULL CHECK (amount > 0),
+    currency           TEXT NOT NULL,
+    method             TEXT NOT NULL,              -- CARD, MOBILE_MONEY
+    status             TEXT NOT NULL DEFAULT 'PROCESSING', -- PROCESSING, SUCCEEDED, FAILED
+    livemode           BOOLEAN NOT NULL,
+    client_secret_hash TEXT NOT NULL,              -- Lets the checkout poll the intent
+    phone_number       TEXT,
+    provider           TEXT,
+    card_brand         TEXT,
+    transaction_id     UUID REFERENCES transactions(id),
+    failure_reason     TEXT,
+    created_at         TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at         TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_payment_intents_account ON payment_intents (account_id, created_at);
@@ -0,0 +1,7 @@
+-- The account a payment was collected for. On a split payment it is the one that may
+-- refund it (the sellers and the platform fee only received a share).
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS merchant_account_id UUID REFERENCES accounts(id);
+
+-- Priced payments recorded their merchant in fees
+UPDATE transactions t SET merchant_account_id = f.account_id
+FROM fees f WHERE f.transaction_id = t.id AND t.merchant_account_id IS NULL;
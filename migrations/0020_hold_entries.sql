@@ -0,0 +1,9 @@
+-- Holds are booked in the ledger: authorizing moves the money from the payer to the HOLDS
+-- system account, and capturing, voiding or expiring moves it on to the destination or back.
+-- Holds authorized before this migration have neither transaction and keep reserving
+-- their money outside the ledger until they are resolved.
+ALTER TABLE holds ADD COLUMN IF NOT EXISTS authorization_transaction_id UUID REFERENCES transactions(id);
+ALTER TABLE holds ADD COLUMN IF NOT EXISTS release_transaction_id UUID REFERENCES transactions(id);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_holds_authorization ON holds (authorization_transaction_id)
+    WHERE authorization_transaction_id IS NOT NULL;
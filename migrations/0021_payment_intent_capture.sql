@@ -0,0 +1,6 @@
+-- A card payment intent only authorizes the card: the money waits in a hold (REQUIRES_CAPTURE)
+-- until the merchant captures it with a secret key. Capturing the hold succeeds the intent;
+-- voiding or expiring it fails the intent.
+ALTER TABLE payment_intents ADD COLUMN IF NOT EXISTS hold_id UUID REFERENCES holds(id);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_payment_intents_hold ON payment_intents (hold_id) WHERE hold_id IS NOT NULL;
@@ -0,0 +1,25 @@
+-- How much of each entry refunds have reversed so far. Partial refunds are allocated over
+-- what is left on every leg, and the last one reverses exactly that remainder, so rounding
+-- can't leave a leg over- or under-refunded.
+ALTER TABLE entries ADD COLUMN IF NOT EXISTS refunded_amount BIGINT NOT NULL DEFAULT 0;
+
+-- Backfill from earlier refunds. Only legs an account has once per transaction, direction
+-- and currency can be matched to their refund entries; the rest start from zero.
+UPDATE entries o SET refunded_amount = r.amount
+FROM (
+    SELECT t.original_transaction_id AS transaction_id, e.account_id, e.currency, e.direction, SUM(e.amount) AS amount
+    FROM entries e
+    JOIN transactions t ON t.id = e.transaction_id
+    WHERE t.original_transaction_id IS NOT NULL AND t.description LIKE 'Refund: %'
+    GROUP BY t.original_transaction_id, e.account_id, e.currency, e.direction
+) r
+WHERE o.transaction_id = r.transaction_id
+  AND o.account_id = r.account_id
+  AND o.currency = r.currency
+  AND o.direction <> r.direction
+  AND o.refunded_amount = 0
+  AND NOT EXISTS (
+      SELECT 1 FROM entries d
+      WHERE d.transaction_id = o.transaction_id AND d.account_id = o.account_id
+        AND d.currency = o.currency AND d.direction = o.direction AND d.id <> o.id
+  );